@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var aggregates = flag.String("aggregate", "", "comma separated `statistics` to output: count, sum, mean, min, max; count alone only counts records without a per-station table, and min/max are not tracked unless requested")
+
+// parseAggregates 解析-aggregate，未指定时返回nil
+func parseAggregates(s string) ([]brc.Aggregate, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var aggs []brc.Aggregate
+	for _, name := range strings.Split(s, ",") {
+		a, err := brc.ParseAggregate(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		aggs = append(aggs, a)
+	}
+	return aggs, nil
+}
+
+// countOnly 判断-aggregate是否只需要总记录数
+func countOnly(aggs []brc.Aggregate) bool {
+	return len(aggs) == 1 && aggs[0] == brc.AggregateCount
+}
+
+// tracksMinMax 判断-aggregate是否需要每个站点的最小值或最大值
+func tracksMinMax(aggs []brc.Aggregate) bool {
+	if len(aggs) == 0 {
+		return true
+	}
+	for _, a := range aggs {
+		if a == brc.AggregateMin || a == brc.AggregateMax {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCount 按format输出总记录数，format为空表示-format
+func writeCount(w io.Writer, n int64, format string) error {
+	var err error
+	switch format = outputFormat(format); format {
+	case "text":
+		_, err = fmt.Fprintln(w, n)
+	case "json":
+		_, err = fmt.Fprintf(w, "{\"count\":%d}\n", n)
+	case "csv":
+		_, err = fmt.Fprintf(w, "count\n%d\n", n)
+	default:
+		err = fmt.Errorf("-aggregate count supports text, json and csv formats, got %q", format)
+	}
+	return err
+}
+
+// runCount 只统计path中的记录数，不区分站点，被中断或超时时输出部分计数并返回checkInterrupted的退出码
+func runCount(path string, opts []brc.Option) (status int) {
+	var n int64
+	var err error
+	if path == "-" {
+		n, err = brc.CountReader(os.Stdin, opts...)
+	} else {
+		n, err = brc.Count(path, opts...)
+	}
+	status = checkInterrupted(path, err)
+
+	all, err := sinks()
+	check(err)
+	var errs []error
+	for _, s := range all {
+		switch {
+		case s.sqlite != "":
+			err = errors.New("-aggregate count cannot be written to SQLite")
+		case s.statsd != "":
+			err = errors.New("-aggregate count cannot be sent to statsd")
+		case s.path == "-":
+			err = writeCount(os.Stdout, n, s.format)
+		default:
+			err = writeFileAtomic(s.path, func(w io.Writer) error {
+				return writeCount(w, n, s.format)
+			})
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	check(errors.Join(errs...))
+	return status
+}
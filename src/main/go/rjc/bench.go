@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+type benchRun struct {
+	elapsed time.Duration
+	rows    int64
+	// 本次运行期间的GC次数、GC暂停的总时间和分配的字节数
+	gcs       uint32
+	gcPause   time.Duration
+	allocated uint64
+}
+
+// measureRun 执行一次process并记录耗时和GC情况，ReadMemStats不计入耗时
+func measureRun(process func() (brc.Results, error)) (benchRun, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	results, err := process()
+	elapsed := time.Since(start)
+	if err != nil {
+		return benchRun{}, err
+	}
+	runtime.ReadMemStats(&after)
+	return benchRun{
+		elapsed:   elapsed,
+		rows:      countRows(results),
+		gcs:       after.NumGC - before.NumGC,
+		gcPause:   time.Duration(after.PauseTotalNs - before.PauseTotalNs),
+		allocated: after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
+
+type benchSummary struct {
+	runs        int
+	min, mean   time.Duration
+	max         time.Duration
+	bytesPerSec float64
+	rowsPerSec  float64
+	peakRSS     int64
+}
+
+// 基于平均耗时计算吞吐量
+func summarizeRuns(runs []benchRun, size int64) benchSummary {
+	s := benchSummary{runs: len(runs)}
+	if len(runs) == 0 {
+		return s
+	}
+	var total time.Duration
+	s.min = runs[0].elapsed
+	for _, r := range runs {
+		total += r.elapsed
+		s.min = min(s.min, r.elapsed)
+		s.max = max(s.max, r.elapsed)
+	}
+	s.mean = total / time.Duration(len(runs))
+	if secs := s.mean.Seconds(); secs > 0 {
+		s.bytesPerSec = float64(size) / secs
+		s.rowsPerSec = float64(runs[0].rows) / secs
+	}
+	return s
+}
+
+func printBenchSummary(w io.Writer, s benchSummary) {
+	fmt.Fprintf(w, "runs: %d\n", s.runs)
+	fmt.Fprintf(w, "wall: min %s, mean %s, max %s\n", s.min, s.mean, s.max)
+	fmt.Fprintf(w, "throughput: %.2f GB/s, %.0f rows/s\n", s.bytesPerSec/1e9, s.rowsPerSec)
+	if s.peakRSS > 0 {
+		fmt.Fprintf(w, "peak RSS: %s\n", formatBytes(s.peakRSS))
+	}
+}
+
+// benchSchemaVersion 是bench -json输出的格式版本，字段的含义改变或删除字段时递增，增加字段时不变
+const benchSchemaVersion = 1
+
+// benchReport 是bench -json输出的内容，耗时以秒为单位
+type benchReport struct {
+	SchemaVersion int    `json:"schema_version"`
+	Time          string `json:"time"`
+	Revision      string `json:"revision,omitempty"`
+	GoVersion     string `json:"go_version"`
+	GOOS          string `json:"goos"`
+	GOARCH        string `json:"goarch"`
+	NumCPU        int    `json:"num_cpu"`
+	Input         string `json:"input"`
+	Bytes         int64  `json:"bytes"`
+	Engine        string `json:"engine"`
+	Workers       int    `json:"workers"`
+	Warmup        int    `json:"warmup"`
+
+	Runs []benchRunReport `json:"runs"`
+
+	MinSeconds  float64 `json:"min_seconds"`
+	MeanSeconds float64 `json:"mean_seconds"`
+	MaxSeconds  float64 `json:"max_seconds"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+	RowsPerSec  float64 `json:"rows_per_sec"`
+	PeakRSS     int64   `json:"peak_rss_bytes"`
+}
+
+type benchRunReport struct {
+	Seconds        float64 `json:"seconds"`
+	Rows           int64   `json:"rows"`
+	BytesPerSec    float64 `json:"bytes_per_sec"`
+	GCs            uint32  `json:"gcs"`
+	GCPauseSeconds float64 `json:"gc_pause_seconds"`
+	AllocatedBytes uint64  `json:"allocated_bytes"`
+}
+
+// newBenchReport 汇总runs和运行环境，revision取自构建信息中的VCS版本
+func newBenchReport(runs []benchRun, s benchSummary, path string, size int64) benchReport {
+	r := benchReport{
+		SchemaVersion: benchSchemaVersion,
+		Time:          startTime.UTC().Format(time.RFC3339),
+		GoVersion:     runtime.Version(),
+		GOOS:          runtime.GOOS,
+		GOARCH:        runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+		Input:         path,
+		Bytes:         size,
+		Runs:          make([]benchRunReport, len(runs)),
+		MinSeconds:    s.min.Seconds(),
+		MeanSeconds:   s.mean.Seconds(),
+		MaxSeconds:    s.max.Seconds(),
+		BytesPerSec:   s.bytesPerSec,
+		RowsPerSec:    s.rowsPerSec,
+		PeakRSS:       s.peakRSS,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				r.Revision = setting.Value
+			}
+		}
+	}
+	for i, run := range runs {
+		r.Runs[i] = benchRunReport{
+			Seconds:        run.elapsed.Seconds(),
+			Rows:           run.rows,
+			BytesPerSec:    float64(size) / max(run.elapsed.Seconds(), 1e-9),
+			GCs:            run.gcs,
+			GCPauseSeconds: run.gcPause.Seconds(),
+			AllocatedBytes: run.allocated,
+		}
+	}
+	return r
+}
+
+func countRows(results brc.Results) int64 {
+	var rows int64
+	for _, s := range results {
+		rows += int64(s.Count)
+	}
+	return rows
+}
+
+// brc bench [-runs N] [-warmup N] [-engine name] [-workers N] [-json] [file]
+func bench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.Usage = func() {
+		commandUsage(fs, "bench")
+	}
+	runs := fs.Int("runs", 5, "number of measured `runs`")
+	warmup := fs.Int("warmup", 1, "number of `warmup` runs to discard")
+	engine := fs.String("engine", "", "input `engine`: scanner, mmap, chunk or parquet")
+	workers := fs.Int("workers", 0, "number of parallel `workers`, 0 means min(8, NumCPU)")
+	asJSON := fs.Bool("json", false, "print per-run timings, throughput, GC statistics and peak RSS as a JSON document for regression tracking")
+	parseFlags(fs, args)
+
+	if *runs <= 0 || *warmup < 0 {
+		fatalUsage("invalid -runs %d or -warmup %d", *runs, *warmup)
+	}
+	path := "measurements.txt"
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	path, err := filepath.Abs(path)
+	check(err)
+	info, err := os.Stat(path)
+	checkProcessError(path, err)
+
+	opts := []brc.Option{
+		brc.WithEngine(brc.Engine(*engine)),
+		brc.WithWorkers(*workers),
+	}
+	var measured []benchRun
+	for i := 0; i < *warmup+*runs; i++ {
+		run, err := measureRun(func() (brc.Results, error) {
+			return brc.Process(path, opts...)
+		})
+		check(err)
+		if i >= *warmup {
+			measured = append(measured, run)
+		}
+	}
+
+	s := summarizeRuns(measured, info.Size())
+	s.peakRSS = peakRSS()
+	if !*asJSON {
+		printBenchSummary(os.Stdout, s)
+		return
+	}
+	r := newBenchReport(measured, s, path, info.Size())
+	r.Engine, r.Workers, r.Warmup = *engine, *workers, *warmup
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	check(e.Encode(r))
+}
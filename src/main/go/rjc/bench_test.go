@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func TestSummarizeRuns(t *testing.T) {
+	runs := []benchRun{
+		{elapsed: 3 * time.Second, rows: 1000},
+		{elapsed: 1 * time.Second, rows: 1000},
+		{elapsed: 2 * time.Second, rows: 1000},
+	}
+	s := summarizeRuns(runs, 4_000_000_000)
+	if s.runs != 3 || s.min != time.Second || s.max != 3*time.Second || s.mean != 2*time.Second {
+		t.Errorf("unexpected timings: %+v", s)
+	}
+	if s.bytesPerSec != 2e9 || s.rowsPerSec != 500 {
+		t.Errorf("unexpected throughput: %f B/s, %f rows/s", s.bytesPerSec, s.rowsPerSec)
+	}
+}
+
+func TestBenchReport(t *testing.T) {
+	runs := []benchRun{
+		{elapsed: time.Second, rows: 1000, gcs: 2, gcPause: time.Millisecond, allocated: 1 << 20},
+		{elapsed: 3 * time.Second, rows: 1000},
+	}
+	s := summarizeRuns(runs, 3e9)
+	r := newBenchReport(runs, s, "measurements.txt", 3e9)
+	if r.SchemaVersion != benchSchemaVersion || r.Bytes != 3e9 || r.MeanSeconds != 2 || r.BytesPerSec != 1.5e9 {
+		t.Errorf("unexpected report %+v", r)
+	}
+	expected := []benchRunReport{
+		{Seconds: 1, Rows: 1000, BytesPerSec: 3e9, GCs: 2, GCPauseSeconds: 0.001, AllocatedBytes: 1 << 20},
+		{Seconds: 3, Rows: 1000, BytesPerSec: 1e9},
+	}
+	if !reflect.DeepEqual(r.Runs, expected) {
+		t.Errorf("expected runs %+v, got %+v", expected, r.Runs)
+	}
+
+	run, err := measureRun(func() (brc.Results, error) {
+		s := brc.NewStatistic()
+		s.ParseAndAddLines([]byte("a;1.0\nb;2.0\n"))
+		return s.Results(), nil
+	})
+	if err != nil || run.rows != 2 || run.elapsed <= 0 {
+		t.Errorf("unexpected run %+v, %v", run, err)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build js && wasm
+
+// wasm 将聚合引擎编译为浏览器或其它JavaScript运行时中使用的WebAssembly模块：
+//
+//	GOOS=js GOARCH=wasm go build -o brc.wasm ./cmd/wasm
+//
+// 配合Go发行版中的wasm_exec.js加载后，全局函数brcProcess(data, format)聚合Uint8Array中的测量数据，
+// format为text（默认）、json或csv，返回格式化的结果，出错时返回Error对象。
+// 回调中的panic会终止整个Go程序，因此错误不以异常的方式抛出
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"syscall/js"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func process(data []byte, format string) (string, error) {
+	// 浏览器中只有一个线程，多个worker没有意义
+	results, err := brc.ProcessBytes(data, brc.WithWorkers(1))
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	switch format {
+	case "", "text":
+		_, err = results.WriteTo(buf)
+	case "json":
+		err = results.WriteJSON(buf, brc.WriteOptions{})
+	case "csv":
+		err = results.WriteCSV(buf, brc.WriteOptions{})
+	default:
+		err = fmt.Errorf("unknown format %q, want text, json or csv", format)
+	}
+	return buf.String(), err
+}
+
+func main() {
+	js.Global().Set("brcProcess", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 0 || args[0].Type() != js.TypeObject {
+			return js.Global().Get("TypeError").New("brcProcess requires a Uint8Array")
+		}
+		data := make([]byte, args[0].Get("length").Int())
+		js.CopyBytesToGo(data, args[0])
+		format := ""
+		if len(args) > 1 && args[1].Type() == js.TypeString {
+			format = args[1].String()
+		}
+		output, err := process(data, format)
+		if err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+		return output
+	}))
+	// 保持运行，brcProcess在之后的调用中仍然可用
+	select {}
+}
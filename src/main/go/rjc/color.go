@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+)
+
+var color = flag.Bool("color", false, "highlight min, mean and max values that cross -color-thresholds in text and table output when stdout is a terminal")
+var colorThresholds = flag.String("color-thresholds", "max>35:red,mean>25:yellow,min<-20:blue", "comma separated `thresholds` for -color as metric>value:color or metric<value:color, in -unit degrees")
+
+// isTerminal 判断w是否为终端
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
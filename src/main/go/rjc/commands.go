@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command 是一个子命令，args是帮助中参数部分的概要
+type command struct {
+	name    string
+	args    string
+	summary string
+}
+
+// commands 按帮助中的顺序列出所有子命令
+var commands = []command{
+	{"run", "[flags] [file]", "aggregate measurements and print min/mean/max per station (default)"},
+	{"generate", "[-rows N] [-stations N] [-seed N] [-stddev N] [-out file]", "generate a measurements file"},
+	{"bench", "[-runs N] [-warmup N] [-engine name] [-workers N] [-json] [file]", "time repeated runs over a file and report throughput"},
+	{"verify", "[flags] expected [file]", "aggregate measurements and compare the output with an expected output file"},
+	{"consume", "[-brokers host:port,...] [-topic name] [-start earliest|latest] [-window d] [-format f] [-http addr]", "aggregate station;value lines from a Kafka topic and print the results of each window"},
+	{"inspect", "-cardinality [-top N] [-workers N] [file]", "estimate the number of distinct stations and the most frequent names without aggregating"},
+	{"save", "-state file [flags] [file]", "aggregate measurements and save the partial state for a later merge"},
+	{"merge", "[flags] state...", "merge saved states and print the results"},
+	{"merge-results", "[flags] results...", "combine the text or json outputs of separately processed inputs into one result"},
+	{"split", "[-parts N] [-prefix prefix] file", "split a measurements file into parts on line boundaries for distributed or multi-process runs"},
+	{"sort", "[-out file] [-run-size size] [-workers N] [-temp-dir dir] [file]", "sort a measurements file by station name with a parallel external merge sort, so that sorted inputs can be grouped in constant memory"},
+	{"serve", "[-listen addr] [-udp addr] [-http addr] [-grpc addr] [-shards N] [-max-rows-per-sec N] [-max-bytes-per-sec size]", "aggregate measurements streamed over the network and serve the current results"},
+	{"worker", "-range start:end -report addr [flags] file", "aggregate a byte range of a shared file and report it to a coordinator"},
+	{"coordinator", "-shards N [-listen addr] [flags]", "merge the states reported by workers and print the results"},
+	{"help", "[command]", "show help for a command"},
+}
+
+// lookupCommand 返回名为name的子命令，不存在时返回nil
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// runCommand 运行子命令name，使用flag.CommandLine的子命令的-h只列出适用于它的参数
+func runCommand(name string, args []string) {
+	flag.CommandLine.Usage = func() {
+		commandUsage(flag.CommandLine, name)
+	}
+	switch name {
+	case "run":
+		runAggregate(args)
+	case "generate":
+		generate(args)
+	case "bench":
+		bench(args)
+	case "verify":
+		verifyCommand(args)
+	case "consume":
+		consume(args)
+	case "inspect":
+		inspect(args)
+	case "save":
+		saveState(args)
+	case "merge":
+		mergeStates(args)
+	case "merge-results":
+		mergeResults(args)
+	case "split":
+		split(args)
+	case "sort":
+		sortCommand(args)
+	case "serve":
+		serve(args)
+	case "worker":
+		worker(args)
+	case "coordinator":
+		coordinator(args)
+	case "help":
+		help(args)
+	}
+}
+
+// commandUsage 输出子命令name的用法、说明和参数
+func commandUsage(fs *flag.FlagSet, name string) {
+	c := lookupCommand(name)
+	w := fs.Output()
+	fmt.Fprintf(w, "usage: brc %s %s\n\n%s.\n", c.name, c.args, c.summary)
+	if name == "run" {
+		fmt.Fprintf(w, "Without a command brc runs run, see brc help for the other commands.\n")
+	}
+	fmt.Fprintf(w, "\nflags:\n")
+	// 其它子命令专用的参数不列出
+	shown := flag.NewFlagSet(name, flag.ContinueOnError)
+	shown.SetOutput(w)
+	fs.VisitAll(func(f *flag.Flag) {
+		if cmd, ok := subcommandFlags[f.Name]; !ok || cmd == name || fs != flag.CommandLine {
+			shown.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	shown.PrintDefaults()
+	printEnvHelp(w, name == "run" || name == "verify")
+}
+
+// help 实现help子命令：没有参数时列出所有子命令，否则输出子命令的用法
+func help(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: brc [command] [flags] [args]\n\ncommands:\n")
+		for _, c := range commands {
+			fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(os.Stderr, "\nWithout a command brc runs run. Use brc help <command> for the flags of a command.\n")
+		return
+	}
+	if lookupCommand(args[0]) == nil || args[0] == "help" {
+		fatalUsage("unknown command %q, see brc help", args[0])
+	}
+	runCommand(args[0], []string{"-h"})
+}
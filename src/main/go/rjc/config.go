@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var configFile = flag.String("config", "", "read flag values from a TOML (key = value) or YAML (key: value) `file`, keys are flag names; flags given on the command line or as BRC_* environment variables take precedence")
+
+// configEntry 是配置文件中的一个参数，列表的每个元素依次传给flag.Set
+type configEntry struct {
+	name   string
+	values []string
+	line   int
+}
+
+// parseConfig 解析只包含顶层键值对的TOML或YAML，根据name的扩展名选择格式。
+// 值可以是带引号的字符串、数字、布尔值或其它不含空格的裸值，以及这些值组成的[a, b]形式的列表，
+// YAML中还可以在值为空的键之后用`- value`逐行列出列表的元素
+func parseConfig(name string, data []byte) ([]configEntry, error) {
+	sep := "="
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".toml":
+	case ".yaml", ".yml":
+		sep = ":"
+	default:
+		return nil, fmt.Errorf("unknown config format %q, want .toml, .yaml or .yml", ext)
+	}
+	var entries []configEntry
+	// items 表示最后一个YAML键的值为空，之后的`- value`是它的元素
+	items := false
+	// checkItems 在YAML键的值为空并且之后没有元素时返回错误
+	checkItems := func() error {
+		if last := len(entries) - 1; items && len(entries[last].values) == 0 {
+			return fmt.Errorf("line %d: missing value for %s", entries[last].line, entries[last].name)
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(line, "- "); ok && sep == ":" {
+			if !items {
+				return nil, fmt.Errorf("line %d: list item %q does not follow a key with an empty value", n, line)
+			}
+			v, err := configValue(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			last := &entries[len(entries)-1]
+			last.values = append(last.values, v)
+			continue
+		}
+		if err := checkItems(); err != nil {
+			return nil, err
+		}
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key %s value, got %q", n, sep, line)
+		}
+		key = strings.ReplaceAll(strings.Trim(strings.TrimSpace(key), `"`), "_", "-")
+		entry := configEntry{name: key, line: n}
+		items = false
+		if value = strings.TrimSpace(value); strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			for _, item := range splitList(value[1 : len(value)-1]) {
+				v, err := configValue(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				entry.values = append(entry.values, v)
+			}
+		} else if value != "" {
+			v, err := configValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			entry.values = []string{v}
+		} else if sep == "=" {
+			return nil, fmt.Errorf("line %d: missing value for %s", n, key)
+		} else {
+			items = true
+		}
+		entries = append(entries, entry)
+	}
+	if err := checkItems(); err != nil {
+		return nil, err
+	}
+	return entries, scanner.Err()
+}
+
+// stripComment 去掉引号之外的'#'及其之后的内容
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitList 按引号之外的','拆分列表的元素
+func splitList(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// configValue 去掉值的引号，双引号内的转义与Go相同，单引号内不转义
+func configValue(v string) (string, error) {
+	switch {
+	case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+		return strconv.Unquote(v)
+	case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+		return v[1 : len(v)-1], nil
+	case strings.ContainsAny(v, `"'`):
+		return "", fmt.Errorf("unbalanced quotes in %s", v)
+	}
+	return v, nil
+}
+
+// applyConfig 将-config中命令行和环境变量都没有指定的参数设置到flag.CommandLine
+func applyConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	entries, err := parseConfig(path, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, e := range entries {
+		if e.name == "config" {
+			return fmt.Errorf("%s:%d: config files cannot include other config files", path, e.line)
+		}
+		if flag.Lookup(e.name) == nil {
+			return fmt.Errorf("%s:%d: unknown flag %q", path, e.line, e.name)
+		}
+		if set[e.name] {
+			continue
+		}
+		for _, v := range e.values {
+			if err := flag.Set(e.name, v); err != nil {
+				return fmt.Errorf("%s:%d: invalid value %q for %s: %w", path, e.line, v, e.name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	toml := `# benchmark configuration
+input = "data/measurements 1B.txt"
+workers = 8
+engine = 'mmap' # inline comment
+filter_prefix = "#1"
+derive = ["range=max-min", "mid=(min+max)/2"]
+`
+	yaml := `---
+input: "data/measurements 1B.txt"
+workers: 8
+engine: mmap # inline comment
+filter-prefix: "#1"
+derive:
+  - range=max-min
+  - "mid=(min+max)/2"
+`
+	expected := []configEntry{
+		{"input", []string{"data/measurements 1B.txt"}, 2},
+		{"workers", []string{"8"}, 3},
+		{"engine", []string{"mmap"}, 4},
+		{"filter-prefix", []string{"#1"}, 5},
+		{"derive", []string{"range=max-min", "mid=(min+max)/2"}, 6},
+	}
+	for name, data := range map[string]string{"brc.toml": toml, "brc.yaml": yaml} {
+		entries, err := parseConfig(name, []byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(entries, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, entries)
+		}
+	}
+
+	for name, data := range map[string]string{
+		"brc.json": "{}",
+		"a.toml":   "[run]\nworkers = 8\n",
+		"b.toml":   "workers =\n",
+		"c.toml":   `input = "x` + "\n",
+		"d.yaml":   "workers 8\n",
+		"e.yaml":   "output:\nverbose: true\n",
+		"f.yaml":   "verbose:\n",
+		"g.yaml":   "workers: 4\n- 8\n",
+		"h.yaml":   "- 8\n",
+	} {
+		if _, err := parseConfig(name, []byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	workers := fs.Int("workers", 0, "")
+	engine := fs.String("engine", "", "")
+	maxMemory := fs.String("max-memory", "", "")
+	if err := fs.Parse([]string{"-engine", "chunk"}); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"BRC_WORKERS": "4", "BRC_ENGINE": "mmap", "BRC_MAX_MEMORY": "512MB"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	if err := applyEnv(fs, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if *workers != 4 || *engine != "chunk" || *maxMemory != "512MB" {
+		t.Errorf("unexpected workers %d, engine %q, max memory %q", *workers, *engine, *maxMemory)
+	}
+
+	env["BRC_WORKERS"] = "many"
+	if err := applyEnv(flag.NewFlagSet("test", flag.ContinueOnError), lookup); err != nil {
+		t.Errorf("unexpected error for unknown flags: %v", err)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("workers", 0, "")
+	if err := applyEnv(fs, lookup); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+func TestSubcommandEnv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "measurements.txt")
+	if err := os.WriteFile(path, []byte(strings.Repeat("Hamburg;12.0\n", 100)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// split使用自己的FlagSet，参数同样可以由环境变量指定，命令行优先
+	t.Setenv("BRC_PARTS", "5")
+	t.Setenv("BRC_PREFIX", filepath.Join(dir, "env-"))
+	split([]string{"-prefix", filepath.Join(dir, "part-"), path})
+	for _, name := range []string{"part-000", "part-004"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s from BRC_PARTS: %v", name, err)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*-005")); len(matches) != 0 {
+		t.Errorf("unexpected parts %v", matches)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "env-*")); len(matches) != 0 {
+		t.Errorf("expected -prefix to take precedence over BRC_PREFIX, got %v", matches)
+	}
+}
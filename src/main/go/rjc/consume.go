@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// 每次Fetch的最大字节数，以及没有新数据时broker等待的时间
+const (
+	kafkaMaxBytes = 8 * 1024 * 1024
+	kafkaMaxWait  = 500 * time.Millisecond
+)
+
+// consumer 从topic的所有分区读取记录，每条记录的值是一行或多行`station;value`
+type consumer struct {
+	brokers []string
+	topic   string
+	// start 是kafkaEarliest或kafkaLatest，没有已知的offset或offset超出范围时使用
+	start int64
+	// agg 是当前窗口的聚合状态，切换窗口时整体替换
+	agg atomic.Pointer[brc.Aggregator]
+	// writing 在写入agg时持有读锁，切换窗口时持有写锁，等待写入旧窗口的批次完成
+	writing sync.RWMutex
+}
+
+// add 将lines写入当前窗口
+func (c *consumer) add(lines []byte) {
+	c.writing.RLock()
+	defer c.writing.RUnlock()
+	c.agg.Load().AddLines(lines)
+}
+
+// swap 以next开始新的窗口，等待正在写入的批次完成后返回旧窗口的聚合状态
+func (c *consumer) swap(next *brc.Aggregator) *brc.Aggregator {
+	c.writing.Lock()
+	defer c.writing.Unlock()
+	return c.agg.Swap(next)
+}
+
+// partitions 依次询问每个broker，返回topic的分区
+func (c *consumer) partitions() ([]kafkaPartition, error) {
+	var errs []error
+	for _, addr := range c.brokers {
+		conn, err := dialKafka(addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		partitions, err := conn.metadata(c.topic)
+		conn.Close()
+		if err == nil {
+			return partitions, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", addr, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// consumePartition 持续读取分区，连接失败或leader变化时重新获取metadata并从下一个offset继续
+func (c *consumer) consumePartition(partition int32) {
+	offset := int64(-1)
+	for {
+		err := c.fetchFromLeader(partition, &offset)
+		log.Printf("%s/%d: %v, retrying", c.topic, partition, err)
+		time.Sleep(time.Second)
+	}
+}
+
+// fetchFromLeader 连接分区的leader并循环Fetch，直到出错
+func (c *consumer) fetchFromLeader(partition int32, offset *int64) error {
+	partitions, err := c.partitions()
+	if err != nil {
+		return err
+	}
+	leader := ""
+	for _, p := range partitions {
+		if p.id == partition {
+			leader = p.leader
+		}
+	}
+	if leader == "" {
+		return errors.New("no leader")
+	}
+	conn, err := dialKafka(leader)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var lines []byte
+	for {
+		if *offset < 0 {
+			if *offset, err = conn.listOffset(c.topic, partition, c.start); err != nil {
+				return err
+			}
+		}
+		records, err := conn.fetch(c.topic, partition, *offset, kafkaMaxBytes, kafkaMaxWait)
+		if err == kafkaOffsetOutOfRange {
+			*offset = -1
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		lines = lines[:0]
+		next, err := decodeRecords(records, *offset, func(value []byte) {
+			lines = append(lines, value...)
+			if value[len(value)-1] != '\n' {
+				lines = append(lines, '\n')
+			}
+		})
+		if len(lines) > 0 {
+			c.add(lines)
+		}
+		*offset = next
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeWindow 按format输出一个窗口的结果
+func writeWindow(w io.Writer, results brc.Results, format string) error {
+	switch format {
+	case "json":
+		return results.WriteJSON(w, brc.WriteOptions{})
+	case "csv":
+		return results.WriteCSV(w, brc.WriteOptions{})
+	default:
+		return results.Write(w, brc.WriteOptions{})
+	}
+}
+
+// brc consume -brokers host:port[,...] -topic name [-start earliest|latest] [-window d] [-format f] [-http addr]
+func consume(args []string) {
+	fs := flag.NewFlagSet("consume", flag.ExitOnError)
+	fs.Usage = func() {
+		commandUsage(fs, "consume")
+	}
+	brokers := fs.String("brokers", "localhost:9092", "comma separated bootstrap `brokers`")
+	topic := fs.String("topic", "measurements", "Kafka `topic` whose records are station;value lines")
+	start := fs.String("start", "latest", "where to start reading each partition: `earliest` or latest")
+	window := fs.Duration("window", time.Minute, "print the results of each tumbling `window` of processing time and start a new one, 0 keeps aggregating")
+	format := fs.String("format", "text", "window output `format`: text, json or csv")
+	shards := fs.Int("shards", 16, "number of `shards` in the shared aggregation state")
+	httpAddr := fs.String("http", "", "serve the current window's results over HTTP on `addr`")
+	parseFlags(fs, args)
+
+	c := &consumer{brokers: strings.Split(*brokers, ","), topic: *topic}
+	switch *start {
+	case "earliest":
+		c.start = kafkaEarliest
+	case "latest":
+		c.start = kafkaLatest
+	default:
+		fatalUsage("unknown -start %q, want earliest or latest", *start)
+	}
+	switch *format {
+	case "text", "json", "csv":
+	default:
+		fatalUsage("unknown -format %q, want text, json or csv", *format)
+	}
+	if *window <= 0 && *httpAddr == "" {
+		fatalUsage("consume requires a positive -window or -http")
+	}
+	c.agg.Store(brc.NewAggregator(*shards))
+
+	partitions, err := c.partitions()
+	if err != nil {
+		log.Fatalf("could not read metadata for %s: %v", *topic, err)
+	}
+	for _, p := range partitions {
+		go c.consumePartition(p.id)
+	}
+	log.Printf("consuming %d partitions of %s", len(partitions), *topic)
+
+	if *httpAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/results", resultsHandler(func() brc.Results { return c.agg.Load().Results() }))
+		go func() {
+			log.Fatal(http.ListenAndServe(*httpAddr, mux))
+		}()
+	}
+	if *window <= 0 {
+		select {}
+	}
+	// 窗口按UTC对齐，切换时已经开始写入旧窗口的批次计入旧窗口，swap等待它们完成后才输出旧窗口
+	for {
+		now := time.Now()
+		end := now.Truncate(*window).Add(*window)
+		time.Sleep(end.Sub(now))
+		old := c.swap(brc.NewAggregator(*shards))
+		log.Printf("window %s: %d rows", end.Add(-*window).UTC().Format(time.RFC3339), old.Rows())
+		check(writeWindow(os.Stdout, old.Results(), *format))
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var byteRange = flag.String("range", "", "`start:end` byte range of the input handled by the worker subcommand, end may be empty for the end of file")
+var report = flag.String("report", "", "coordinator `addr` the worker subcommand sends its partial state to")
+var shards = flag.Int("shards", 0, "number of worker states the coordinator subcommand waits for")
+var listen = flag.String("listen", ":7072", "`addr` the coordinator subcommand listens on")
+
+// coordinator收到状态后回复的确认
+const stateAck = "OK\n"
+
+func parseByteRange(s string) (start, end int64, err error) {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range %q, want start:end", s)
+	}
+	if start, err = strconv.ParseInt(from, 10, 64); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range start %q", from)
+	}
+	if to == "" {
+		return start, 0, nil
+	}
+	if end, err = strconv.ParseInt(to, 10, 64); err != nil || end <= start {
+		return 0, 0, fmt.Errorf("invalid range end %q", to)
+	}
+	return start, end, nil
+}
+
+// sendState 将聚合状态发送给coordinator，等待确认后返回
+func sendState(addr string, s *brc.MergedStatistics) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := s.WriteState(conn); err != nil {
+		return err
+	}
+	ack, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no acknowledgement from coordinator: %w", err)
+	}
+	if ack != stateAck {
+		return fmt.Errorf("unexpected acknowledgement %q from coordinator", ack)
+	}
+	return nil
+}
+
+// worker 实现worker子命令：聚合共享存储上文件的-range区间，将部分聚合状态发送给-report指定的coordinator。
+// 相邻的区间按行对齐，每一行恰好被一个worker处理
+func worker(args []string) {
+	parseFlags(flag.CommandLine, args)
+	if *report == "" {
+		fatalUsage("worker requires -report")
+	}
+	start, end, err := parseByteRange(*byteRange)
+	if err != nil {
+		log.Fatal(err)
+	}
+	path := inputPath()
+	if isStreamInput(path) {
+		fatalUsage("worker requires a regular input file")
+	}
+	merged, err := brc.ProcessState(path, append(processOptions(), brc.WithRange(start, end))...)
+	checkProcessError(path, err)
+	if err := sendState(*report, merged); err != nil {
+		log.Fatalf("could not report to %s: %v", *report, err)
+	}
+}
+
+// collectStates 接收n个worker发送的状态并合并，无法解析的连接会被记录并忽略
+func collectStates(ln net.Listener, n int) *brc.MergedStatistics {
+	var (
+		mu       sync.Mutex
+		merged   *brc.MergedStatistics
+		received int
+		done     = make(chan struct{})
+	)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-done:
+				return merged
+			default:
+			}
+			check(err)
+		}
+		go func() {
+			defer conn.Close()
+			s, err := brc.ReadState(conn)
+			if err != nil {
+				log.Printf("invalid state from %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if received == n {
+				return
+			}
+			if merged == nil {
+				merged = s
+			} else {
+				merged.Merge(s)
+			}
+			received++
+			conn.Write([]byte(stateAck))
+			if received == n {
+				close(done)
+				ln.Close()
+			}
+		}()
+	}
+}
+
+// coordinator 实现coordinator子命令：等待-shards个worker的部分聚合状态，合并后按输出相关的参数输出结果
+func coordinator(args []string) {
+	parseFlags(flag.CommandLine, args)
+	if *shards <= 0 {
+		fatalUsage("coordinator requires a positive -shards")
+	}
+	ln, err := net.Listen("tcp", *listen)
+	check(err)
+	log.Printf("waiting for %d workers on %s", *shards, ln.Addr())
+	merged := collectStates(ln, *shards)
+	check(writeOutput(merged.Results(processOptions()...)))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func TestParseByteRange(t *testing.T) {
+	for _, tc := range []struct {
+		s          string
+		start, end int64
+		ok         bool
+	}{
+		{"0:100", 0, 100, true},
+		{"100:", 100, 0, true},
+		{"100:100", 0, 0, false},
+		{"-1:5", 0, 0, false},
+		{"5", 0, 0, false},
+		{"a:b", 0, 0, false},
+	} {
+		start, end, err := parseByteRange(tc.s)
+		if (err == nil) != tc.ok || start != tc.start || end != tc.end {
+			t.Errorf("%q: expected (%d, %d, %v), got (%d, %d, %v)", tc.s, tc.start, tc.end, tc.ok, start, end, err)
+		}
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	data := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-0.1\n"
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := [][2]int64{{0, 20}, {20, 40}, {40, 0}}
+	errs := make(chan error, len(ranges))
+	for _, r := range ranges {
+		go func(start, end int64) {
+			s, err := brc.ProcessState(path, brc.WithRange(start, end))
+			if err == nil {
+				err = sendState(ln.Addr().String(), s)
+			}
+			errs <- err
+		}(r[0], r[1])
+	}
+	merged := collectStates(ln, len(ranges))
+	for range ranges {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := brc.Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := merged.Results(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
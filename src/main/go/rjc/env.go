@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// envPrefix 是与参数对应的环境变量的前缀，如-max-memory对应BRC_MAX_MEMORY
+const envPrefix = "BRC_"
+
+// envName 返回参数name对应的环境变量名
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv 将fs中命令行没有指定的参数设置为对应环境变量的值，
+// 优先级低于命令行参数、高于-config，因此应在applyConfig之前调用
+func applyEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		if v, ok := lookup(envName(f.Name)); ok {
+			if e := fs.Set(f.Name, v); e != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", v, envName(f.Name), e)
+			}
+		}
+	})
+	return err
+}
+
+// parseFlags 解析子命令的参数，再将命令行没有指定的参数设置为对应环境变量的值，所有子命令都通过它解析参数。
+// 返回这次解析的命令行中指定的参数，之后fs.Visit也会遍历由环境变量、-config和之前的解析设置的参数
+func parseFlags(fs *flag.FlagSet, args []string) map[string]bool {
+	before := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		before[f.Name] = true
+	})
+	check(fs.Parse(args))
+	cmdline := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		if !before[f.Name] {
+			cmdline[f.Name] = true
+		}
+	})
+	if err := applyEnv(fs, os.LookupEnv); err != nil {
+		fatalUsage("%v", err)
+	}
+	return cmdline
+}
+
+// printEnvHelp 说明参数也可以通过环境变量指定，config表示子命令还支持-config
+func printEnvHelp(w io.Writer, config bool) {
+	if !config {
+		fmt.Fprintf(w, "\nEvery flag can also be set with a %s environment variable, e.g. -workers as %s;\n"+
+			"command line flags take precedence over environment variables.\n", envPrefix+"*", envName("workers"))
+		return
+	}
+	fmt.Fprintf(w, "\nEvery flag can also be set with a %s environment variable, e.g. -max-memory as %s;\n"+
+		"command line flags take precedence over environment variables, which take precedence over -config.\n", envPrefix+"*", envName("max-memory"))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var estimate = flag.Bool("estimate", false, "aggregate only the first and last -estimate-sample of the input and print the predicted runtime, station count and peak memory for the whole file instead of the results")
+var estimateSample = flag.String("estimate-sample", "256MB", "`size` sampled from each end of the input by -estimate")
+
+// estimation 是-estimate对样本的测量结果
+type estimation struct {
+	size     int64
+	sampled  int64
+	elapsed  time.Duration
+	stations int
+	// tailOnly 是只在末尾样本中出现的站点数，大于0时整个文件的站点数可能更多
+	tailOnly int
+	peakRSS  int64
+}
+
+// predictedRuntime 按样本的吞吐量推算处理整个文件的耗时
+func (e estimation) predictedRuntime() time.Duration {
+	if e.sampled == 0 {
+		return 0
+	}
+	return time.Duration(float64(e.elapsed) * float64(e.size) / float64(e.sampled))
+}
+
+func printEstimate(w io.Writer, e estimation) {
+	fmt.Fprintf(w, "sampled: %s of %s in %s (%.2f GB/s)\n", formatBytes(e.sampled), formatBytes(e.size),
+		e.elapsed.Round(time.Millisecond), float64(e.sampled)/max(e.elapsed.Seconds(), 1e-9)/1e9)
+	fmt.Fprintf(w, "predicted runtime: %s\n", e.predictedRuntime().Round(time.Millisecond))
+	if e.tailOnly > 0 {
+		fmt.Fprintf(w, "stations: at least %d, %d only seen at the end of the file\n", e.stations, e.tailOnly)
+	} else {
+		fmt.Fprintf(w, "stations: %d\n", e.stations)
+	}
+	// 峰值内存主要取决于读缓冲区和站点数，与文件大小无关
+	if e.peakRSS > 0 {
+		fmt.Fprintf(w, "predicted peak RSS: %s\n", formatBytes(e.peakRSS))
+	}
+}
+
+// runEstimate 实现-estimate：聚合输入开头和末尾各-estimate-sample字节，输出对整个文件的预测
+func runEstimate(path string, opts []brc.Option) {
+	if isStreamInput(path) {
+		fatalUsage("-estimate requires a regular input file")
+	}
+	sample, err := parseSize(*estimateSample)
+	if err != nil || sample <= 0 {
+		fatalUsage("invalid -estimate-sample %q", *estimateSample)
+	}
+	info, err := os.Stat(path)
+	checkProcessError(path, err)
+	e := estimation{size: info.Size()}
+	ranges := [][2]int64{{0, e.size}}
+	if e.size > 2*sample {
+		ranges = [][2]int64{{0, sample}, {e.size - sample, e.size}}
+	}
+	seen := make(map[string]bool)
+	start := time.Now()
+	for i, r := range ranges {
+		results, err := brc.Process(path, append(opts[:len(opts):len(opts)], brc.WithRange(r[0], r[1]))...)
+		checkProcessError(path, err)
+		for _, s := range results {
+			if !seen[s.Name] && i > 0 {
+				e.tailOnly++
+			}
+			seen[s.Name] = true
+		}
+		e.sampled += r[1] - r[0]
+	}
+	e.elapsed = time.Since(start)
+	e.stations = len(seen)
+	e.peakRSS = peakRSS()
+	printEstimate(os.Stdout, e)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimation(t *testing.T) {
+	e := estimation{size: 10_000_000_000, sampled: 500_000_000, elapsed: 2 * time.Second, stations: 413, tailOnly: 2}
+	if got := e.predictedRuntime(); got != 40*time.Second {
+		t.Errorf("expected 40s, got %s", got)
+	}
+	buf := &bytes.Buffer{}
+	printEstimate(buf, e)
+	for _, want := range []string{"predicted runtime: 40s\n", "stations: at least 413, 2 only seen at the end of the file\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+	if got := (estimation{}).predictedRuntime(); got != 0 {
+		t.Errorf("expected 0 for an empty sample, got %s", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// 进程的退出码
+const (
+	exitError = 1
+	// exitUsage 与flag包解析参数失败时相同
+	exitUsage = 2
+	exitIO    = 3
+	// exitParse 表示-strict遇到了不合法的行，或者merge-results的结果文件格式不正确
+	exitParse = 4
+	// exitWarnings 表示结果已经输出，但-report-malformed跳过了不合法的行
+	exitWarnings = 5
+	// exitInterrupted 表示收到SIGINT或SIGTERM，输出的是中断之前的部分结果
+	exitInterrupted = 6
+	// exitTimeout 表示超过了-timeout，输出的是超时之前的部分结果
+	exitTimeout = 7
+)
+
+var reportMalformed = flag.Int("report-malformed", 0, "validate lines like -strict but skip malformed ones, then print their count and the first `N` with offsets to stderr and exit with status 5")
+
+// exitCode 返回err对应的退出码
+func exitCode(err error) int {
+	var perr *brc.ParseError
+	var ferr *brc.ResultsFormatError
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &perr), errors.As(err, &ferr):
+		return exitParse
+	case errors.As(err, &pathErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return exitIO
+	default:
+		return exitError
+	}
+}
+
+// check 在err不为nil时报告err，并以对应的退出码退出
+func check(err error) {
+	if err != nil {
+		log.Print(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// fatalUsage 报告参数错误，并与flag包一样以exitUsage退出
+func fatalUsage(format string, v ...any) {
+	log.Printf(format, v...)
+	os.Exit(exitUsage)
+}
+
+// checkProcessError 以可读的方式报告处理输入时的错误
+func checkProcessError(path string, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("input file %s does not exist", path)
+		os.Exit(exitIO)
+	}
+	check(err)
+}
+
+// checkInterrupted 在err是InterruptedError时报告结果不完整，并返回超时时的exitTimeout或收到信号时的exitInterrupted，
+// err为nil时返回0，其它错误与checkProcessError相同
+func checkInterrupted(path string, err error) int {
+	var ierr *brc.InterruptedError
+	if !errors.As(err, &ierr) {
+		checkProcessError(path, err)
+		return 0
+	}
+	reason, status := "interrupted", exitInterrupted
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason, status = fmt.Sprintf("timed out (-timeout %s)", *timeout), exitTimeout
+	}
+	if ierr.Size > 0 {
+		log.Printf("partial results: %s after %s of %s (%.1f%%), %d rows", reason,
+			formatBytes(ierr.Bytes), formatBytes(ierr.Size), 100*float64(ierr.Bytes)/float64(ierr.Size), ierr.Rows)
+	} else {
+		log.Printf("partial results: %s after %s, %d rows", reason, formatBytes(ierr.Bytes), ierr.Rows)
+	}
+	return status
+}
+
+// printMalformed 输出-report-malformed跳过的行的汇总
+func printMalformed(w io.Writer, m *brc.Malformed) {
+	fmt.Fprintf(w, "skipped %d malformed lines", m.Count)
+	if int64(len(m.Lines)) < m.Count {
+		fmt.Fprintf(w, ", the first %d", len(m.Lines))
+	}
+	fmt.Fprintln(w, ":")
+	for _, err := range m.Lines {
+		fmt.Fprintf(w, "  %v\n", err)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"log"
+	"os"
+	"runtime/pprof"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var flamegraph = flag.String("flamegraph", "", "profile the CPU like -cpuprofile and render the profile as a flame graph SVG to `file`")
+
+// startCPUProfile 按-cpuprofile和-flamegraph开始CPU profile，返回的函数停止profile并写出火焰图
+func startCPUProfile() (stop func()) {
+	if *cpuprofile == "" && *flamegraph == "" {
+		return func() {}
+	}
+	var w []io.Writer
+	var f *os.File
+	if *cpuprofile != "" {
+		var err error
+		f, err = os.Create(*cpuprofile) // ignore_security_alert
+		if err != nil {
+			log.Fatal("could not create CPU profile: ", err)
+		}
+		w = append(w, f)
+	}
+	buf := &bytes.Buffer{}
+	if *flamegraph != "" {
+		w = append(w, buf)
+	}
+	if err := pprof.StartCPUProfile(io.MultiWriter(w...)); err != nil {
+		log.Fatal("could not start CPU profile: ", err)
+	}
+	return func() {
+		pprof.StopCPUProfile()
+		if f != nil {
+			f.Close() // error handling omitted for example
+		}
+		if *flamegraph != "" {
+			check(writeFileAtomic(*flamegraph, func(w io.Writer) error {
+				return writeFlamegraph(w, buf.Bytes())
+			}))
+		}
+	}
+}
+
+// stackSample 是profile中的一个调用栈及其取值，stack从最外层的函数开始
+type stackSample struct {
+	stack []string
+	value int64
+}
+
+// parseProfile 解析pprof格式（可能经过gzip压缩的protobuf）的profile，
+// 取值使用最后一种样本类型，CPU profile中即CPU纳秒数。内联的函数作为单独的一帧
+func parseProfile(data []byte) ([]stackSample, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	type sample struct {
+		locations []uint64
+		values    []int64
+	}
+	var samples []sample
+	locations := make(map[uint64][]uint64) // location id -> function ids，最内层的在前
+	functions := make(map[uint64]int64)    // function id -> 名字在strings中的下标
+	var strs []string
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 2: // Sample
+			var s sample
+			err := forEachField(v, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+				switch num {
+				case 1:
+					return appendPacked(&s.locations, typ, v, x, func(x uint64) uint64 { return x })
+				case 2:
+					return appendPacked(&s.values, typ, v, x, func(x uint64) int64 { return int64(x) })
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			samples = append(samples, s)
+		case 4: // Location
+			var id uint64
+			var funcs []uint64
+			err := forEachField(v, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+				switch {
+				case num == 1 && typ == protowire.VarintType:
+					id = x
+				case num == 4 && typ == protowire.BytesType:
+					return forEachField(v, func(num protowire.Number, typ protowire.Type, _ []byte, x uint64) error {
+						if num == 1 && typ == protowire.VarintType {
+							funcs = append(funcs, x)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			locations[id] = funcs
+		case 5: // Function
+			var id uint64
+			var name int64
+			err := forEachField(v, func(num protowire.Number, typ protowire.Type, _ []byte, x uint64) error {
+				switch {
+				case num == 1 && typ == protowire.VarintType:
+					id = x
+				case num == 2 && typ == protowire.VarintType:
+					name = int64(x)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			functions[id] = name
+		case 6: // string_table
+			strs = append(strs, string(v))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+
+	result := make([]stackSample, 0, len(samples))
+	for _, s := range samples {
+		if len(s.values) == 0 {
+			continue
+		}
+		var stack []string
+		for _, loc := range s.locations {
+			for _, fn := range locations[loc] {
+				name := "?"
+				if i := functions[fn]; i > 0 && i < int64(len(strs)) {
+					name = strs[i]
+				}
+				stack = append(stack, name)
+			}
+		}
+		slices.Reverse(stack)
+		result = append(result, stackSample{stack: stack, value: s.values[len(s.values)-1]})
+	}
+	return result, nil
+}
+
+// forEachField 依次对data中的每个protobuf字段调用f，v是长度前缀字段的内容，x是varint字段的值
+func forEachField(data []byte, f func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		var v []byte
+		var x uint64
+		switch typ {
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(data)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := f(num, typ, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendPacked 将打包或未打包的repeated varint字段追加到dst
+func appendPacked[T any](dst *[]T, typ protowire.Type, v []byte, x uint64, conv func(uint64) T) error {
+	if typ == protowire.VarintType {
+		*dst = append(*dst, conv(x))
+		return nil
+	}
+	for len(v) > 0 {
+		x, n := protowire.ConsumeVarint(v)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		*dst = append(*dst, conv(x))
+		v = v[n:]
+	}
+	return nil
+}
+
+// flameNode 是火焰图中的一帧，value包括所有子帧
+type flameNode struct {
+	name     string
+	value    int64
+	children []*flameNode
+}
+
+func (n *flameNode) child(name string) *flameNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &flameNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// depth 返回以n为根的树的层数
+func (n *flameNode) depth() int {
+	d := 0
+	for _, c := range n.children {
+		d = max(d, c.depth())
+	}
+	return d + 1
+}
+
+// buildFlameTree 合并相同的调用栈，同一层的帧按名字排序
+func buildFlameTree(samples []stackSample) *flameNode {
+	root := &flameNode{name: "all"}
+	for _, s := range samples {
+		n := root
+		n.value += s.value
+		for _, name := range s.stack {
+			n = n.child(name)
+			n.value += s.value
+		}
+	}
+	var sortChildren func(n *flameNode)
+	sortChildren = func(n *flameNode) {
+		slices.SortFunc(n.children, func(a, b *flameNode) int { return strings.Compare(a.name, b.name) })
+		for _, c := range n.children {
+			sortChildren(c)
+		}
+	}
+	sortChildren(root)
+	return root
+}
+
+const (
+	flameWidth       = 1200
+	flameFrameHeight = 16
+	flameMargin      = 10
+	flameTitleHeight = 30
+	// flameCharWidth 是12px等宽字体一个字符的大致宽度，用于截断帧内的函数名
+	flameCharWidth = 7
+)
+
+// writeFlamegraph 将pprof格式的profile渲染为SVG火焰图，根帧在最下方，帧的宽度与取值成正比
+func writeFlamegraph(w io.Writer, profile []byte) error {
+	samples, err := parseProfile(profile)
+	if err != nil {
+		return err
+	}
+	root := buildFlameTree(samples)
+	if root.value == 0 {
+		return errors.New("profile has no samples, the run may have been too short")
+	}
+	depth := root.depth()
+	height := flameTitleHeight + depth*flameFrameHeight + flameMargin
+	scale := float64(flameWidth-2*flameMargin) / float64(root.value)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<?xml version="1.0" standalone="no"?>
+<svg version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">
+<style>text { font-family: monospace; font-size: 12px; fill: #000; } rect { stroke: #fff; stroke-width: 0.5; }</style>
+<rect x="0" y="0" width="%d" height="%d" fill="#f8f8f8"/>
+<text x="%d" y="20" text-anchor="middle" style="font-size: 16px">CPU Flame Graph (%s)</text>
+`, flameWidth, height, flameWidth, height, flameWidth, height, flameWidth/2, formatNanos(root.value))
+	var draw func(n *flameNode, x float64, level int)
+	draw = func(n *flameNode, x float64, level int) {
+		width := float64(n.value) * scale
+		// 过窄的帧看不清，也不再绘制其子帧
+		if width < 0.1 {
+			return
+		}
+		y := height - flameMargin - (level+1)*flameFrameHeight
+		fmt.Fprintf(buf, "<g><title>%s (%s, %.2f%%)</title><rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\"/>",
+			html.EscapeString(n.name), formatNanos(n.value), 100*float64(n.value)/float64(root.value),
+			x, y, width, flameFrameHeight-1, flameColor(n.name))
+		if chars := int(width-6) / flameCharWidth; chars >= 3 {
+			label := n.name
+			if len(label) > chars {
+				label = label[:chars-2] + ".."
+			}
+			fmt.Fprintf(buf, "<text x=\"%.1f\" y=\"%d\">%s</text>", x+3, y+flameFrameHeight-4, html.EscapeString(label))
+		}
+		buf.WriteString("</g>\n")
+		for _, c := range n.children {
+			draw(c, x, level+1)
+			x += float64(c.value) * scale
+		}
+	}
+	draw(root, flameMargin, 0)
+	buf.WriteString("</svg>\n")
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// flameColor 根据函数名返回一个固定的暖色，相同的函数在不同的位置颜色相同
+func flameColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	v := h.Sum32()
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+v%50, 80+(v>>8)%150, 40+(v>>16)%50)
+}
+
+// formatNanos 将纳秒数格式化为毫秒或秒
+func formatNanos(ns int64) string {
+	if ns < 1e9 {
+		return fmt.Sprintf("%.1fms", float64(ns)/1e6)
+	}
+	return fmt.Sprintf("%.2fs", float64(ns)/1e9)
+}
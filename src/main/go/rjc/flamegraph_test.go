@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testProfile 编码一个包含main->parse->hash和main->merge两个调用栈的profile，
+// parse和hash在同一个location中（hash内联到parse）
+func testProfile() []byte {
+	message := func(fields ...func([]byte) []byte) []byte {
+		var b []byte
+		for _, f := range fields {
+			b = f(b)
+		}
+		return b
+	}
+	varint := func(num protowire.Number, v uint64) func([]byte) []byte {
+		return func(b []byte) []byte {
+			return protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), v)
+		}
+	}
+	bytesField := func(num protowire.Number, v []byte) func([]byte) []byte {
+		return func(b []byte) []byte {
+			return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), v)
+		}
+	}
+	packed := func(num protowire.Number, vs ...uint64) func([]byte) []byte {
+		var p []byte
+		for _, v := range vs {
+			p = protowire.AppendVarint(p, v)
+		}
+		return bytesField(num, p)
+	}
+	line := func(fn uint64) func([]byte) []byte { return bytesField(4, message(varint(1, fn))) }
+	var b []byte
+	for _, s := range []string{"", "main", "parse", "hash", "merge"} {
+		b = bytesField(6, []byte(s))(b)
+	}
+	for id := uint64(1); id <= 4; id++ {
+		b = bytesField(5, message(varint(1, id), varint(2, id)))(b)
+	}
+	b = bytesField(4, message(varint(1, 1), line(1)))(b)
+	b = bytesField(4, message(varint(1, 2), line(3), line(2)))(b)
+	b = bytesField(4, message(varint(1, 3), line(4)))(b)
+	b = bytesField(2, message(packed(1, 2, 1), packed(2, 3, 30e6)))(b)
+	b = bytesField(2, message(packed(1, 3, 1), packed(2, 1, 10e6)))(b)
+	return b
+}
+
+func TestParseProfile(t *testing.T) {
+	samples, err := parseProfile(testProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []stackSample{
+		{stack: []string{"main", "parse", "hash"}, value: 30e6},
+		{stack: []string{"main", "merge"}, value: 10e6},
+	}
+	if !reflect.DeepEqual(samples, expected) {
+		t.Errorf("expected %v, got %v", expected, samples)
+	}
+
+	root := buildFlameTree(samples)
+	if root.value != 40e6 || root.depth() != 4 || len(root.children) != 1 {
+		t.Fatalf("unexpected tree %+v", root)
+	}
+	if main := root.children[0]; len(main.children) != 2 || main.children[0].name != "merge" || main.children[1].value != 30e6 {
+		t.Errorf("unexpected children of main %+v", main.children)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeFlamegraph(buf, testProfile()); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"<svg", "<title>hash (30.0ms, 75.00%)</title>", "<title>merge (10.0ms, 25.00%)</title>", "</svg>\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in the flame graph", s)
+		}
+	}
+}
+
+func TestParseRuntimeProfile(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		t.Skip("cpu profiling unavailable:", err)
+	}
+	pprof.StopCPUProfile()
+	if _, err := parseProfile(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFlamegraph(&bytes.Buffer{}, buf.Bytes()); err == nil || !strings.Contains(err.Error(), "no samples") {
+		t.Errorf("expected no samples error, got %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var follow = flag.Bool("follow", false, "keep reading the input file as it grows like tail -f and periodically output updated results until interrupted")
+var followInterval = flag.Duration("follow-interval", 5*time.Second, "`interval` between outputs in -follow mode")
+
+// followInput 持续聚合path中追加的数据，每隔-follow-interval输出一次，收到SIGINT或SIGTERM时输出最终结果后返回
+func followInput(path string, opts []brc.Option) {
+	if path == "-" {
+		fatalUsage("-follow requires an input file")
+	}
+	if *checkpoint != "" {
+		fatalUsage("-follow cannot be used with -checkpoint")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	check(brc.Follow(ctx, path, *followInterval, writeOutput, opts...))
+}
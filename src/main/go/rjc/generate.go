@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+type weatherStation struct {
+	name string
+	mean float64
+}
+
+// 从官方站点列表中按seed随机选取n个站点，超过413个时在站点名后追加序号生成新站点
+func pickStations(r *rand.Rand, n int) []weatherStation {
+	stations := make([]weatherStation, 0, n)
+	for _, i := range r.Perm(len(weatherStations)) {
+		if len(stations) == n {
+			break
+		}
+		stations = append(stations, weatherStations[i])
+	}
+	for i := len(stations); i < n; i++ {
+		base := weatherStations[r.Intn(len(weatherStations))]
+		stations = append(stations, weatherStation{
+			name: base.name + " " + strconv.Itoa(i),
+			mean: base.mean,
+		})
+	}
+	return stations
+}
+
+// 与官方生成器一致：以站点平均温度为均值的正态分布，保留一位小数，并限制在[-99.9, 99.9]
+func writeMeasurements(w io.Writer, r *rand.Rand, stations []weatherStation, rows int64, stddev float64) error {
+	bw := bufio.NewWriterSize(w, 1024*1024)
+	line := make([]byte, 0, 128)
+	for i := int64(0); i < rows; i++ {
+		station := stations[r.Intn(len(stations))]
+		t := math.Round((r.NormFloat64()*stddev+station.mean)*10) / 10
+		t = max(-99.9, min(99.9, t))
+
+		line = append(line[:0], station.name...)
+		line = append(line, ';')
+		line = strconv.AppendFloat(line, t, 'f', 1, 64)
+		line = append(line, '\n')
+		if _, err := bw.Write(line); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// brc generate [-rows N] [-stations N] [-seed N] [-stddev N] [-out file]
+func generate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs.Usage = func() {
+		commandUsage(fs, "generate")
+	}
+	rows := fs.Int64("rows", 1_000_000_000, "number of `rows` to generate")
+	numStations := fs.Int("stations", len(weatherStations), "number of distinct `stations`")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "random `seed`, fixed seeds produce identical files")
+	stddev := fs.Float64("stddev", 10, "standard `deviation` of the temperature around each station's mean")
+	out := fs.String("out", "measurements.txt", "write measurements to `file`, - for stdout")
+	parseFlags(fs, args)
+
+	if *rows < 0 || *numStations <= 0 {
+		fatalUsage("invalid -rows %d or -stations %d", *rows, *numStations)
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		check(err)
+		defer f.Close()
+		w = f
+	}
+
+	start := time.Now()
+	r := rand.New(rand.NewSource(*seed))
+	stations := pickStations(r, *numStations)
+	check(writeMeasurements(w, r, stations, *rows, *stddev))
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "Created file with %d measurements in %s\n", *rows, time.Since(start))
+	}
+}
@@ -1,3 +1,18 @@
 module github.com/hyperchao/1brc
 
-go 1.21.6
+go 1.22
+
+require (
+	github.com/google/flatbuffers v25.2.10+incompatible
+	github.com/klauspost/compress v1.18.0
+	github.com/mattn/go-sqlite3 v1.14.33
+	golang.org/x/sys v0.30.0
+	golang.org/x/text v0.17.0
+	google.golang.org/grpc v1.67.1
+	google.golang.org/protobuf v1.35.2
+)
+
+require (
+	golang.org/x/net v0.28.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+)
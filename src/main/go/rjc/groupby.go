@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// loadMetadata 读取-metadata，未指定时返回nil
+var loadMetadata = sync.OnceValues(func() (*brc.Metadata, error) {
+	if *metadata == "" {
+		return nil, nil
+	}
+	file, err := os.Open(*metadata)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	m, err := brc.ReadMetadata(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", *metadata, err)
+	}
+	return m, nil
+})
+
+// groupsByMetadata 判断-group-by是否按元数据的列聚合
+func groupsByMetadata() bool {
+	return strings.HasPrefix(*groupBy, "metadata:")
+}
+
+// parseGroupBy 解析-group-by，格式为prefix:N、regex:<pattern>、map:<file>或metadata:<column>
+func parseGroupBy(spec string) (brc.GroupBy, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "prefix":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return brc.GroupBy{}, fmt.Errorf("prefix length must be a positive integer, got %q", arg)
+		}
+		return brc.GroupByPrefix(n), nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return brc.GroupBy{}, err
+		}
+		return brc.GroupByRegex(re), nil
+	case "map":
+		file, err := os.Open(arg)
+		if err != nil {
+			return brc.GroupBy{}, err
+		}
+		defer file.Close()
+		mapping, err := brc.ReadGroupMap(file)
+		if err != nil {
+			return brc.GroupBy{}, fmt.Errorf("%s: %w", arg, err)
+		}
+		return brc.GroupByMap(mapping), nil
+	case "metadata":
+		m, err := loadMetadata()
+		if err != nil {
+			return brc.GroupBy{}, err
+		}
+		if m == nil {
+			return brc.GroupBy{}, errors.New("metadata:<column> requires -metadata")
+		}
+		return m.GroupBy(arg)
+	default:
+		return brc.GroupBy{}, fmt.Errorf("unknown grouping %q, want prefix:N, regex:<pattern>, map:<file> or metadata:<column>", spec)
+	}
+}
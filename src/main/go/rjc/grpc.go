@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+	"github.com/hyperchao/1brc/pkg/brcpb"
+)
+
+var grpcAddr = flag.String("grpc-addr", "", "after processing, serve the results over gRPC on `addr` until interrupted")
+
+// queryServer 实现brcpb.QueryServer，每次请求通过results获取当前的结果
+type queryServer struct {
+	brcpb.UnimplementedQueryServer
+	results func() brc.Results
+	// complete 为false表示结果仍在变化，如serve模式
+	complete bool
+}
+
+func toStation(s *brc.Station) *brcpb.Station {
+	return &brcpb.Station{
+		Name:  s.Name,
+		Min:   float64(s.Min) / 10,
+		Mean:  float64(s.Sum) / float64(s.Count) / 10,
+		Max:   float64(s.Max) / 10,
+		Count: int64(s.Count),
+	}
+}
+
+func (q *queryServer) GetStation(_ context.Context, req *brcpb.GetStationRequest) (*brcpb.Station, error) {
+	results := q.results()
+	i := sort.Search(len(results), func(i int) bool { return results[i].Name >= req.Name })
+	if i == len(results) || results[i].Name != req.Name {
+		return nil, status.Errorf(codes.NotFound, "station %q not found", req.Name)
+	}
+	return toStation(&results[i]), nil
+}
+
+func (q *queryServer) ListStations(_ context.Context, req *brcpb.ListStationsRequest) (*brcpb.ListStationsResponse, error) {
+	results := q.results()
+	resp := &brcpb.ListStationsResponse{}
+	for i := sort.Search(len(results), func(i int) bool { return results[i].Name >= req.Prefix }); i < len(results); i++ {
+		if !strings.HasPrefix(results[i].Name, req.Prefix) {
+			break
+		}
+		resp.Stations = append(resp.Stations, toStation(&results[i]))
+	}
+	return resp, nil
+}
+
+func (q *queryServer) GetSummary(context.Context, *brcpb.GetSummaryRequest) (*brcpb.Summary, error) {
+	results := q.results()
+	summary := &brcpb.Summary{Stations: int64(len(results)), Complete: q.complete}
+	if len(results) == 0 {
+		return summary, nil
+	}
+	total := brc.M{Min: results[0].Min, Max: results[0].Max}
+	for i := range results {
+		total.Merge(&results[i].M)
+	}
+	summary.Rows = int64(total.Count)
+	summary.Min = float64(total.Min) / 10
+	summary.Max = float64(total.Max) / 10
+	summary.Mean = float64(total.Sum) / float64(total.Count) / 10
+	return summary, nil
+}
+
+// serveGRPC 在addr上提供查询接口，直到监听出错
+func serveGRPC(addr string, q *queryServer) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	brcpb.RegisterQueryServer(s, q)
+	log.Printf("serving gRPC queries on %s", ln.Addr())
+	return s.Serve(ln)
+}
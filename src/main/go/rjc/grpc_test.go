@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+	"github.com/hyperchao/1brc/pkg/brcpb"
+)
+
+func TestQueryServer(t *testing.T) {
+	s := brc.NewStatistic()
+	s.ParseAndAddLines([]byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nHalifax;1.0\n"))
+	q := &queryServer{results: s.Results, complete: true}
+	ctx := context.Background()
+
+	st, err := q.GetStation(ctx, &brcpb.GetStationRequest{Name: "Hamburg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Min != -3.4 || st.Max != 12.0 || st.Count != 2 {
+		t.Errorf("unexpected station %v", st)
+	}
+	if _, err := q.GetStation(ctx, &brcpb.GetStationRequest{Name: "Oslo"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	list, err := q.ListStations(ctx, &brcpb.ListStationsRequest{Prefix: "Ha"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Stations) != 2 || list.Stations[0].Name != "Halifax" || list.Stations[1].Name != "Hamburg" {
+		t.Errorf("unexpected stations %v", list.Stations)
+	}
+
+	summary, err := q.GetSummary(ctx, &brcpb.GetSummaryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Stations != 3 || summary.Rows != 4 || summary.Min != -3.4 || summary.Max != 12.0 || !summary.Complete {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
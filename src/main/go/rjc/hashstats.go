@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var hashStats = flag.Bool("hash-stats", false, "print hash table probe and collision statistics to stderr, to compare -hash functions")
+
+// printHashStats 打印每次查找平均越过的槽位数和哈希值完全相同的冲突次数
+func printHashStats(w io.Writer, stats brc.HashStats) {
+	name := *hash
+	if name == "" {
+		name = string(brc.HashFNV)
+	}
+	probes := 0.0
+	if stats.Lookups > 0 {
+		probes = float64(stats.Probes) / float64(stats.Lookups)
+	}
+	fmt.Fprintf(w, "hash %s: %d stations, %d lookups, %.4f probes/lookup, %d collisions\n",
+		name, stats.Stations, stats.Lookups, probes, stats.Collisions)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func printCardinality(w io.Writer, c *brc.Cardinality) {
+	fmt.Fprintf(w, "rows: %d\n", c.Rows)
+	fmt.Fprintf(w, "distinct stations: ~%d\n", c.Distinct)
+	if len(c.Top) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "most frequent:\n")
+	width := 0
+	for _, n := range c.Top {
+		width = max(width, len(n.Name))
+	}
+	for _, n := range c.Top {
+		fmt.Fprintf(w, "  %-*s %d\n", width, n.Name, n.Count)
+	}
+}
+
+// brc inspect -cardinality [-top N] [-workers N] [file]
+func inspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		commandUsage(fs, "inspect")
+	}
+	cardinality := fs.Bool("cardinality", false, "report the approximate number of distinct stations (HyperLogLog) and the most frequent names without aggregating")
+	top := fs.Int("top", 10, "number of most frequent station names to list")
+	workers := fs.Int("workers", 0, "number of parallel `workers`, 0 means min(8, NumCPU)")
+	parseFlags(fs, args)
+
+	if !*cardinality {
+		fatalUsage("inspect requires -cardinality")
+	}
+	if *top < 0 {
+		fatalUsage("invalid -top %d", *top)
+	}
+	path := "measurements.txt"
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	path, err := filepath.Abs(path)
+	check(err)
+	c, err := brc.Inspect(path, *top, brc.WithWorkers(*workers))
+	checkProcessError(path, err)
+	printCardinality(os.Stdout, c)
+}
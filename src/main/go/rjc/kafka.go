@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy/xerial"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 使用的Kafka API及其版本，Kafka 4.0仍然支持这些版本
+const (
+	kafkaFetch       int16 = 1
+	kafkaListOffsets int16 = 2
+	kafkaMetadata    int16 = 3
+
+	kafkaFetchVersion       int16 = 4
+	kafkaListOffsetsVersion int16 = 1
+	kafkaMetadataVersion    int16 = 1
+)
+
+// ListOffsets的特殊时间戳
+const (
+	kafkaLatest   int64 = -1
+	kafkaEarliest int64 = -2
+)
+
+// kafkaError 是响应中非0的错误码，除kafkaOffsetOutOfRange外都通过重新获取metadata重试
+type kafkaError int16
+
+// kafkaOffsetOutOfRange 表示请求的offset已被删除或尚不存在
+const kafkaOffsetOutOfRange kafkaError = 1
+
+func (e kafkaError) Error() string {
+	return "kafka error code " + strconv.Itoa(int(e))
+}
+
+// kafkaEncoder 按Kafka协议的大端格式编码请求
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *kafkaEncoder) int16(v int16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+}
+
+func (e *kafkaEncoder) int32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+func (e *kafkaEncoder) int64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// kafkaDecoder 解码响应，第一个错误之后的读取都返回零值
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+var errKafkaShort = errors.New("kafka: short response")
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.b) {
+		if d.err == nil {
+			d.err = errKafkaShort
+		}
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string 读取int16长度的字符串，长度为-1的null读取为空字符串
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// bytes 读取int32长度的字节数组，null为nil
+func (d *kafkaDecoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// varint 读取zigzag编码的变长整数
+func (d *kafkaDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errKafkaShort
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// varbytes 读取varint长度的字节数组，null为nil
+func (d *kafkaDecoder) varbytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// kafkaConn 是到一个broker的连接，请求依次发送，不并发
+type kafkaConn struct {
+	conn        net.Conn
+	correlation int32
+}
+
+func dialKafka(addr string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{conn: conn}, nil
+}
+
+func (c *kafkaConn) Close() error {
+	return c.conn.Close()
+}
+
+// roundTrip 发送请求并返回去掉响应头后的响应体
+func (c *kafkaConn) roundTrip(api, version int16, body []byte, timeout time.Duration) (*kafkaDecoder, error) {
+	c.correlation++
+	e := &kafkaEncoder{buf: make([]byte, 4, 64+len(body))}
+	e.int16(api)
+	e.int16(version)
+	e.int32(c.correlation)
+	e.string("brc")
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := c.conn.Write(e.buf); err != nil {
+		return nil, err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+	d := &kafkaDecoder{b: resp}
+	if id := d.int32(); id != c.correlation {
+		return nil, fmt.Errorf("kafka: unexpected correlation id %d, want %d", id, c.correlation)
+	}
+	return d, d.err
+}
+
+// kafkaPartition 是topic的一个分区及其leader的地址
+type kafkaPartition struct {
+	id     int32
+	leader string
+}
+
+// metadata 返回topic的所有分区，分区没有leader时leader为空
+func (c *kafkaConn) metadata(topic string) ([]kafkaPartition, error) {
+	e := &kafkaEncoder{}
+	e.int32(1)
+	e.string(topic)
+	d, err := c.roundTrip(kafkaMetadata, kafkaMetadataVersion, e.buf, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	brokers := make(map[int32]string)
+	for i := d.int32(); i > 0; i-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller_id
+	var partitions []kafkaPartition
+	for i := d.int32(); i > 0; i-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // is_internal
+		if code != 0 && d.err == nil {
+			return nil, fmt.Errorf("topic %s: %w", name, kafkaError(code))
+		}
+		for j := d.int32(); j > 0; j-- {
+			d.int16() // 分区的错误码，没有leader时由leader_id体现
+			p := kafkaPartition{id: d.int32()}
+			p.leader = brokers[d.int32()]
+			for k := d.int32(); k > 0; k-- {
+				d.int32() // replica_nodes
+			}
+			for k := d.int32(); k > 0; k-- {
+				d.int32() // isr_nodes
+			}
+			partitions = append(partitions, p)
+		}
+	}
+	return partitions, d.err
+}
+
+// listOffset 返回分区在timestamp（kafkaEarliest或kafkaLatest）处的offset
+func (c *kafkaConn) listOffset(topic string, partition int32, timestamp int64) (int64, error) {
+	e := &kafkaEncoder{}
+	e.int32(-1) // replica_id
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.int64(timestamp)
+	d, err := c.roundTrip(kafkaListOffsets, kafkaListOffsetsVersion, e.buf, 30*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	for i := d.int32(); i > 0; i-- {
+		d.string()
+		for j := d.int32(); j > 0; j-- {
+			id := d.int32()
+			code := d.int16()
+			d.int64() // timestamp
+			offset := d.int64()
+			if d.err == nil && id == partition {
+				if code != 0 {
+					return 0, kafkaError(code)
+				}
+				return offset, nil
+			}
+		}
+	}
+	return 0, cmp.Or[error](d.err, fmt.Errorf("kafka: partition %d missing from ListOffsets response", partition))
+}
+
+// fetch 从offset开始读取分区的record batch，没有新数据时broker最多等待maxWait
+func (c *kafkaConn) fetch(topic string, partition int32, offset int64, maxBytes int32, maxWait time.Duration) ([]byte, error) {
+	e := &kafkaEncoder{}
+	e.int32(-1) // replica_id
+	e.int32(int32(maxWait / time.Millisecond))
+	e.int32(1) // min_bytes
+	e.int32(maxBytes)
+	e.int8(0) // isolation_level: read_uncommitted
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.int64(offset)
+	e.int32(maxBytes)
+	d, err := c.roundTrip(kafkaFetch, kafkaFetchVersion, e.buf, maxWait+30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	d.int32() // throttle_time_ms
+	for i := d.int32(); i > 0; i-- {
+		d.string()
+		for j := d.int32(); j > 0; j-- {
+			id := d.int32()
+			code := d.int16()
+			d.int64() // high_watermark
+			d.int64() // last_stable_offset
+			for k := d.int32(); k > 0; k-- {
+				d.int64() // producer_id
+				d.int64() // first_offset
+			}
+			records := d.bytes()
+			if d.err == nil && id == partition {
+				if code != 0 {
+					return nil, kafkaError(code)
+				}
+				return records, nil
+			}
+		}
+	}
+	return nil, cmp.Or[error](d.err, fmt.Errorf("kafka: partition %d missing from Fetch response", partition))
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// zstd解码器可以并发使用
+var kafkaZstd, _ = zstd.NewReader(nil)
+
+// decodeRecords 解析Fetch返回的record batch（magic 2），对offset不小于from的每条记录调用f，
+// 返回下一次Fetch的offset。末尾被截断的batch留给下一次Fetch，control batch只推进offset
+func decodeRecords(data []byte, from int64, f func(value []byte)) (int64, error) {
+	next := from
+	for len(data) >= 12 {
+		base := int64(binary.BigEndian.Uint64(data))
+		length := int(binary.BigEndian.Uint32(data[8:]))
+		if 12+length > len(data) {
+			break
+		}
+		d := &kafkaDecoder{b: data[12 : 12+length]}
+		data = data[12+length:]
+		d.int32() // partition_leader_epoch
+		if magic := d.int8(); magic != 2 && d.err == nil {
+			return next, fmt.Errorf("kafka: unsupported record batch magic %d", magic)
+		}
+		crc := uint32(d.int32())
+		if d.err == nil && crc32.Checksum(d.b, castagnoli) != crc {
+			return next, fmt.Errorf("kafka: record batch at offset %d has a bad checksum", base)
+		}
+		attributes := d.int16()
+		lastOffsetDelta := d.int32()
+		d.next(8 + 8 + 8 + 2 + 4) // base_timestamp, max_timestamp, producer_id, producer_epoch, base_sequence
+		count := d.int32()
+		if d.err != nil {
+			return next, d.err
+		}
+		next = max(next, base+int64(lastOffsetDelta)+1)
+		if attributes&0x20 != 0 {
+			continue
+		}
+		records, err := decompressRecords(attributes&0x7, d.b)
+		if err != nil {
+			return next, fmt.Errorf("kafka: record batch at offset %d: %w", base, err)
+		}
+		rd := &kafkaDecoder{b: records}
+		for i := int32(0); i < count && rd.err == nil; i++ {
+			r := &kafkaDecoder{b: rd.varbytes()}
+			r.int8()   // attributes
+			r.varint() // timestamp_delta
+			offset := base + r.varint()
+			r.varbytes() // key
+			value := r.varbytes()
+			if r.err == nil && offset >= from && len(value) > 0 {
+				f(value)
+			}
+		}
+		if rd.err != nil {
+			return next, fmt.Errorf("kafka: record batch at offset %d: %w", base, rd.err)
+		}
+	}
+	return next, nil
+}
+
+// decompressRecords 按batch属性的低3位解压records
+func decompressRecords(codec int16, records []byte) ([]byte, error) {
+	switch codec {
+	case 0:
+		return records, nil
+	case 1:
+		zr, err := gzip.NewReader(bytes.NewReader(records))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(zr)
+	case 2:
+		return xerial.Decode(records)
+	case 4:
+		return kafkaZstd.DecodeAll(records, nil)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %d, want none, gzip, snappy or zstd", codec)
+	}
+}
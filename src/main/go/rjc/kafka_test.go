@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// recordBatch 编码magic 2的record batch，codec为1时用gzip压缩
+func recordBatch(base int64, codec int16, control bool, values ...string) []byte {
+	var records []byte
+	for i, v := range values {
+		var r []byte
+		r = append(r, 0)                     // attributes
+		r = binary.AppendVarint(r, 0)        // timestamp_delta
+		r = binary.AppendVarint(r, int64(i)) // offset_delta
+		r = binary.AppendVarint(r, -1)       // key
+		r = binary.AppendVarint(r, int64(len(v)))
+		r = append(r, v...)
+		r = binary.AppendVarint(r, 0) // headers
+		records = binary.AppendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+	if codec == 1 {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		zw.Write(records)
+		zw.Close()
+		records = buf.Bytes()
+	}
+	attributes := codec
+	if control {
+		attributes |= 0x20
+	}
+	e := &kafkaEncoder{}
+	e.int16(attributes)
+	e.int32(int32(len(values) - 1))
+	e.buf = append(e.buf, make([]byte, 8+8+8+2+4)...)
+	e.int32(int32(len(values)))
+	e.buf = append(e.buf, records...)
+	body := e.buf
+
+	h := &kafkaEncoder{}
+	h.int64(base)
+	h.int32(int32(4 + 1 + 4 + len(body)))
+	h.int32(0) // partition_leader_epoch
+	h.int8(2)
+	h.int32(int32(crc32.Checksum(body, castagnoli)))
+	return append(h.buf, body...)
+}
+
+func TestDecodeRecords(t *testing.T) {
+	data := append(recordBatch(10, 0, false, "a;1.0", "b;2.0\nc;3.0\n"), recordBatch(12, 1, false, "d;4.0")...)
+	data = append(data, recordBatch(13, 0, true, "commit")...)
+	full := recordBatch(14, 0, false, "e;5.0")
+	data = append(data, full[:len(full)-1]...)
+
+	var values []string
+	next, err := decodeRecords(data, 11, func(v []byte) { values = append(values, string(v)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"b;2.0\nc;3.0\n", "d;4.0"}; !reflect.DeepEqual(values, expected) || next != 14 {
+		t.Errorf("expected %q up to 14, got %q up to %d", expected, values, next)
+	}
+
+	bad := recordBatch(0, 0, false, "a;1.0")
+	bad[len(bad)-2] ^= 1
+	if _, err := decodeRecords(bad, 0, func([]byte) {}); err == nil {
+		t.Error("expected checksum error")
+	}
+}
+
+// fakeBroker 处理一个连接上的Metadata、ListOffsets和Fetch请求，分区0的offset 0处有batch，
+// 读到末尾时关闭连接
+func fakeBroker(ln net.Listener, batch []byte, end int64) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		io.ReadFull(conn, req)
+		d := &kafkaDecoder{b: req}
+		api, _, corr := d.int16(), d.int16(), d.int32()
+		d.string()
+		e := &kafkaEncoder{buf: make([]byte, 4)}
+		e.int32(corr)
+		switch api {
+		case kafkaMetadata:
+			e.int32(1)
+			e.int32(1)
+			e.string(host)
+			e.int32(int32(portNum))
+			e.int16(-1)
+			e.int32(1) // controller_id
+			e.int32(1)
+			e.int16(0)
+			e.string("measurements")
+			e.int8(0)
+			e.int32(1)
+			e.int16(0)
+			e.int32(0) // partition
+			e.int32(1) // leader
+			e.int32(0)
+			e.int32(0)
+		case kafkaListOffsets:
+			e.int32(1)
+			e.string("measurements")
+			e.int32(1)
+			e.int32(0)
+			e.int16(0)
+			e.int64(-1)
+			e.int64(0)
+		case kafkaFetch:
+			d.next(4 + 4 + 4 + 4 + 1 + 4)
+			d.string()
+			d.next(4 + 4)
+			if d.int64() >= end {
+				return
+			}
+			e.int32(0)
+			e.int32(1)
+			e.string("measurements")
+			e.int32(1)
+			e.int32(0)
+			e.int16(0)
+			e.int64(end)
+			e.int64(end)
+			e.int32(-1)
+			e.int32(int32(len(batch)))
+			e.buf = append(e.buf, batch...)
+		}
+		binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+		conn.Write(e.buf)
+	}
+}
+
+func TestConsumePartition(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		batch := recordBatch(0, 0, false, "Hamburg;12.0", "Bulawayo;8.9\nHamburg;-3.4")
+		// 第一个连接读取metadata，第二个连接到leader读取数据
+		fakeBroker(ln, nil, 0)
+		fakeBroker(ln, batch, 2)
+	}()
+
+	c := &consumer{brokers: []string{ln.Addr().String()}, topic: "measurements", start: kafkaEarliest}
+	c.agg.Store(brc.NewAggregator(4))
+	offset := int64(-1)
+	if err := c.fetchFromLeader(0, &offset); err == nil {
+		t.Fatal("expected the broker to close the connection")
+	}
+	if offset != 2 {
+		t.Errorf("expected offset 2, got %d", offset)
+	}
+	buf := &strings.Builder{}
+	writeWindow(buf, c.agg.Load().Results(), "text")
+	if expected := "{Bulawayo=8.9/8.9/8.9, Hamburg=-3.4/4.3/12.0}\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestConsumerSwap(t *testing.T) {
+	c := &consumer{}
+	c.agg.Store(brc.NewAggregator(4))
+	const writers, batches = 4, 200
+	done := make(chan struct{})
+	for i := 0; i < writers; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < batches; j++ {
+				c.add([]byte("a;1.0\nb;2.0\n"))
+			}
+		}()
+	}
+	// 每个窗口输出时已经包含所有写入它的批次，各窗口的行数之和等于写入的总行数
+	var rows int64
+	for finished := 0; finished < writers; {
+		select {
+		case <-done:
+			finished++
+		default:
+			rows += c.swap(brc.NewAggregator(4)).Rows()
+		}
+	}
+	rows += c.swap(brc.NewAggregator(4)).Rows()
+	if rows != 2*writers*batches {
+		t.Errorf("expected %d rows over all windows, got %d", 2*writers*batches, rows)
+	}
+}
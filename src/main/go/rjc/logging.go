@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+)
+
+var verbose = flag.Bool("verbose", false, "log per-batch timings, worker utilization and merge statistics to stderr")
+var quiet = flag.Bool("quiet", false, "only output the result: no progress, summaries or warnings on stderr, only fatal errors")
+
+// setupLogging 按-verbose和-quiet设置slog的默认Logger，log包的输出作为错误记录
+func setupLogging() {
+	level := slog.LevelInfo
+	switch {
+	case *verbose && *quiet:
+		fatalUsage("-verbose cannot be used with -quiet")
+	case *verbose:
+		level = slog.LevelDebug
+	case *quiet:
+		level = slog.LevelError
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	slog.SetLogLoggerLevel(slog.LevelError)
+}
@@ -1,257 +1,423 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"math"
+	"net/http"
+	_ "net/http/pprof"
 	"os"
-	"runtime"
-	"runtime/pprof"
-	"sort"
-	"sync"
-	"unsafe"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
 )
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
-var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
-
-func pie(e error) {
-	if e != nil {
-		panic(e)
-	}
-}
+var memprofile = flag.String("memprofile", "", "write heap profile to `file` when the run finishes")
+var traceFile = flag.String("trace", "", "write execution trace to `file`, inspect with go tool trace")
+var pprofAddr = flag.String("pprof-addr", "", "serve net/http/pprof on `addr` while running, e.g. :6060")
+var engine = flag.String("engine", "", "input `engine`: scanner, mmap, chunk, io_uring or parquet, defaults to parquet for Parquet files and chunk for other regular files")
+var workers = flag.Int("workers", 0, "number of parallel `workers`, 0 means min(8, NumCPU)")
+var compression = flag.String("compression", "", "input `compression`: none, gzip or zstd, detected from the file header by default")
+var strictSpec = flag.Bool("strict-spec", false, "like -strict, and also reject station names over 100 bytes or invalid UTF-8 and values outside [-99.9, 99.9]")
+var quoted = flag.Bool("quoted", false, "allow station names containing ';' when quoted (\"Foo;Bar\";12.3) or escaped (Foo\\;Bar;12.3)")
+var window = flag.Duration("window", 0, "read station;timestamp;value lines, timestamp in Unix seconds or RFC 3339, and aggregate per station and UTC-aligned time `window`, e.g. 1h; stations are output as name@window-start")
+var normalizeKeys = flag.Bool("normalize-keys", false, "NFC-normalize station names so composed and decomposed forms aggregate together; invalid UTF-8 is an error with -strict")
+var foldCase = flag.String("fold-case", "", "fold station name case before aggregating: `ascii` or unicode, so Tokyo and TOKYO merge")
+var strict = flag.Bool("strict", false, "validate every line against the 1BRC format and report the first invalid one")
+var percentiles = flag.String("percentiles", "", "comma separated `percentiles` to estimate per station, e.g. 50,90,99")
+var median = flag.Bool("median", false, "compute exact per-station medians, costs about 16KB per station and worker")
+var checkOverflow = flag.Bool("check-overflow", false, "fail instead of silently wrapping when a station's sum or sum of squares overflows int64")
+var checkpoint = flag.String("checkpoint", "", "periodically save progress to `file` so an interrupted run can be resumed")
+var checkpointInterval = flag.Duration("checkpoint-interval", time.Minute, "`interval` between checkpoints")
+var resume = flag.Bool("resume", false, "resume from the -checkpoint file if it exists")
+var filterPrefix = flag.String("filter-prefix", "", "only aggregate stations whose name starts with `prefix`")
+var filterRegex = flag.String("filter-regex", "", "only aggregate stations whose name matches `regexp`")
+var groupBy = flag.String("group-by", "", "aggregate stations into coarser `groups`: prefix:N (first N characters), regex:<pattern> (first capture group), map:<file> (station,group CSV) or metadata:<column> of -metadata")
+var metadata = flag.String("metadata", "", "station metadata CSV `file` with a header, e.g. station,country,lat,lon; its columns are added to csv and json output unless -group-by uses one of them")
+var maxMemory = flag.String("max-memory", "", "limit read buffers and map pre-allocation to `size`, e.g. 512MB, and use it as the GC memory limit")
+var hash = flag.String("hash", "", "station name `hash` for the per-worker tables: fnv, xxhash, wyhash or crc32c (default fnv)")
+var sharedTable = flag.Bool("shared-table", false, "have all workers insert into one lock-free hash table instead of merging per-worker tables")
+var timeout = flag.Duration("timeout", 0, "stop reading after `duration`, e.g. 5m, output the partial results and exit with status 7; 0 means no limit, not used with -follow")
+var dumpPartials = flag.String("dump-partials", "", "write each worker's batches and per-station min, max, sum and count to `dir`/worker-NNN.txt before merging, to bisect differences between parallel and reference runs")
+var raceSafe = flag.Bool("race-safe", false, "copy each batch before handing it to a worker so scanner buffers are never shared; use with a -race build")
+var hugePages = flag.Bool("huge-pages", false, "back the mmap engine's mapping with transparent huge pages where the kernel supports it")
+var numa = flag.Bool("numa", false, "partition the input by NUMA node and pin each node's workers to its CPUs, chunk and mmap engines only")
+var autoTune = flag.Bool("auto-tune", false, "try different worker counts and read sizes on the first part of the input and use the fastest for the rest, chunk and mmap engines only")
+var streamChunks = flag.Int("stream-chunks", 4, "when reading a pipe or FIFO, parse each `chunk` as soon as it holds complete lines and stop reading while this many are in flight, so memory stays flat however fast the producer is; 0 reads 64MB blocks as for files")
+var streamChunkSize = flag.String("stream-chunk-size", "1MB", "`size` of each in-flight chunk with -stream-chunks")
+var input = flag.String("input", "measurements.txt", "read measurements from `file`, may also be given as the first argument, - for stdin; s3://bucket/key, gs://bucket/object and http(s):// URLs are downloaded with parallel range requests, or streamed if the server does not support them")
 
-func UnsafeBytesToString(b []byte) string {
-	return unsafe.String(unsafe.SliceData(b), len(b))
+// 只被子命令使用的参数及其子命令
+var subcommandFlags = map[string]string{
+	"state":  "save",
+	"range":  "worker",
+	"report": "worker",
+	"shards": "coordinator",
+	"listen": "coordinator",
 }
 
-type Statistic struct {
-	keys     []byte
-	measures map[string]*M
+// checkSubcommandFlags 拒绝命令行中指定的其它子命令专用的参数。
+// 环境变量和-config中的这些参数可能是为那些子命令设置的，不检查
+func checkSubcommandFlags(cmdline map[string]bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if cmd, ok := subcommandFlags[f.Name]; ok && cmdline[f.Name] {
+			fatalUsage("-%s is only used by the %s subcommand", f.Name, cmd)
+		}
+	})
 }
 
-func newStatistic() *Statistic {
-	return &Statistic{
-		keys:     make([]byte, 0, 8*1024),
-		measures: make(map[string]*M),
-	}
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-func (s *Statistic) Add(nameBytes []byte, val int64) {
-	name := UnsafeBytesToString(nameBytes)
-	m, ok := s.measures[name]
-	if !ok {
-		s.keys = append(s.keys, nameBytes...)
-		name = UnsafeBytesToString(s.keys[len(s.keys)-len(name):])
-		m = newM()
-		s.measures[name] = m
-	}
-	m.Add(val)
+func stdinIsPipe() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
 }
 
-func (s *Statistic) ParseAndAddLines(lines []byte) {
-	for {
-		idx := bytes.IndexByte(lines, ';')
-		if idx < 0 {
-			return
-		}
-		val := int64(0)
-		neg := lines[idx+1] == '-'
-		i := idx + 1
-		for i < len(lines) {
-			if lines[i] == '\n' {
-				i++
-				break
-			}
-			if lines[i] >= '0' && lines[i] <= '9' {
-				val = val*10 + int64(lines[i]-'0')
-			}
-			i++
-		}
-		if neg {
-			val = -val
-		}
-		s.Add(lines[:idx], val)
-		lines = lines[i:]
+// isStreamInput 报告path是否是stdin、FIFO或其它不是普通文件的输入，只能从头到尾读取一次
+func isStreamInput(path string) bool {
+	if path == "-" {
+		return true
 	}
+	if brc.IsRemote(path) {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.Mode().IsRegular()
 }
 
-func (s *Statistic) PrintResult() {
-	printResult(s.measures)
-}
-
-type MergedStatistics struct {
-	keys     [][]byte
-	measures map[string]*M
+// 位置参数优先于-input，相对路径按当前工作目录解析；
+// 未指定输入且stdin是管道时从stdin读取，"-"表示stdin
+func inputPath() string {
+	path := *input
+	if flag.NArg() > 0 {
+		path = flag.Arg(0)
+	} else if !isFlagSet("input") && stdinIsPipe() {
+		path = "-"
+	}
+	if path == "-" || brc.IsRemote(path) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	check(err)
+	return abs
 }
 
-func mergeStatistics(slice ...*Statistic) *MergedStatistics {
-	r := &MergedStatistics{
-		measures: make(map[string]*M),
+// parseSize 解析带单位的字节数，如512MB、1GiB，单位不区分大小写，KB、MB、GB都按1024进位
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30},
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30},
+		{"b", 1},
 	}
-
-	for _, s := range slice {
-		r.keys = append(r.keys, s.keys)
-		for name, m := range s.measures {
-			m2, ok := r.measures[name]
-			if !ok {
-				r.measures[name] = m
-			} else {
-				m2.count += m.count
-				m2.sum += m.sum
-				if m.min < m2.min {
-					m2.min = m.min
-				}
-				if m.max > m2.max {
-					m2.max = m.max
-				}
-			}
+	lower := strings.ToLower(strings.TrimSpace(s))
+	scale := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower, scale = strings.TrimSpace(strings.TrimSuffix(lower, u.suffix)), u.scale
+			break
 		}
 	}
-
-	return r
-}
-
-func (s *MergedStatistics) PrintResult() {
-	printResult(s.measures)
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/scale {
+		return 0, fmt.Errorf("invalid size %q, want a positive number of bytes with an optional unit, e.g. 512MB", s)
+	}
+	return n * scale, nil
 }
 
-func printResult(measures map[string]*M) {
-	keys := make([]string, 0, len(measures))
-	for key := range measures {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	if len(keys) > 0 {
-		fmt.Printf("{")
-		key := keys[0]
-		m := measures[key]
-		fmt.Printf("%s=%.1f/%.1f/%.1f", key, float64(m.min)/10, float64(m.sum)/float64(m.count*10), float64(m.max)/10)
-		for _, key := range keys[1:] {
-			m := measures[key]
-			fmt.Printf(", %s=%.1f/%.1f/%.1f", key, float64(m.min)/10, float64(m.sum)/float64(m.count*10), float64(m.max)/10)
+func parsePercentiles(s string) ([]float64, error) {
+	var ps []float64
+	for _, field := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q, want a number between 0 and 100", field)
 		}
-		fmt.Printf("}\n")
+		ps = append(ps, p)
 	}
+	return ps, nil
 }
 
-type M struct {
-	name  string
-	count int
-	min   int64
-	max   int64
-	sum   int64
-}
-
-func newM() *M {
-	return &M{
-		count: 0,
-		min:   math.MaxInt64,
-		max:   math.MinInt64,
+// processOptions 根据命令行参数构造聚合选项，不包括-progress
+func processOptions() []brc.Option {
+	opts := []brc.Option{
+		brc.WithEngine(brc.Engine(*engine)),
+		brc.WithWorkers(*workers),
+		brc.WithCompression(brc.Compression(*compression)),
+		brc.WithStrict(*strict),
+		brc.WithStrictSpec(*strictSpec),
+		brc.WithQuoted(*quoted),
+		brc.WithNormalizeKeys(*normalizeKeys),
+		brc.WithFoldCase(brc.CaseFolding(*foldCase)),
 	}
-}
-
-func (m *M) Add(val int64) {
-	m.count++
-	m.sum += val
-	if val < m.min {
-		m.min = val
+	if *window != 0 {
+		opts = append(opts, brc.WithWindow(*window))
+	}
+	if *filterPrefix != "" {
+		opts = append(opts, brc.WithFilterPrefix(*filterPrefix))
+	}
+	if *filterRegex != "" {
+		re, err := regexp.Compile(*filterRegex)
+		if err != nil {
+			fatalUsage("invalid -filter-regex: %v", err)
+		}
+		opts = append(opts, brc.WithFilterRegex(re))
+	}
+	if *groupBy != "" {
+		g, err := parseGroupBy(*groupBy)
+		if err != nil {
+			fatalUsage("invalid -group-by: %v", err)
+		}
+		opts = append(opts, brc.WithGroupBy(g))
+	}
+	if *checkpoint != "" {
+		opts = append(opts, brc.WithCheckpoint(*checkpoint, *checkpointInterval), brc.WithResume(*resume))
+	} else if *resume {
+		fatalUsage("-resume requires -checkpoint")
+	}
+	if *percentiles != "" {
+		ps, err := parsePercentiles(*percentiles)
+		check(err)
+		opts = append(opts, brc.WithPercentiles(ps...))
+	}
+	if *median {
+		opts = append(opts, brc.WithExactMedian(true))
+	}
+	if *histogram {
+		opts = append(opts, brc.WithHistogram(true))
+	}
+	if *checkOverflow {
+		opts = append(opts, brc.WithOverflowCheck(true))
+	}
+	if *hugePages {
+		opts = append(opts, brc.WithHugePages(true))
+	}
+	if *hash != "" {
+		opts = append(opts, brc.WithHash(brc.Hash(*hash)))
+	}
+	if *sharedTable {
+		opts = append(opts, brc.WithSharedTable(true))
+	}
+	if *raceSafe {
+		opts = append(opts, brc.WithRaceSafe(true))
+	}
+	if *dumpPartials != "" {
+		opts = append(opts, brc.WithDumpPartials(*dumpPartials))
 	}
-	if val > m.max {
-		m.max = val
+	if *numa {
+		opts = append(opts, brc.WithNUMA(true))
 	}
+	limiter, err := newRateLimiter(*maxRowsPerSec, *maxBytesPerSec)
+	if err != nil {
+		fatalUsage("%v", err)
+	}
+	if limiter != nil {
+		opts = append(opts, brc.WithRateLimit(limiter))
+	}
+	if *autoTune {
+		opts = append(opts, brc.WithAutoTune(true))
+	}
+	aggs, err := parseAggregates(*aggregates)
+	if err != nil {
+		fatalUsage("invalid -aggregate: %v", err)
+	}
+	if !tracksMinMax(aggs) {
+		opts = append(opts, brc.WithSkipMinMax(true))
+	}
+	if *verbose {
+		opts = append(opts, brc.WithLogger(slog.Default()))
+	}
+	if *maxMemory != "" {
+		size, err := parseSize(*maxMemory)
+		if err != nil {
+			fatalUsage("invalid -max-memory: %v", err)
+		}
+		// 缓冲区之外的分配也尽量不超过预算
+		debug.SetMemoryLimit(size)
+		opts = append(opts, brc.WithMaxMemory(size))
+	}
+	if *streamChunks > 0 && isStreamInput(inputPath()) {
+		size, err := parseSize(*streamChunkSize)
+		if err != nil || size <= 0 {
+			fatalUsage("invalid -stream-chunk-size %q", *streamChunkSize)
+		}
+		opts = append(opts, brc.WithStreaming(*streamChunks, int(size)))
+	}
+	return opts
 }
 
-// 相比于scanner默认的SplitFunc，会读取多行，实现方式是按缓冲区中最后一个换行符进行区分
-// 这样读取到的token实际包含多行数据，并且需要注意可能会有多余的'\r'字符
-func scanManyLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
-	}
-	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
-		// We have a full newline-terminated line.
-		return i + 1, data[0:i], nil
-	}
-	// If we're at EOF, we have a final, non-terminated line. Return it.
-	if atEOF {
-		return len(data), data, nil
-	}
-	// Request more data.
-	return 0, nil, nil
+func main() {
+	args := os.Args[1:]
+	name := "run"
+	// 没有子命令时与run相同，兼容以前的用法
+	if len(args) > 0 && lookupCommand(args[0]) != nil {
+		name, args = args[0], args[1:]
+	}
+	runCommand(name, args)
 }
 
-func main() {
-	flag.Parse()
-	if *cpuprofile != "" {
-		f, err := os.Create(*cpuprofile) // ignore_security_alert
+// runAggregate 实现run子命令：聚合输入并按输出相关的参数输出每个站点的结果
+func runAggregate(args []string) {
+	cmdline := parseFlags(flag.CommandLine, args)
+	if *configFile != "" {
+		if err := applyConfig(*configFile); err != nil {
+			fatalUsage("invalid -config: %v", err)
+		}
+	}
+	checkSubcommandFlags(cmdline)
+	setupLogging()
+	// 先注册，在停止profile等其它defer之后退出
+	status := 0
+	defer func() {
+		if status != 0 {
+			os.Exit(status)
+		}
+	}()
+	defer startCPUProfile()()
+	defer startProfiles()()
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile) // ignore_security_alert
 		if err != nil {
-			log.Fatal("could not create CPU profile: ", err)
+			log.Fatal("could not create trace file: ", err)
 		}
-		defer f.Close() // error handling omitted for example
-		if err := pprof.StartCPUProfile(f); err != nil {
-			log.Fatal("could not start CPU profile: ", err)
+		defer f.Close()
+		if err := trace.Start(f); err != nil {
+			log.Fatal("could not start trace: ", err)
 		}
-		defer pprof.StopCPUProfile()
+		defer trace.Stop()
 	}
 
-	file, err := os.Open("measurements.txt")
-	pie(err)
-	defer file.Close()
-
-	num := min(8, runtime.NumCPU())
-	statistics := make([]*Statistic, num)
-
-	wg := &sync.WaitGroup{}
-	ch := make(chan []byte)
-	for i := 0; i < num; i++ {
-		go func(idx int) {
-			statistics[idx] = newStatistic()
-			for lines := range ch {
-				statistics[idx].ParseAndAddLines(lines)
-				wg.Done()
-			}
-		}(i)
+	if *pprofAddr != "" {
+		go func() {
+			log.Println(http.ListenAndServe(*pprofAddr, nil))
+		}()
 	}
 
-	scanner := bufio.NewScanner(file)
-	buffer := make([]byte, 256*1024*1024)
-	scanner.Buffer(buffer, len(buffer))
-	scanner.Split(scanManyLines)
-
-	sep := []byte("\n")
-	for scanner.Scan() {
-		data := scanner.Bytes()
-		count := bytes.Count(data, sep)
+	if err := checkRunStats(); err != nil {
+		fatalUsage("%v", err)
+	}
+	if *timeout < 0 {
+		fatalUsage("-timeout must not be negative, got %s", *timeout)
+	}
+	checkMinCount()
+	if *timeout > 0 && *follow {
+		fatalUsage("-timeout cannot be used with -follow")
+	}
+	path := inputPath()
+	opts := processOptions()
+	// 始终记录进度，收到SIGUSR1时打印快照
+	p := &brc.Progress{}
+	opts = append(opts, brc.WithProgress(p))
+	if *progress && !*quiet {
+		stop := reportProgress(os.Stderr, p, time.Second)
+		defer stop()
+	}
+	defer notifySnapshots(os.Stderr, p)()
+	if *selfcheck {
+		runSelfcheck(path, opts)
+		return
+	}
+	if *estimate {
+		runEstimate(path, opts)
+		return
+	}
+	if *follow {
+		followInput(path, opts)
+		return
+	}
+	// 收到SIGINT或SIGTERM或者超过-timeout时停止读取并输出已经解析的部分结果，再次收到信号时按默认行为直接退出
+	sigCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		<-sigCtx.Done()
+		stopSignals()
+	}()
+	ctx := sigCtx
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(sigCtx, *timeout)
+		defer cancel()
+	}
+	opts = append(opts, brc.WithContext(ctx))
+	if aggs, _ := parseAggregates(*aggregates); countOnly(aggs) {
+		status = runCount(path, opts)
+		return
+	}
+	sample := newSample()
+	if sample != nil {
+		opts = append(opts, brc.WithSample(sample))
+	}
+	var malformed *brc.Malformed
+	if *reportMalformed > 0 {
+		malformed = brc.NewMalformed(*reportMalformed)
+		opts = append(opts, brc.WithMalformed(malformed))
+	}
+	var stats brc.HashStats
+	if *hashStats {
+		opts = append(opts, brc.WithHashStats(&stats))
+	}
+	var run brc.RunStats
+	if *runStats != "" {
+		opts = append(opts, brc.WithRunStats(&run))
+	}
+	var results brc.Results
+	var err error
+	if path == "-" {
+		results, err = brc.ProcessReader(os.Stdin, opts...)
+	} else {
+		results, err = brc.Process(path, opts...)
+	}
+	interrupted := checkInterrupted(path, err)
+	if *hashStats && !*quiet {
+		printHashStats(os.Stderr, stats)
+	}
+	if *runStats != "" && !*quiet {
+		check(printRunStats(os.Stderr, &run, *runStats))
+	}
 
-		step := min(count+1, max(10, (count+1)/num+1))
+	check(writeOutput(results))
+	slog.Debug("done", "input", path, "stations", len(results), "elapsed", time.Since(startTime))
+	if *verify != "" {
+		verifyResults(results)
+	}
 
-		var (
-			n          = 0
-			start      = 0
-			batchStart = 0
-		)
-		for {
-			pos := bytes.IndexByte(data[start:], '\n')
-			if pos < 0 {
-				wg.Add(1)
-				ch <- data[batchStart:]
-				break
-			}
-			n++
-			if n%step == 0 {
-				wg.Add(1)
-				ch <- data[batchStart : start+pos]
-				batchStart = start + pos + 1
-			}
-			start = start + pos + 1
+	if sample != nil && !*quiet {
+		printSample(os.Stderr, sample)
+	}
+	if malformed != nil && malformed.Count > 0 {
+		if !*quiet {
+			printMalformed(os.Stderr, malformed)
 		}
-		wg.Wait()
+		status = exitWarnings
+	}
+	if interrupted != 0 {
+		status = interrupted
 	}
-	pie(scanner.Err())
 
-	statistic := mergeStatistics(statistics...)
-	statistic.PrintResult()
+	if *grpcAddr != "" {
+		q := &queryServer{results: func() brc.Results { return results }, complete: true}
+		log.Fatal(serveGRPC(*grpcAddr, q))
+	}
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"4096":    4096,
+		"100b":    100,
+		"64KB":    64 << 10,
+		"512MB":   512 << 20,
+		"512 MiB": 512 << 20,
+		"2g":      2 << 30,
+	} {
+		size, err := parseSize(s)
+		if err != nil || size != expected {
+			t.Errorf("%q: expected %d, got %d, %v", s, expected, size, err)
+		}
+	}
+	for _, s := range []string{"", "MB", "-1MB", "0", "1.5GB", "1TB", "9999999999GB"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	if l, err := newRateLimiter(0, ""); l != nil || err != nil {
+		t.Errorf("expected no limiter, got %v, %v", l, err)
+	}
+	if l, err := newRateLimiter(1000, "16MB"); l == nil || err != nil {
+		t.Errorf("expected a limiter, got %v, %v", l, err)
+	}
+	if _, err := newRateLimiter(-1, ""); err == nil {
+		t.Error("expected error for negative -max-rows-per-sec")
+	}
+	if _, err := newRateLimiter(0, "fast"); err == nil {
+		t.Error("expected error for invalid -max-bytes-per-sec")
+	}
+}
+
+func TestParseAggregates(t *testing.T) {
+	aggs, err := parseAggregates("count")
+	if err != nil || !countOnly(aggs) || tracksMinMax(aggs) {
+		t.Errorf("count: unexpected %v, %v", aggs, err)
+	}
+	aggs, err = parseAggregates("sum, mean")
+	if err != nil || len(aggs) != 2 || countOnly(aggs) || tracksMinMax(aggs) {
+		t.Errorf("sum,mean: unexpected %v, %v", aggs, err)
+	}
+	aggs, err = parseAggregates("mean,max")
+	if err != nil || !tracksMinMax(aggs) {
+		t.Errorf("mean,max: unexpected %v, %v", aggs, err)
+	}
+	if aggs, err = parseAggregates(""); err != nil || aggs != nil || !tracksMinMax(aggs) {
+		t.Errorf("empty: unexpected %v, %v", aggs, err)
+	}
+	if _, err = parseAggregates("count,median"); err == nil {
+		t.Error("expected error for unknown aggregate")
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.csv")
+	if err := os.WriteFile(path, []byte("Hamburg,DE\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []string{"prefix:1", "regex:^(..)", "map:" + path} {
+		if _, err := parseGroupBy(spec); err != nil {
+			t.Errorf("%q: %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "prefix", "prefix:0", "prefix:x", "regex:(", "map:" + path + ".missing", "suffix:1"} {
+		if _, err := parseGroupBy(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	_, err := os.Open(filepath.Join(t.TempDir(), "missing"))
+	for _, tc := range []struct {
+		err      error
+		expected int
+	}{
+		{err, exitIO},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), exitIO},
+		{fmt.Errorf("worker: %w", &brc.ParseError{Offset: 3, Line: "x"}), exitParse},
+		{fmt.Errorf("could not read results r.txt: %w", &brc.ResultsFormatError{Err: errors.New("bad")}), exitParse},
+		{errors.New("boom"), exitError},
+	} {
+		if code := exitCode(tc.err); code != tc.expected {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.expected, code)
+		}
+	}
+}
+
+func TestCheckInterrupted(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, tc := range []struct {
+		err      error
+		expected int
+	}{
+		{nil, 0},
+		{&brc.InterruptedError{Bytes: 10, Size: 100, Err: context.Canceled}, exitInterrupted},
+		{&brc.InterruptedError{Bytes: 10, Err: context.DeadlineExceeded}, exitTimeout},
+	} {
+		if code := checkInterrupted("measurements.txt", tc.err); code != tc.expected {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.expected, code)
+		}
+	}
+}
+
+func TestLookupCommand(t *testing.T) {
+	for _, cmd := range subcommandFlags {
+		if lookupCommand(cmd) == nil {
+			t.Errorf("%s: expected a command", cmd)
+		}
+	}
+	if c := lookupCommand("verify"); c == nil || c.name != "verify" {
+		t.Errorf("expected verify, got %v", c)
+	}
+	if c := lookupCommand("measurements.txt"); c != nil {
+		t.Errorf("expected nil, got %v", c)
+	}
+}
+
+func TestRunIgnoresSubcommandEnv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "measurements.txt")
+	if err := os.WriteFile(path, []byte("Hamburg;12.0\nHamburg;14.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "results.txt")
+	defer func(o stringList) { outputs = o }(outputs)
+	defer slog.SetDefault(slog.Default())
+	defer func() {
+		for _, name := range []string{"listen", "state", "quiet"} {
+			flag.Set(name, flag.Lookup(name).DefValue)
+		}
+	}()
+	// 为coordinator和save设置的环境变量不影响run
+	t.Setenv("BRC_LISTEN", ":9999")
+	t.Setenv("BRC_STATE", filepath.Join(dir, "state"))
+	runAggregate([]string{"-quiet", "-output", out, path})
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{Hamburg=12.0/13.0/14.0}\n"; string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, data)
+	}
+}
+
+func TestPrintSample(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printSample(buf, &brc.Sample{Chunks: 3, Bytes: 3 << 20, Total: 300 << 20})
+	if expected := "approximate results from 3 chunks, 3.0 MiB of 300.0 MiB (1.00%) of the input\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestPrintCardinality(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printCardinality(buf, &brc.Cardinality{Rows: 3, Distinct: 2, Top: []brc.NameCount{{Name: "Hamburg", Count: 2}, {Name: "Abha", Count: 1}}})
+	expected := "rows: 3\ndistinct stations: ~2\nmost frequent:\n  Hamburg 2\n  Abha    1\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
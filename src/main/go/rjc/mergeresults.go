@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// mergeResults 实现merge-results子命令：合并位置参数中分别处理不同输入得到的结果文件，按-format等参数输出。
+// 结果文件可以是默认的文本格式或json格式，出现在多个文件中的站点需要json中的count和sum才能精确合并，
+// 即以-format json -aggregate min,max,sum,count输出的结果，否则报错而不是输出有误差的平均值
+func mergeResults(args []string) {
+	parseFlags(flag.CommandLine, args)
+	setupLogging()
+	if flag.NArg() == 0 {
+		fatalUsage("merge-results requires at least one results file")
+	}
+	checkMinCount()
+	var inputs []brc.Results
+	for _, path := range flag.Args() {
+		r, err := readResultsFile(path)
+		if err != nil {
+			check(fmt.Errorf("could not read results %s: %w", path, err))
+		}
+		inputs = append(inputs, r)
+	}
+	merged, err := brc.MergeResults(inputs...)
+	check(err)
+	check(writeOutput(merged))
+}
+
+func readResultsFile(path string) (brc.Results, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return brc.ReadResults(data)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var format = flag.String("format", "text", "output `format`: text, json, csv, markdown, table (aligned with a totals row), arrow (IPC file) or arrow-stream (IPC stream)")
+var limit = flag.Int("limit", 0, "only show the first `N` stations with -format table, the totals row still covers all stations")
+var pretty = flag.Bool("pretty", false, "indent json output")
+var top = flag.Int("top", 0, "only output the top `N` stations sorted by -by instead of all stations by name")
+var by = flag.String("by", "mean", "`metric` used by -top: mean, max, min or count")
+var sortBy = flag.String("sort", "", "order output stations by `metric`: name, mean, min, max or count, defaults to name or the -top order")
+var desc = flag.Bool("desc", false, "sort in descending order, used with -sort")
+var minCount = flag.Int("min-count", 0, "omit stations with fewer than `N` observations from the output, e.g. typos that appear only once in real-world inputs")
+var minCountOmitted = flag.String("min-count-omitted", "", "write the stations omitted by -min-count to `file` in -format, - for stderr")
+var histogram = flag.Bool("histogram", false, "output per-station histograms in json and csv formats, costs about 16KB per station and worker")
+var buckets = flag.Int("buckets", 10, "number of histogram `buckets` spanning each station's min to max")
+var unit = flag.String("unit", "c", "output temperature `unit`: c (Celsius) or f (Fahrenheit)")
+var inputUnit = flag.String("input-unit", "c", "temperature `unit` of the input values: c or f")
+var stats = flag.String("stats", "", "comma separated extra `statistics` to output per station: stddev, variance")
+var tmpl = flag.String("template", "", "render results through the text/template in `file` instead of -format; see brc.Results.WriteTemplate for the available data and functions")
+var derived = stringList{}
+var outputs = stringList{}
+
+func init() {
+	flag.Var(&outputs, "output", "write results to `[format:]dest` instead of stdout: a file written atomically, - for stdout, sqlite://path to append to a SQLite database or statsd://host:port[/prefix] to send gauges over UDP; format defaults to -format; may be repeated to write several outputs")
+	flag.Var(&derived, "derive", "output a derived `name=expr` column per station, expr combines min, max, mean, sum, count, stddev, variance and numbers with + - * / and parentheses, e.g. range=max-min; may be repeated")
+}
+
+// stringList 是可以重复指定的字符串参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// formats 是-format和-output可以指定的输出格式
+var formats = []string{"text", "json", "csv", "markdown", "table", "arrow", "arrow-stream"}
+
+// outputFormat 返回f，为空时返回-format
+func outputFormat(f string) string {
+	return cmp.Or(f, *format)
+}
+
+// writeOptions 返回按format输出时的参数，format为空表示-format
+func writeOptions(format string) (brc.WriteOptions, error) {
+	format = outputFormat(format)
+	opts := brc.WriteOptions{Pretty: *pretty}
+	var err error
+	if opts.Unit, err = brc.ParseUnit(*unit); err != nil {
+		return opts, err
+	}
+	if opts.InputUnit, err = brc.ParseUnit(*inputUnit); err != nil {
+		return opts, err
+	}
+	if *histogram {
+		if *buckets <= 0 {
+			return opts, fmt.Errorf("-buckets must be positive, got %d", *buckets)
+		}
+		opts.Buckets = *buckets
+	}
+	if opts.Aggregates, err = parseAggregates(*aggregates); err != nil {
+		return opts, err
+	}
+	if *color {
+		if opts.Thresholds, err = brc.ParseThresholds(*colorThresholds); err != nil {
+			return opts, err
+		}
+	}
+	// 按元数据的列聚合时结果中不再是站点名
+	if !groupsByMetadata() {
+		if opts.Metadata, err = loadMetadata(); err != nil {
+			return opts, err
+		}
+		if opts.Metadata != nil && format != "csv" && format != "json" && format != "markdown" && format != "table" {
+			return opts, fmt.Errorf("-metadata adds columns to csv, json, markdown and table output only, got %q", format)
+		}
+	}
+	if *stats != "" {
+		for _, name := range strings.Split(*stats, ",") {
+			switch strings.TrimSpace(name) {
+			case "stddev":
+				opts.Columns = append(opts.Columns, brc.StddevColumn)
+			case "variance":
+				opts.Columns = append(opts.Columns, brc.VarianceColumn)
+			default:
+				return opts, fmt.Errorf("unknown statistic %q, want stddev or variance", name)
+			}
+		}
+	}
+	for _, spec := range derived {
+		c, err := brc.ParseDerived(spec)
+		if err != nil {
+			return opts, err
+		}
+		opts.Columns = append(opts.Columns, c)
+	}
+	return opts, nil
+}
+
+// writeResults 按format输出结果，format为空时使用-template或-format
+func writeResults(w io.Writer, results brc.Results, format string) error {
+	opts, err := writeOptions(format)
+	if err != nil {
+		return err
+	}
+	if !isTerminal(w) {
+		opts.Thresholds = nil
+	}
+	if format == "" && *tmpl != "" {
+		text, err := os.ReadFile(*tmpl)
+		if err != nil {
+			return err
+		}
+		return results.WriteTemplate(w, string(text), opts)
+	}
+	switch format = outputFormat(format); format {
+	case "text":
+		return results.Write(w, opts)
+	case "json":
+		return results.WriteJSON(w, opts)
+	case "csv":
+		return results.WriteCSV(w, opts)
+	case "markdown":
+		return results.WriteMarkdown(w, opts)
+	case "table":
+		return results.WriteTable(w, *limit, opts)
+	case "arrow":
+		return results.WriteArrow(w, opts)
+	case "arrow-stream":
+		return results.WriteArrowStream(w, opts)
+	default:
+		return fmt.Errorf("unknown format %q, want text, json, csv, markdown, table, arrow or arrow-stream", format)
+	}
+}
+
+// 先写入同目录下的临时文件，再通过rename替换目标文件，避免留下写了一半的结果
+func writeFileAtomic(path string, write func(w io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// startTime 作为运行信息写入SQLite
+var startTime = time.Now()
+
+// writeSQLite 将结果追加到SQLite数据库中，忽略-format
+func writeSQLite(path string, results brc.Results) error {
+	opts, err := writeOptions("")
+	if err != nil {
+		return err
+	}
+	run := brc.RunInfo{Started: startTime, Finished: time.Now(), Command: strings.Join(os.Args[1:], " ")}
+	_, err = results.WriteSQLite(path, run, opts)
+	return err
+}
+
+// sink 是-output指定的一个输出目标
+type sink struct {
+	// format 为空时使用-template或-format
+	format string
+	// path 是文件路径，"-"表示stdout
+	path string
+	// sqlite 和statsd 非空时分别是SQLite数据库的路径和statsd的地址
+	sqlite string
+	statsd string
+	prefix string
+}
+
+// parseSink 解析[format:]dest形式的-output
+func parseSink(spec string) (sink, error) {
+	if path, ok := strings.CutPrefix(spec, "sqlite://"); ok {
+		return sink{sqlite: path}, nil
+	}
+	if addr, ok := strings.CutPrefix(spec, "statsd://"); ok {
+		addr, prefix, _ := strings.Cut(addr, "/")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return sink{}, fmt.Errorf("invalid statsd address in -output %q: %w", spec, err)
+		}
+		return sink{statsd: addr, prefix: cmp.Or(strings.Trim(prefix, "/"), "brc")}, nil
+	}
+	var s sink
+	if f, path, ok := strings.Cut(spec, ":"); ok && slices.Contains(formats, f) {
+		s.format, spec = f, path
+	}
+	if spec == "" {
+		return sink{}, fmt.Errorf("missing destination in -output %q", s.format+":")
+	}
+	s.path = spec
+	return s, nil
+}
+
+// sinks 返回-output指定的所有输出目标，未指定时只输出到stdout
+func sinks() ([]sink, error) {
+	if len(outputs) == 0 {
+		return []sink{{path: "-"}}, nil
+	}
+	var all []sink
+	for _, spec := range outputs {
+		s, err := parseSink(spec)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+// statsdPacketSize 是一个UDP包的最大字节数，保证在常见的MTU下不分片
+const statsdPacketSize = 1432
+
+// writeStatsd 通过UDP将结果以gauge的形式发送到addr
+func writeStatsd(addr, prefix string, results brc.Results) error {
+	opts, err := writeOptions("")
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return results.WriteStatsd(conn, prefix, statsdPacketSize, opts)
+}
+
+// write 将结果写入s
+func (s sink) write(results brc.Results) error {
+	switch {
+	case s.sqlite != "":
+		return writeSQLite(s.sqlite, results)
+	case s.statsd != "":
+		return writeStatsd(s.statsd, s.prefix, results)
+	case s.path == "-":
+		return writeResults(os.Stdout, results, s.format)
+	default:
+		return writeFileAtomic(s.path, func(w io.Writer) error {
+			return writeResults(w, results, s.format)
+		})
+	}
+}
+
+// omitRare 去掉观测次数少于-min-count的站点，并按-min-count-omitted单独输出被去掉的站点
+func omitRare(results brc.Results) (brc.Results, error) {
+	kept, omitted := results.MinCount(*minCount)
+	if len(omitted) > 0 {
+		slog.Info("omitted stations below -min-count", "stations", len(omitted), "min_count", *minCount)
+	}
+	switch *minCountOmitted {
+	case "":
+	case "-":
+		return kept, writeResults(os.Stderr, omitted, "")
+	default:
+		return kept, writeFileAtomic(*minCountOmitted, func(w io.Writer) error {
+			return writeResults(w, omitted, "")
+		})
+	}
+	return kept, nil
+}
+
+// checkMinCount 检查writeOutput使用的-min-count和-min-count-omitted
+func checkMinCount() {
+	if *minCount < 0 {
+		fatalUsage("-min-count must not be negative, got %d", *minCount)
+	}
+	if *minCountOmitted != "" && *minCount == 0 {
+		fatalUsage("-min-count-omitted requires -min-count")
+	}
+}
+
+// writeOutput 将结果依次写入-output指定的每个目标，未指定时写到stdout。
+// 一个目标失败时仍然写入其它目标，返回所有的错误
+func writeOutput(results brc.Results) error {
+	var err error
+	if *minCount > 0 {
+		if results, err = omitRare(results); err != nil {
+			return err
+		}
+	}
+	if *top > 0 {
+		if results, err = results.Top(*top, brc.Metric(*by)); err != nil {
+			return err
+		}
+	}
+	if *sortBy != "" {
+		if results, err = results.Sort(brc.Metric(*sortBy), *desc); err != nil {
+			return err
+		}
+	}
+	all, err := sinks()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range all {
+		if err := s.write(results); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
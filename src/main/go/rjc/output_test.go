@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入失败时保留原文件且不留下临时文件
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("boom")
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("expected original content, got %q", data)
+	}
+
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("expected new content, got %q", data)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the result file, got %v", entries)
+	}
+}
+
+func TestParseSink(t *testing.T) {
+	for _, tt := range []struct {
+		spec     string
+		expected sink
+	}{
+		{"results.txt", sink{path: "results.txt"}},
+		{"-", sink{path: "-"}},
+		{"json:results.json", sink{format: "json", path: "results.json"}},
+		{"csv:-", sink{format: "csv", path: "-"}},
+		{"c:/results.txt", sink{path: "c:/results.txt"}},
+		{"sqlite://runs.db", sink{sqlite: "runs.db"}},
+		{"statsd://localhost:8125", sink{statsd: "localhost:8125", prefix: "brc"}},
+		{"statsd://localhost:8125/weather.1brc", sink{statsd: "localhost:8125", prefix: "weather.1brc"}},
+	} {
+		s, err := parseSink(tt.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+		} else if s != tt.expected {
+			t.Errorf("%s: expected %+v, got %+v", tt.spec, tt.expected, s)
+		}
+	}
+	for _, spec := range []string{"json:", "statsd://localhost"} {
+		if _, err := parseSink(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestWriteOutputSinks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dir := t.TempDir()
+	text, json := filepath.Join(dir, "results.txt"), filepath.Join(dir, "results.json")
+	defer func(old stringList) { outputs = old }(outputs)
+	outputs = stringList{text, "json:" + json, "statsd://" + conn.LocalAddr().String() + "/test"}
+
+	s := brc.NewStatistic()
+	s.ParseAndAddLines([]byte("a;1.0\n"))
+	if err := writeOutput(s.Results()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(text); string(data) != "{a=1.0/1.0/1.0}\n" {
+		t.Errorf("unexpected text output %q", data)
+	}
+	if data, _ := os.ReadFile(json); string(data) != `{"a":{"min":1.0,"mean":1.0,"max":1.0,"count":1}}`+"\n" {
+		t.Errorf("unexpected json output %q", data)
+	}
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf[:n]), "test.a.min:1.0|g\n") {
+		t.Errorf("unexpected statsd packet %q", buf[:n])
+	}
+}
+
+func TestWriteOutputMinCount(t *testing.T) {
+	dir := t.TempDir()
+	text, omitted := filepath.Join(dir, "results.txt"), filepath.Join(dir, "omitted.txt")
+	defer func(old stringList) { outputs = old }(outputs)
+	defer func(old int, path string) { *minCount, *minCountOmitted = old, path }(*minCount, *minCountOmitted)
+	outputs = stringList{text}
+	*minCount, *minCountOmitted = 2, omitted
+
+	s := brc.NewStatistic()
+	s.ParseAndAddLines([]byte("a;1.0\nb;2.0\nb;4.0\nTpyo;3.0\n"))
+	if err := writeOutput(s.Results()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(text); string(data) != "{b=2.0/3.0/4.0}\n" {
+		t.Errorf("unexpected output %q", data)
+	}
+	if data, _ := os.ReadFile(omitted); string(data) != "{Tpyo=3.0/3.0/3.0, a=1.0/1.0/1.0}\n" {
+		t.Errorf("unexpected omitted stations %q", data)
+	}
+}
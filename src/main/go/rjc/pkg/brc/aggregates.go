@@ -0,0 +1,107 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Aggregate 是可以单独输出的统计量
+type Aggregate string
+
+const (
+	AggregateCount Aggregate = "count"
+	AggregateSum   Aggregate = "sum"
+	AggregateMean  Aggregate = "mean"
+	AggregateMin   Aggregate = "min"
+	AggregateMax   Aggregate = "max"
+)
+
+// ParseAggregate 校验统计量的名字
+func ParseAggregate(name string) (Aggregate, error) {
+	switch a := Aggregate(name); a {
+	case AggregateCount, AggregateSum, AggregateMean, AggregateMin, AggregateMax:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown aggregate %q, want count, sum, mean, min or max", name)
+	}
+}
+
+// WithSkipMinMax 只累计每个站点的Count、Sum和SumSq，不维护最小值和最大值，
+// 用于只需要总和或平均值的场景。结果中的Min和Max没有意义，输出时应配合WriteOptions.Aggregates
+func WithSkipMinMax(skip bool) Option {
+	return func(o *options) {
+		o.skipMinMax = skip
+	}
+}
+
+// addSum 与Add相同，但不更新Min和Max
+func (m *M) addSum(val int64) {
+	m.Count++
+	m.Sum += val
+	m.SumSq += val * val
+}
+
+// Count 统计path中的记录数，不区分站点，也不解析温度，是最快的处理方式
+func Count(path string, opts ...Option) (int64, error) {
+	file, err := openInput(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return count(file, newOptions(opts))
+}
+
+// CountReader 与Count相同，但从r读取
+func CountReader(r io.Reader, opts ...Option) (int64, error) {
+	return count(r, newOptions(opts))
+}
+
+func count(r io.Reader, o options) (int64, error) {
+	switch {
+	case o.strict || o.strictSpec || o.malformed != nil || o.quoted || o.window > 0:
+		return 0, errors.New("count does not support strict, quoted or windowed input")
+	case o.filterPrefix != "" || o.filterRegex != nil:
+		return 0, errors.New("count does not support filters")
+	case o.checkpoint != "" || o.sharedTable:
+		return 0, errors.New("count does not support checkpoints or a shared table")
+	}
+	o.countOnly = true
+	merged, err := aggregate(r, o)
+	if merged == nil {
+		return 0, err
+	}
+	return merged.rows, err
+}
+
+// countRecords 返回lines中的记录数，每条记录恰好包含一个';'
+func countRecords(lines []byte) int64 {
+	return int64(bytes.Count(lines, []byte{';'}))
+}
+
+// aggregateValues 返回opts.Aggregates中各统计量格式化后的值，单位为opts.Unit的度
+func (s *Station) aggregateValues(opts WriteOptions) []string {
+	lo, mean, hi := s.formatMinMeanMax(opts)
+	values := make([]string, len(opts.Aggregates))
+	for i, a := range opts.Aggregates {
+		switch a {
+		case AggregateCount:
+			values[i] = strconv.Itoa(s.Count)
+		case AggregateSum:
+			if opts.converts() {
+				values[i] = formatValue(opts.temperature(s.mean()) * float64(s.Count))
+			} else {
+				values[i] = formatTenths(s.Sum)
+			}
+		case AggregateMean:
+			values[i] = mean
+		case AggregateMin:
+			values[i] = lo
+		case AggregateMax:
+			values[i] = hi
+		}
+	}
+	return values
+}
@@ -0,0 +1,57 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	const data = "Hamburg;12.0\nBulawayo;8.9\n\nHamburg;-3.4\nPalembang;38.8"
+	path := writeMeasurements(t, data)
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		n, err := Count(path, WithEngine(engine), WithWorkers(2))
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if n != 4 {
+			t.Errorf("%s: expected 4 records, got %d", engine, n)
+		}
+	}
+	if n, err := CountReader(strings.NewReader(data)); err != nil || n != 4 {
+		t.Errorf("expected 4 records from reader, got %d, %v", n, err)
+	}
+	if _, err := Count(path, WithStrict(true)); err == nil {
+		t.Error("expected error for strict count")
+	}
+}
+
+func TestSkipMinMax(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n")
+	results, err := Process(path, WithSkipMinMax(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Count != 2 || results[1].Sum != 86 {
+		t.Fatalf("unexpected results %v", results)
+	}
+
+	opts := WriteOptions{Aggregates: []Aggregate{AggregateSum, AggregateMean, AggregateCount}}
+	for _, tc := range []struct {
+		write    func(Results, *bytes.Buffer) error
+		expected string
+	}{
+		{func(r Results, b *bytes.Buffer) error { return r.Write(b, opts) }, "{Bulawayo=8.9/8.9/1, Hamburg=8.6/4.3/2}\n"},
+		{func(r Results, b *bytes.Buffer) error { return r.WriteCSV(b, opts) }, "station,sum,mean,count\nBulawayo,8.9,8.9,1\nHamburg,8.6,4.3,2\n"},
+		{func(r Results, b *bytes.Buffer) error { return r.WriteJSON(b, opts) },
+			`{"Bulawayo":{"sum":8.9,"mean":8.9,"count":1},"Hamburg":{"sum":8.6,"mean":4.3,"count":2}}` + "\n"},
+	} {
+		buf := &bytes.Buffer{}
+		if err := tc.write(results, buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package brc
+
+import "sync"
+
+// Aggregator 是可以被多个goroutine并发写入的聚合状态，按站点名的哈希分成多个shard，
+// 每个shard由独立的锁保护，用于持续接收数据的服务
+type Aggregator struct {
+	shards []aggregatorShard
+	pool   sync.Pool
+}
+
+type aggregatorShard struct {
+	mu sync.Mutex
+	s  *Statistic
+}
+
+// NewAggregator 创建有shards个shard的Aggregator，shards会向上取整为2的幂
+func NewAggregator(shards int) *Aggregator {
+	n := 1
+	for n < shards {
+		n *= 2
+	}
+	a := &Aggregator{shards: make([]aggregatorShard, n)}
+	for i := range a.shards {
+		a.shards[i].s = NewStatistic()
+	}
+	a.pool.New = func() any {
+		return &aggregatorBuffer{s: NewStatistic(), shards: make([][]*entry, n)}
+	}
+	return a
+}
+
+// aggregatorBuffer 是AddLines复用的本地聚合状态
+type aggregatorBuffer struct {
+	s      *Statistic
+	shards [][]*entry
+}
+
+// AddLines 解析`name;value`格式的多行数据并合并到聚合状态中。
+// lines先在本地的Statistic中聚合，每个shard只加锁一次
+func (a *Aggregator) AddLines(lines []byte) {
+	buf := a.pool.Get().(*aggregatorBuffer)
+	local := buf.s
+	local.ParseAndAddLines(lines)
+	mask := uint64(len(a.shards) - 1)
+	for i := range local.table.entries {
+		if e := &local.table.entries[i]; e.used {
+			buf.shards[e.hash&mask] = append(buf.shards[e.hash&mask], e)
+		}
+	}
+	for i, entries := range buf.shards {
+		if len(entries) == 0 {
+			continue
+		}
+		shard := &a.shards[i]
+		shard.mu.Lock()
+		for _, e := range entries {
+			shard.s.table.get(local.table.name(e), e.hash).Merge(&e.m)
+			shard.s.rows += int64(e.m.Count)
+		}
+		shard.mu.Unlock()
+		buf.shards[i] = entries[:0]
+	}
+	local.reset()
+	a.pool.Put(buf)
+}
+
+// Rows 返回已经聚合的行数
+func (a *Aggregator) Rows() int64 {
+	var rows int64
+	for i := range a.shards {
+		shard := &a.shards[i]
+		shard.mu.Lock()
+		rows += shard.s.rows
+		shard.mu.Unlock()
+	}
+	return rows
+}
+
+// Results 返回当前的聚合结果，不影响之后的写入
+func (a *Aggregator) Results() Results {
+	merged := &MergedStatistics{index: make(map[string]int32)}
+	for i := range a.shards {
+		shard := &a.shards[i]
+		shard.mu.Lock()
+		shard.s.table.each(func(name []byte, m *M) {
+			// 结果不引用shard内部的内存
+			merged.add(string(name), m)
+		})
+		shard.mu.Unlock()
+	}
+	return merged.Results()
+}
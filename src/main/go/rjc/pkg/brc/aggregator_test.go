@@ -0,0 +1,38 @@
+package brc
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestAggregator(t *testing.T) {
+	const lines = "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-0.1\n"
+	a := NewAggregator(3)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				a.AddLines([]byte(lines))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if rows := a.Rows(); rows != 8*100*5 {
+		t.Errorf("expected %d rows, got %d", 8*100*5, rows)
+	}
+	buf := &bytes.Buffer{}
+	a.Results().WriteTo(buf)
+	const expected = "{Bulawayo=-0.1/4.4/8.9, Hamburg=-3.4/4.3/12.0, Palembang=38.8/38.8/38.8}\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	for _, s := range a.Results() {
+		if s.Count != 8*100*2 && s.Name != "Palembang" {
+			t.Errorf("%s: expected count %d, got %d", s.Name, 8*100*2, s.Count)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// Arrow IPC格式中用到的flatbuffers枚举值，见Arrow的Schema.fbs、Message.fbs和File.fbs
+const (
+	arrowMetadataV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8          = 5
+
+	arrowPrecisionDouble = 2
+
+	// 消息和body都按8字节对齐
+	arrowAlignment = 8
+)
+
+var (
+	arrowMagic        = []byte("ARROW1")
+	arrowContinuation = []byte{0xff, 0xff, 0xff, 0xff}
+)
+
+type arrowField struct {
+	name string
+	typ  byte
+}
+
+// arrowBlock 记录文件格式中一条消息的位置，写入footer
+type arrowBlock struct {
+	offset         int64
+	metadataLength int32
+	bodyLength     int64
+}
+
+// arrowWriter 依次写入schema消息和record batch消息，记录已写入的字节数
+type arrowWriter struct {
+	w       io.Writer
+	written int64
+	err     error
+}
+
+func (aw *arrowWriter) write(b []byte) {
+	if aw.err != nil {
+		return
+	}
+	n, err := aw.w.Write(b)
+	aw.written += int64(n)
+	aw.err = err
+}
+
+func (aw *arrowWriter) pad() {
+	if n := aw.written % arrowAlignment; n != 0 {
+		aw.write(make([]byte, arrowAlignment-n))
+	}
+}
+
+// message 写入一条封装的消息：continuation、metadata长度、flatbuffers格式的Message和body
+func (aw *arrowWriter) message(metadata, body []byte) arrowBlock {
+	block := arrowBlock{offset: aw.written, bodyLength: int64(len(body))}
+	// 8字节的前缀加上metadata需要对齐到8字节
+	padded := (len(metadata) + 8 + arrowAlignment - 1) / arrowAlignment * arrowAlignment
+	block.metadataLength = int32(padded)
+	aw.write(arrowContinuation)
+	aw.write(binary.LittleEndian.AppendUint32(nil, uint32(padded-8)))
+	aw.write(metadata)
+	aw.write(make([]byte, padded-8-len(metadata)))
+	aw.write(body)
+	return block
+}
+
+func (aw *arrowWriter) endOfStream() {
+	aw.write(arrowContinuation)
+	aw.write([]byte{0, 0, 0, 0})
+}
+
+func arrowFields(r Results, opts WriteOptions) []arrowField {
+	fields := []arrowField{
+		{"station", arrowTypeUtf8},
+		{"min", arrowTypeFloatingPoint},
+		{"mean", arrowTypeFloatingPoint},
+		{"max", arrowTypeFloatingPoint},
+		{"count", arrowTypeInt},
+	}
+	if len(r) > 0 {
+		for _, f := range r[0].extraFields(opts) {
+			fields = append(fields, arrowField{f.name, arrowTypeFloatingPoint})
+		}
+	}
+	return fields
+}
+
+// buildArrowSchema 在b中构建Schema表，所有字段都不可为null
+func buildArrowSchema(b *flatbuffers.Builder, fields []arrowField) flatbuffers.UOffsetT {
+	offsets := make([]flatbuffers.UOffsetT, len(fields))
+	for i, f := range fields {
+		name := b.CreateString(f.name)
+		switch f.typ {
+		case arrowTypeInt:
+			b.StartObject(2)
+			b.PrependInt32Slot(0, 64, 0)
+			b.PrependBoolSlot(1, true, false)
+		case arrowTypeFloatingPoint:
+			b.StartObject(1)
+			b.PrependInt16Slot(0, arrowPrecisionDouble, 0)
+		default:
+			b.StartObject(0)
+		}
+		typ := b.EndObject()
+		b.StartVector(4, 0, 4)
+		children := b.EndVector(0)
+
+		b.StartObject(7)
+		b.PrependUOffsetTSlot(0, name, 0)
+		b.PrependBoolSlot(1, false, false)
+		b.PrependByteSlot(2, f.typ, 0)
+		b.PrependUOffsetTSlot(3, typ, 0)
+		b.PrependUOffsetTSlot(5, children, 0)
+		offsets[i] = b.EndObject()
+	}
+	b.StartVector(4, len(offsets), 4)
+	for i := len(offsets) - 1; i >= 0; i-- {
+		b.PrependUOffsetT(offsets[i])
+	}
+	vec := b.EndVector(len(offsets))
+
+	b.StartObject(4)
+	b.PrependUOffsetTSlot(1, vec, 0)
+	return b.EndObject()
+}
+
+func buildArrowMessage(b *flatbuffers.Builder, headerType byte, header flatbuffers.UOffsetT, bodyLength int64) []byte {
+	b.StartObject(5)
+	b.PrependInt16Slot(0, arrowMetadataV5, 0)
+	b.PrependByteSlot(1, headerType, 0)
+	b.PrependUOffsetTSlot(2, header, 0)
+	b.PrependInt64Slot(3, bodyLength, 0)
+	b.Finish(b.EndObject())
+	return b.FinishedBytes()
+}
+
+// arrowBody 将所有列依次写入body，每列的buffer都按8字节对齐，validity bitmap为空
+func arrowBody(r Results, opts WriteOptions, fields []arrowField) (body []byte, buffers [][2]int64) {
+	buf := &bytes.Buffer{}
+	add := func(data []byte) {
+		offset := int64(buf.Len())
+		buf.Write(data)
+		for buf.Len()%arrowAlignment != 0 {
+			buf.WriteByte(0)
+		}
+		buffers = append(buffers, [2]int64{offset, int64(len(data))})
+	}
+	float64s := func(value func(s *Station) float64) []byte {
+		data := make([]byte, 0, 8*len(r))
+		for i := range r {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(value(&r[i])))
+		}
+		return data
+	}
+
+	for k := range fields {
+		// 每列的第一个buffer是validity bitmap，没有null时长度为0
+		add(nil)
+		switch k {
+		case 0:
+			offsets := binary.LittleEndian.AppendUint32(nil, 0)
+			var names []byte
+			for i := range r {
+				names = append(names, r[i].Name...)
+				offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(names)))
+			}
+			add(offsets)
+			add(names)
+		case 1:
+			add(float64s(func(s *Station) float64 { return opts.temperature(float64(s.Min) / 10) }))
+		case 2:
+			add(float64s(func(s *Station) float64 { return opts.temperature(s.mean()) }))
+		case 3:
+			add(float64s(func(s *Station) float64 { return opts.temperature(float64(s.Max) / 10) }))
+		case 4:
+			data := make([]byte, 0, 8*len(r))
+			for i := range r {
+				data = binary.LittleEndian.AppendUint64(data, uint64(r[i].Count))
+			}
+			add(data)
+		default:
+			add(float64s(func(s *Station) float64 { return s.extraFields(opts)[k-5].value }))
+		}
+	}
+	return buf.Bytes(), buffers
+}
+
+func buildArrowRecordBatch(b *flatbuffers.Builder, rows, columns int, buffers [][2]int64) flatbuffers.UOffsetT {
+	b.StartVector(16, len(buffers), 8)
+	for i := len(buffers) - 1; i >= 0; i-- {
+		b.Prep(8, 16)
+		b.PrependInt64(buffers[i][1])
+		b.PrependInt64(buffers[i][0])
+	}
+	bufs := b.EndVector(len(buffers))
+
+	b.StartVector(16, columns, 8)
+	for i := 0; i < columns; i++ {
+		b.Prep(8, 16)
+		b.PrependInt64(0)
+		b.PrependInt64(int64(rows))
+	}
+	nodes := b.EndVector(columns)
+
+	b.StartObject(5)
+	b.PrependInt64Slot(0, int64(rows), 0)
+	b.PrependUOffsetTSlot(1, nodes, 0)
+	b.PrependUOffsetTSlot(2, bufs, 0)
+	return b.EndObject()
+}
+
+// writeArrowMessages 写入schema和包含全部站点的一个record batch
+func (r Results) writeArrowMessages(aw *arrowWriter, opts WriteOptions) (schema, batch arrowBlock) {
+	fields := arrowFields(r, opts)
+	b := flatbuffers.NewBuilder(1024)
+	schema = aw.message(buildArrowMessage(b, arrowHeaderSchema, buildArrowSchema(b, fields), 0), nil)
+
+	body, buffers := arrowBody(r, opts, fields)
+	b = flatbuffers.NewBuilder(1024)
+	header := buildArrowRecordBatch(b, len(r), len(fields), buffers)
+	batch = aw.message(buildArrowMessage(b, arrowHeaderRecordBatch, header, int64(len(body))), body)
+	return schema, batch
+}
+
+// WriteArrowStream 以Arrow IPC流格式输出结果，每个站点一行，列依次为station、min、mean、max、count，
+// 中位数、分位数和opts.Columns作为额外的列追加在最后。温度以opts.Unit的度为单位，不做舍入
+func (r Results) WriteArrowStream(w io.Writer, opts WriteOptions) error {
+	aw := &arrowWriter{w: w}
+	r.writeArrowMessages(aw, opts)
+	aw.endOfStream()
+	return aw.err
+}
+
+// WriteArrow 以Arrow IPC文件格式（即Feather V2）输出结果，内容与WriteArrowStream相同，
+// 末尾的footer支持随机读取
+func (r Results) WriteArrow(w io.Writer, opts WriteOptions) error {
+	aw := &arrowWriter{w: w}
+	aw.write(arrowMagic)
+	aw.pad()
+	_, batch := r.writeArrowMessages(aw, opts)
+	aw.endOfStream()
+
+	b := flatbuffers.NewBuilder(1024)
+	schema := buildArrowSchema(b, arrowFields(r, opts))
+	b.StartVector(24, 1, 8)
+	b.Prep(8, 24)
+	b.PrependInt64(batch.bodyLength)
+	b.Pad(4)
+	b.PrependInt32(batch.metadataLength)
+	b.PrependInt64(batch.offset)
+	batches := b.EndVector(1)
+	b.StartObject(5)
+	b.PrependInt16Slot(0, arrowMetadataV5, 0)
+	b.PrependUOffsetTSlot(1, schema, 0)
+	b.PrependUOffsetTSlot(3, batches, 0)
+	b.Finish(b.EndObject())
+	footer := b.FinishedBytes()
+
+	aw.write(footer)
+	aw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	aw.write(arrowMagic)
+	return aw.err
+}
@@ -0,0 +1,163 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// arrowTable 读取flatbuffers表中的字段，slot从0开始
+type arrowTable struct{ flatbuffers.Table }
+
+func (t *arrowTable) offset(slot int) flatbuffers.UOffsetT {
+	return flatbuffers.UOffsetT(t.Offset(flatbuffers.VOffsetT(4 + 2*slot)))
+}
+
+func (t *arrowTable) table(slot int) *arrowTable {
+	o := t.offset(slot)
+	if o == 0 {
+		return nil
+	}
+	return &arrowTable{flatbuffers.Table{Bytes: t.Bytes, Pos: t.Indirect(o + t.Pos)}}
+}
+
+func (t *arrowTable) vector(slot int) (flatbuffers.UOffsetT, int) {
+	o := t.offset(slot)
+	if o == 0 {
+		return 0, 0
+	}
+	return t.Vector(o), t.VectorLen(o)
+}
+
+func (t *arrowTable) int64(slot int) int64 {
+	if o := t.offset(slot); o != 0 {
+		return t.GetInt64(o + t.Pos)
+	}
+	return 0
+}
+
+func (t *arrowTable) byte(slot int) byte {
+	if o := t.offset(slot); o != 0 {
+		return t.GetByte(o + t.Pos)
+	}
+	return 0
+}
+
+func arrowFieldNames(t *testing.T, schema *arrowTable) []string {
+	t.Helper()
+	vec, n := schema.vector(1)
+	var names []string
+	for i := 0; i < n; i++ {
+		field := &arrowTable{flatbuffers.Table{Bytes: schema.Bytes, Pos: schema.Indirect(vec + flatbuffers.UOffsetT(4*i))}}
+		names = append(names, string(field.ByteVector(field.offset(0)+field.Pos)))
+	}
+	return names
+}
+
+// readArrowMessage 解析data开头的封装消息，返回Message表、body和剩余的数据
+func readArrowMessage(t *testing.T, data []byte) (*arrowTable, []byte, []byte) {
+	t.Helper()
+	if !bytes.Equal(data[:4], arrowContinuation) {
+		t.Fatalf("missing continuation marker")
+	}
+	size := int(binary.LittleEndian.Uint32(data[4:]))
+	if (size+8)%8 != 0 {
+		t.Fatalf("metadata size %d is not aligned", size)
+	}
+	metadata := data[8 : 8+size]
+	msg := &arrowTable{flatbuffers.Table{Bytes: metadata, Pos: flatbuffers.GetUOffsetT(metadata)}}
+	bodyLength := int(msg.int64(3))
+	body := data[8+size : 8+size+bodyLength]
+	return msg, body, data[8+size+bodyLength:]
+}
+
+func checkArrowBatch(t *testing.T, batch *arrowTable, body []byte) {
+	t.Helper()
+	if rows := batch.int64(0); rows != 2 {
+		t.Fatalf("expected 2 rows, got %d", rows)
+	}
+	vec, n := batch.vector(2)
+	buffer := func(i int) []byte {
+		pos := vec + flatbuffers.UOffsetT(16*i)
+		offset, length := batch.GetInt64(pos), batch.GetInt64(pos+8)
+		if offset%8 != 0 {
+			t.Fatalf("buffer %d offset %d is not aligned", i, offset)
+		}
+		return body[offset : offset+length]
+	}
+	// station: validity, offsets, data; min: validity, values; ...
+	if n != 3+2*5 {
+		t.Fatalf("expected 13 buffers, got %d", n)
+	}
+	if names := string(buffer(2)); names != "ab" {
+		t.Errorf("expected station data %q, got %q", "ab", names)
+	}
+	mean := buffer(6)
+	if v := math.Float64frombits(binary.LittleEndian.Uint64(mean[8:])); v != 2.0 {
+		t.Errorf("expected mean 2.0 for b, got %v", v)
+	}
+	if c := binary.LittleEndian.Uint64(buffer(10)[8:]); c != 2 {
+		t.Errorf("expected count 2 for b, got %d", c)
+	}
+	if v := math.Float64frombits(binary.LittleEndian.Uint64(buffer(12)[8:])); v != 0.5 {
+		t.Errorf("expected stddev 0.5 for b, got %v", v)
+	}
+}
+
+func TestWriteArrowStream(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testResults().WriteArrowStream(buf, WriteOptions{Columns: []Column{StddevColumn}}); err != nil {
+		t.Fatal(err)
+	}
+	msg, _, rest := readArrowMessage(t, buf.Bytes())
+	if msg.byte(1) != arrowHeaderSchema {
+		t.Fatalf("expected schema message, got header type %d", msg.byte(1))
+	}
+	expected := "station min mean max count stddev"
+	if names := strings.Join(arrowFieldNames(t, msg.table(2)), " "); names != expected {
+		t.Errorf("expected fields %q, got %q", expected, names)
+	}
+
+	msg, body, rest := readArrowMessage(t, rest)
+	if msg.byte(1) != arrowHeaderRecordBatch {
+		t.Fatalf("expected record batch message, got header type %d", msg.byte(1))
+	}
+	checkArrowBatch(t, msg.table(2), body)
+	if !bytes.Equal(rest, []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}) {
+		t.Errorf("expected end of stream marker, got %x", rest)
+	}
+}
+
+func TestWriteArrow(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testResults().WriteArrow(buf, WriteOptions{Columns: []Column{StddevColumn}}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("ARROW1\x00\x00")) || !bytes.HasSuffix(data, arrowMagic) {
+		t.Fatal("missing arrow file magic")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+	footerBytes := data[len(data)-10-size : len(data)-10]
+	footer := &arrowTable{flatbuffers.Table{Bytes: footerBytes, Pos: flatbuffers.GetUOffsetT(footerBytes)}}
+	if names := arrowFieldNames(t, footer.table(1)); len(names) != 6 {
+		t.Errorf("expected 6 fields in footer schema, got %q", names)
+	}
+
+	vec, n := footer.vector(3)
+	if n != 1 {
+		t.Fatalf("expected 1 record batch block, got %d", n)
+	}
+	offset := footer.GetInt64(vec)
+	metadataLength := footer.GetInt32(vec + 8)
+	bodyLength := footer.GetInt64(vec + 16)
+	msg, body, _ := readArrowMessage(t, data[offset:])
+	if int64(len(body)) != bodyLength || 8+len(msg.Bytes) != int(metadataLength) {
+		t.Errorf("block does not match message: metadata %d, body %d", metadataLength, bodyLength)
+	}
+	checkArrowBatch(t, msg.table(2), body)
+}
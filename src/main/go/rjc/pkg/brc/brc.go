@@ -0,0 +1,580 @@
+// Package brc 实现1BRC的聚合引擎：解析`name;value`格式的测量数据，
+// 按站点统计最小值、平均值和最大值。
+package brc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Engine 决定输入数据如何读取并分发给worker
+type Engine string
+
+const (
+	// EngineScanner 由单个goroutine顺序读取，再按行分批分发，支持不能seek的流
+	EngineScanner Engine = "scanner"
+	// EngineMmap 将整个文件映射到内存，每个worker解析自己的一段
+	EngineMmap Engine = "mmap"
+	// EngineChunk 预先将文件按换行符切分成多个字节区间，每个worker独立读取并解析自己的区间
+	EngineChunk Engine = "chunk"
+	// EngineIOUring 与EngineScanner相同，但通过Linux的io_uring同时提交多个读取请求，只支持普通文件
+	EngineIOUring Engine = "io_uring"
+	// EngineParquet 读取Parquet文件的station和temperature列，每个worker依次领取一个row group
+	EngineParquet Engine = "parquet"
+)
+
+type options struct {
+	engine      Engine
+	workers     int
+	compression Compression
+	progress    *Progress
+	logger      *slog.Logger
+	strict      bool
+	strictSpec  bool
+	malformed   *Malformed
+	quoted      bool
+	window      time.Duration
+	percentiles []float64
+	exactMedian bool
+	histogram   bool
+
+	checkOverflow bool
+	skipMinMax    bool
+	countOnly     bool
+	normalizeKeys bool
+	foldCase      CaseFolding
+
+	filterPrefix string
+	filterRegex  *regexp.Regexp
+	groupBy      GroupBy
+
+	checkpoint         string
+	checkpointInterval time.Duration
+	resume             bool
+
+	rangeStart int64
+	rangeEnd   int64
+	sample     *Sample
+
+	maxMemory int64
+	hugePages bool
+	numa      bool
+	autoTune  bool
+	raceSafe  bool
+
+	streamInFlight  int
+	streamChunkSize int
+
+	hash      Hash
+	hashStats *HashStats
+	runStats  *RunStats
+
+	dumpPartials string
+	rateLimit    *RateLimiter
+
+	ctx context.Context
+
+	sharedTable bool
+}
+
+// Option 配置Process的行为
+type Option func(*options)
+
+// WithEngine 指定输入引擎，默认Parquet文件使用EngineParquet，其它普通文件使用EngineChunk，
+// 其它输入使用EngineScanner
+func WithEngine(engine Engine) Option {
+	return func(o *options) {
+		o.engine = engine
+	}
+}
+
+// WithWorkers 指定并行解析的worker数量，0表示自动，即min(8, runtime.NumCPU())
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		o.workers = workers
+	}
+}
+
+// WithCompression 指定输入的压缩格式，默认根据文件头自动识别；
+// 压缩的输入总是以流的方式解压，并通过EngineScanner分发给worker
+func WithCompression(compression Compression) Option {
+	return func(o *options) {
+		o.compression = compression
+	}
+}
+
+// WithProgress 在处理过程中持续更新p，可以在其它goroutine中读取
+func WithProgress(p *Progress) Option {
+	return func(o *options) {
+		o.progress = p
+	}
+}
+
+// WithStrict 开启严格模式，校验每一行是否符合`name;-?\d?\d\.\d`格式，
+// 遇到不合法的行时Process返回*ParseError
+func WithStrict(strict bool) Option {
+	return func(o *options) {
+		o.strict = strict
+	}
+}
+
+// WithPercentiles 为每个站点估计指定的分位数（0到100之间，如50、90、99），
+// 结果保存在Station.Percentiles中
+func WithPercentiles(percentiles ...float64) Option {
+	return func(o *options) {
+		o.percentiles = percentiles
+	}
+}
+
+// WithOverflowCheck 检查每个站点的Sum和SumSq是否超出int64的范围，溢出时Process返回*OverflowError。
+// 挑战的数据不可能溢出，该选项用于取值范围更大的输入，会略微降低解析速度
+func WithOverflowCheck(check bool) Option {
+	return func(o *options) {
+		o.checkOverflow = check
+	}
+}
+
+// WithHugePages 让EngineMmap的映射尽量使用透明大页（Linux的MADV_HUGEPAGE），
+// 减少扫描大文件时的TLB miss；内核或文件系统不支持时没有效果
+func WithHugePages(hugePages bool) Option {
+	return func(o *options) {
+		o.hugePages = hugePages
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Process 聚合path指定文件中的所有测量数据，path也可以是IsRemote的对象存储URL
+func Process(path string, opts ...Option) (Results, error) {
+	file, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return process(file, newOptions(opts))
+}
+
+// ProcessReader 聚合r中的所有测量数据，r可以是stdin、管道等不支持seek的流，
+// 此时只能使用EngineScanner
+func ProcessReader(r io.Reader, opts ...Option) (Results, error) {
+	return process(r, newOptions(opts))
+}
+
+// ProcessBytes 聚合内存中的测量数据，不需要打开文件，可以用于js/wasm等没有文件系统的环境。
+// 默认与EngineMmap一样由各个worker直接解析data的不同部分，压缩的数据以流的方式解压
+func ProcessBytes(data []byte, opts ...Option) (Results, error) {
+	return process(&memoryReader{Reader: bytes.NewReader(data), data: data}, newOptions(opts))
+}
+
+// memoryReader 是ProcessBytes的输入，EngineMmap直接解析data，其它引擎按流读取
+type memoryReader struct {
+	*bytes.Reader
+	data []byte
+}
+
+// job 是一次聚合的运行状态，各个引擎通过它解析数据并汇报进度
+type job struct {
+	options
+	workers int
+
+	mu  sync.Mutex
+	err error
+	// 已知最早的错误偏移+1，0表示没有错误
+	errOffset atomic.Int64
+
+	// 输入的总大小，未知时为0
+	size int64
+	// 从checkpoint恢复时的起始偏移和保存的状态
+	start int64
+	saved *MergedStatistics
+	// 指定了WithRange时处理区间的终点，为0时处理到输入末尾
+	end int64
+	// 缓冲区和哈希表的大小，未设置时使用默认大小
+	memory memoryPlan
+	// 站点名的哈希函数，nil表示hashName
+	hash func(name []byte) uint64
+	// 开启WithSharedTable时所有worker共用的哈希表
+	shared *sharedTable
+	// stopped 表示WithContext的ctx结束时有worker提前停止，parsed 是开启WithContext时已经解析的字节数
+	stopped atomic.Bool
+	parsed  atomic.Int64
+	// live 是开启WithProgress时创建的所有Statistic，供Progress.Snapshot读取
+	liveMu sync.Mutex
+	live   []*Statistic
+}
+
+func (j *job) newStatistic() *Statistic {
+	size := j.memory.tableSize
+	if size == 0 {
+		size = initialTableSize
+	}
+	s := &Statistic{table: newTable(size)}
+	if len(j.percentiles) > 0 {
+		s.EnableSketches()
+	}
+	if j.exactMedian || j.histogram {
+		s.EnableCounts()
+	}
+	if j.checkOverflow {
+		s.EnableOverflowCheck()
+	}
+	s.filter = j.newFilter()
+	s.group = j.newGroup()
+	s.window = j.window
+	s.hash = j.hash
+	s.spec = j.strictSpec
+	// 分位数、中位数和直方图需要完整的累计值
+	s.skipMinMax = j.skipMinMax && len(j.percentiles) == 0 && !j.exactMedian && !j.histogram
+	s.normalize = j.normalizeKeys
+	s.foldCase = j.foldCase
+	if j.shared != nil {
+		s.shared = j.shared
+		// 共享哈希表引用keyArena中的站点名
+		s.table.keys.shared = true
+	}
+	if j.progress != nil {
+		j.liveMu.Lock()
+		j.live = append(j.live, s)
+		j.liveMu.Unlock()
+	}
+	return s
+}
+
+// fail 记录解析错误，多个worker都出错时保留偏移最小的一个
+func (j *job) fail(err *ParseError) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if prev, ok := j.err.(*ParseError); !ok || err.Offset < prev.Offset {
+		j.err = err
+		j.errOffset.Store(err.Offset + 1)
+	}
+}
+
+func (j *job) failed() bool {
+	return j.errOffset.Load() != 0
+}
+
+// skip 判断offset之前是否已经出现错误
+func (j *job) skip(offset int64) bool {
+	e := j.errOffset.Load()
+	return e != 0 && offset >= e-1
+}
+
+// parse 解析lines，offset是lines在输入中的起始偏移，仅用于报告错误；
+// 已经出错时跳过错误之后的数据，但仍然解析之前的数据，保证报告的是第一个错误
+func (j *job) parse(s *Statistic, lines []byte, offset int64) {
+	if j.skip(offset) {
+		return
+	}
+	if j.rateLimit == nil {
+		j.parseBatch(s, lines, offset)
+		return
+	}
+	// 在批次之外等待，不阻塞Progress.Snapshot，也不计入worker的忙碌时间
+	rows := s.rows
+	j.parseBatch(s, lines, offset)
+	ctx := j.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	j.rateLimit.Wait(ctx, int(s.rows-rows), len(lines))
+}
+
+// parseBatch 解析一个批次并更新进度和运行统计
+func (j *job) parseBatch(s *Statistic, lines []byte, offset int64) {
+	if j.progress != nil {
+		// Progress.Snapshot在两个批次之间读取s
+		s.live.Lock()
+		defer s.live.Unlock()
+	}
+	rows := s.rows
+	if j.logger != nil || j.runStats != nil || j.dumpPartials != "" {
+		defer j.logBatch(s, offset, len(lines), time.Now())
+	}
+	if j.countOnly {
+		s.rows += countRecords(lines)
+	} else if j.malformed != nil {
+		j.parseSkipping(s, lines, offset)
+	} else if j.window > 0 {
+		var err *ParseError
+		if errors.As(s.ParseAndAddLinesWindowed(lines, offset, j.strict || j.strictSpec), &err) {
+			j.fail(err)
+		}
+	} else if j.quoted {
+		var err *ParseError
+		if errors.As(s.ParseAndAddLinesQuoted(lines, offset, j.strict || j.strictSpec), &err) {
+			j.fail(err)
+		}
+	} else if j.strict || j.strictSpec {
+		var err *ParseError
+		if errors.As(s.ParseAndAddLinesStrict(lines, offset), &err) {
+			j.fail(err)
+		}
+	} else {
+		s.ParseAndAddLines(lines)
+	}
+	if j.ctx != nil {
+		j.parsed.Add(int64(len(lines)))
+	}
+	if j.progress != nil {
+		j.progress.bytes.Add(int64(len(lines)))
+		j.progress.rows.Add(s.rows - rows)
+	}
+}
+
+func process(r io.Reader, o options) (Results, error) {
+	merged, err := aggregate(r, o)
+	if merged == nil {
+		return nil, err
+	}
+	// 被中断时err是InterruptedError，同时返回部分结果
+	return merged.results(o), err
+}
+
+// aggregate 聚合r中的所有测量数据，返回合并后的状态
+func aggregate(r io.Reader, o options) (*MergedStatistics, error) {
+	started := time.Now()
+	j := &job{options: o, workers: o.workers}
+	if j.workers <= 0 {
+		j.workers = min(8, runtime.NumCPU())
+	}
+	var statistics []*Statistic
+	var err error
+	if j.hash, err = hashFunc(o.hash); err != nil {
+		return nil, err
+	}
+	if err = checkFoldCase(o.foldCase); err != nil {
+		return nil, err
+	}
+	if err = o.checkWindow(); err != nil {
+		return nil, err
+	}
+	if o.sharedTable {
+		if err = o.checkSharedTable(); err != nil {
+			return nil, err
+		}
+		j.shared = newSharedTable(sharedTableSize)
+	}
+	// 识别压缩格式之后r可能被包装，需要先判断
+	stream := isStream(r)
+	if stream && o.ctx != nil {
+		var stop func() bool
+		r, stop = interruptible(o.ctx, r)
+		defer stop()
+	}
+	compression := o.compression
+	if compression == CompressionAuto {
+		r, compression, err = sniffCompression(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if compression != CompressionNone {
+		if o.engine != "" && o.engine != EngineScanner {
+			return nil, fmt.Errorf("%s engine does not support compressed input", o.engine)
+		}
+		zr, close, err := decompress(r, compression)
+		if err != nil {
+			return nil, err
+		}
+		defer close()
+		r = zr
+	}
+
+	if o.checkpoint != "" {
+		if o.engine != "" && o.engine != EngineScanner {
+			return nil, fmt.Errorf("%s engine does not support checkpoints", o.engine)
+		}
+		o.engine = EngineScanner
+	}
+	if o.engine == "" {
+		o.engine = defaultEngine(r)
+	}
+	if stream && o.engine != EngineScanner {
+		// FIFO、socket等不能按偏移读取，也没有大小，不能划分区间，改为流式读取
+		o.engine = EngineScanner
+	}
+	if o.sample != nil {
+		if err = o.checkSample(o.engine); err != nil {
+			return nil, err
+		}
+	}
+	if j.memory, err = planMemory(o.engine, j.workers, o.maxMemory); err != nil {
+		return nil, err
+	}
+	j.workers = j.memory.workers
+	if o.engine == EngineScanner {
+		if err = j.applyStreaming(); err != nil {
+			return nil, err
+		}
+	}
+	if compression == CompressionNone {
+		j.size = inputSize(r)
+	}
+	if o.progress != nil {
+		o.progress.total.Store(j.size)
+		defer o.progress.attach(j)()
+	}
+	if o.rangeStart != 0 || o.rangeEnd != 0 {
+		if r, err = j.applyRange(r, o.engine); err != nil {
+			return nil, err
+		}
+	}
+	if o.resume && o.checkpoint != "" {
+		r, err = j.loadCheckpoint(r, compression != CompressionNone)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch o.engine {
+	case EngineScanner:
+		statistics, err = j.scanStatistics(r)
+	case EngineMmap:
+		if m, ok := r.(*memoryReader); ok {
+			statistics, err = j.bytesStatistics(m.data)
+			break
+		}
+		file, ok := r.(*os.File)
+		if !ok {
+			return nil, errors.New("mmap engine requires a file or in-memory input")
+		}
+		if o.autoTune {
+			statistics, err = j.autoTune(file, false, func() ([]*Statistic, error) { return j.mmapStatistics(file) })
+		} else {
+			statistics, err = j.mmapStatistics(file)
+		}
+	case EngineChunk:
+		file, ok := r.(randomAccess)
+		if _, mem := r.(*memoryReader); !ok || mem {
+			return nil, errors.New("chunk engine requires a file")
+		}
+		if o.autoTune {
+			statistics, err = j.autoTune(file, true, func() ([]*Statistic, error) { return j.chunkStatistics(file) })
+		} else {
+			statistics, err = j.chunkStatistics(file)
+		}
+	case EngineIOUring:
+		file, ok := r.(*os.File)
+		if !ok || j.size == 0 {
+			return nil, errors.New("io_uring engine requires an uncompressed regular file")
+		}
+		statistics, err = j.uringStatistics(file)
+	case EngineParquet:
+		file, ok := r.(*os.File)
+		if !ok {
+			return nil, errors.New("parquet engine requires a file")
+		}
+		statistics, err = j.parquetStatistics(file)
+	default:
+		err = fmt.Errorf("unknown engine %q, want scanner, mmap, chunk, io_uring or parquet", o.engine)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if j.err != nil {
+		return nil, j.err
+	}
+	j.closeLive()
+	if o.hashStats != nil {
+		*o.hashStats = collectHashStats(statistics)
+	}
+	if o.logger != nil {
+		j.logWorkers(statistics, time.Since(started))
+	}
+	if o.runStats != nil {
+		j.collectRunStats(statistics, o.engine, time.Since(started))
+	}
+	if o.dumpPartials != "" {
+		if err = j.writePartials(statistics); err != nil {
+			return nil, err
+		}
+	}
+	mergeStart := time.Now()
+	var merged *MergedStatistics
+	if j.shared != nil {
+		if j.shared.full.Load() {
+			return nil, errSharedTableFull
+		}
+		merged = j.shared.merged()
+		for _, s := range statistics {
+			merged.rows += s.rows
+		}
+	} else {
+		merged = mergeStatistics(statistics...)
+	}
+	if j.saved != nil {
+		merged.Merge(j.saved)
+	}
+	if o.logger != nil {
+		o.logger.Debug("merged", "engine", o.engine, "workers", len(statistics), "stations", len(merged.index),
+			"rows", merged.rows, "duration", time.Since(mergeStart))
+	}
+	if o.runStats != nil {
+		o.runStats.Merge = time.Since(mergeStart)
+		o.runStats.Wall = time.Since(started)
+		o.runStats.Rows = merged.rows
+		o.runStats.Stations = len(merged.index)
+	}
+	if o.checkOverflow && merged.overflowed != "" {
+		return nil, &OverflowError{Station: merged.overflowed}
+	}
+	if err := merged.checkCountsRange(); err != nil {
+		return nil, err
+	}
+	return merged, j.interruptedError(merged)
+}
+
+// inputSize 返回普通文件或内存输入的大小，其它输入返回0
+func inputSize(r io.Reader) int64 {
+	if m, ok := r.(*memoryReader); ok {
+		return int64(len(m.data))
+	}
+	if file, ok := r.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	}
+	if o, ok := r.(*remoteObject); ok {
+		return o.size
+	}
+	return 0
+}
+
+// isStream 报告r是否是FIFO、socket、字符设备等不是普通文件的*os.File
+func isStream(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && !info.Mode().IsRegular()
+}
+
+func defaultEngine(r io.Reader) Engine {
+	if _, ok := r.(*memoryReader); ok {
+		return EngineMmap
+	}
+	if inputSize(r) > 0 {
+		if file, ok := r.(*os.File); ok && isParquet(file) {
+			return EngineParquet
+		}
+		return EngineChunk
+	}
+	return EngineScanner
+}
@@ -0,0 +1,363 @@
+package brc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func writeMeasurements(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcess(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-0.1")
+	const expected = "{Bulawayo=-0.1/4.4/8.9, Hamburg=-3.4/4.3/12.0, Palembang=38.8/38.8/38.8}\n"
+
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		for _, workers := range []int{0, 1, 3, 32} {
+			results, err := Process(path, WithEngine(engine), WithWorkers(workers))
+			if err != nil {
+				t.Fatalf("%s/%d: %v", engine, workers, err)
+			}
+			buf := &bytes.Buffer{}
+			if _, err := results.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != expected {
+				t.Errorf("%s/%d: expected %q, got %q", engine, workers, expected, buf.String())
+			}
+		}
+	}
+}
+
+// Windows上生成的文件以CRLF结尾，超过minTaskSize时任务边界可能落在'\r'和'\n'之间
+func TestProcessCRLFTasks(t *testing.T) {
+	data := strings.Repeat("Hamburg;12.0\r\nBulawayo;8.9\r\nHamburg;-3.4\r\nPalembang;38.8\r\n", 50000)
+	path := writeMeasurements(t, data+"Bulawayo;-0.1\r\n")
+	const expected = "{Bulawayo=-0.1/8.9/8.9, Hamburg=-3.4/4.3/12.0, Palembang=38.8/38.8/38.8}\n"
+
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		for _, strict := range []bool{false, true} {
+			results, err := Process(path, WithEngine(engine), WithWorkers(3), WithStrict(strict))
+			if err != nil {
+				t.Fatalf("%s/%v: %v", engine, strict, err)
+			}
+			buf := &bytes.Buffer{}
+			if _, err := results.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != expected {
+				t.Errorf("%s/%v: expected %q, got %q", engine, strict, expected, buf.String())
+			}
+		}
+	}
+}
+
+func TestProcessBoundaries(t *testing.T) {
+	path := writeMeasurements(t, "zero;-0.0\nzero;0.0\nzero;-0.0\nhot;99.9\nhot;99.9\ncold;-99.9\n"+
+		"digit;5.0\ndigit;-5.0\ndigit;-0.1\nnear;-0.1\nnear;0.0\nnear;-0.0\nnear;0.0\n")
+	const expected = "{cold=-99.9/-99.9/-99.9, digit=-5.0/0.0/5.0, hot=99.9/99.9/99.9, near=-0.1/0.0/0.0, zero=0.0/0.0/0.0}\n"
+
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		for _, strict := range []bool{false, true} {
+			results, err := Process(path, WithEngine(engine), WithWorkers(3), WithStrict(strict))
+			if err != nil {
+				t.Fatalf("%s/%v: %v", engine, strict, err)
+			}
+			buf := &bytes.Buffer{}
+			if _, err := results.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != expected {
+				t.Errorf("%s/%v: expected %q, got %q", engine, strict, expected, buf.String())
+			}
+		}
+	}
+}
+
+func TestProcessUnknownEngine(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\n")
+	if _, err := Process(path, WithEngine("foo")); err == nil {
+		t.Error("expected error for unknown engine")
+	}
+}
+
+func TestProcessReader(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		for i := 0; i < 1000; i++ {
+			io.WriteString(w, "Hamburg;12.0\nBulawayo;-8.9\n")
+		}
+		w.Close()
+	}()
+
+	results, err := ProcessReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Count != 1000 || results[1].Sum != 120*1000 {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	if _, err := ProcessReader(strings.NewReader("Hamburg;12.0\n"), WithEngine(EngineMmap)); err == nil {
+		t.Error("expected error for mmap engine on a non-file reader")
+	}
+}
+
+func TestProcessBytes(t *testing.T) {
+	data := []byte(strings.Repeat("Hamburg;12.0\nBulawayo;-8.9\n", 1000))
+	gz := &bytes.Buffer{}
+	zw := gzip.NewWriter(gz)
+	zw.Write(data)
+	zw.Close()
+
+	for name, input := range map[string][]byte{"plain": data, "gzip": gz.Bytes()} {
+		for _, engine := range []Engine{"", EngineScanner} {
+			results, err := ProcessBytes(input, WithEngine(engine), WithWorkers(3))
+			if err != nil {
+				t.Fatalf("%s/%s: %v", name, engine, err)
+			}
+			if len(results) != 2 || results[0].Count != 1000 || results[1].Sum != 120*1000 {
+				t.Errorf("%s/%s: unexpected results %+v", name, engine, results)
+			}
+		}
+	}
+	if results, err := ProcessBytes(nil); err != nil || len(results) != 0 {
+		t.Errorf("expected no results, got %+v, %v", results, err)
+	}
+	if _, err := ProcessBytes(data, WithEngine(EngineChunk)); err == nil {
+		t.Error("expected error for chunk engine on in-memory input")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	var data []byte
+	for i := 0; len(data) < 5*minTaskSize; i++ {
+		data = append(data, strings.Repeat("x", i%100)+";1.0\n"...)
+	}
+	for _, workers := range []int{1, 2, 3} {
+		sched := newScheduler(bytes.NewReader(data), int64(len(data)), workers)
+		var prev int64
+		tasks := 0
+		for {
+			start, end, err := sched.next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start == end {
+				break
+			}
+			tasks++
+			if start != prev || end < start {
+				t.Fatalf("workers=%d: unexpected task [%d, %d) after %d", workers, start, end, prev)
+			}
+			if data[end-1] != '\n' {
+				t.Errorf("workers=%d: task end %d is not at a line start", workers, end)
+			}
+			prev = end
+		}
+		if prev != int64(len(data)) {
+			t.Errorf("workers=%d: tasks cover %d of %d bytes", workers, prev, len(data))
+		}
+		if workers > 1 && tasks <= workers {
+			t.Errorf("workers=%d: expected more tasks than workers, got %d", workers, tasks)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	data := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8"
+	s := NewStatistic()
+	// 缓冲区只够容纳一行多一点，覆盖跨缓冲区的半行拼接
+	if err := (&job{}).parseRange(strings.NewReader(data), s, make([]byte, 16), 0, int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	s.Results().WriteTo(buf)
+	if expected := "{Bulawayo=8.9/8.9/8.9, Hamburg=-3.4/4.3/12.0, Palembang=38.8/38.8/38.8}\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	if err := (&job{}).parseRange(strings.NewReader(data), NewStatistic(), make([]byte, 8), 0, int64(len(data))); err != errLineTooLong {
+		t.Errorf("expected errLineTooLong, got %v", err)
+	}
+}
+
+func TestBlockReader(t *testing.T) {
+	data := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8"
+	// next返回之后缓冲区可以被任意覆盖，不完整的行已经复制到blockReader中
+	buf := make([]byte, 16)
+	br := &blockReader{r: strings.NewReader(data)}
+	var blocks []string
+	for !br.eof {
+		block, err := br.next(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, string(block))
+		for i := range buf {
+			buf[i] = 'x'
+		}
+	}
+	expected := []string{"Hamburg;12.0\n", "Bulawayo;8.9\n", "Hamburg;-3.4\n", "Palembang;38.8"}
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("expected %q, got %q", expected, blocks)
+	}
+
+	br = &blockReader{r: strings.NewReader(data)}
+	if _, err := br.next(make([]byte, 8)); err != errLineTooLong {
+		t.Errorf("expected errLineTooLong, got %v", err)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	var data bytes.Buffer
+	for i := 0; data.Len() < 256*1024; i++ {
+		fmt.Fprintf(&data, "station%d;%d.0\n", i, i%100)
+	}
+	data.WriteString("last;1.0")
+
+	j := &job{options: options{raceSafe: true}, workers: 4}
+	ch := make(chan batch, 1024)
+	j.dispatch(ch, data.Bytes(), 100, nil, &sync.WaitGroup{})
+	close(ch)
+	var joined []byte
+	prev := data.Len()
+	for b := range ch {
+		if b.offset != 100+int64(len(joined)) {
+			t.Fatalf("expected offset %d, got %d", 100+len(joined), b.offset)
+		}
+		joined = append(joined, b.lines...)
+		if len(joined) < data.Len() && b.lines[len(b.lines)-1] != '\n' {
+			t.Fatalf("batch at %d does not end with a newline", b.offset)
+		}
+		if len(b.lines) > prev+64 {
+			t.Errorf("batch at %d grew from %d to %d bytes", b.offset, prev, len(b.lines))
+		}
+		prev = len(b.lines)
+	}
+	if !bytes.Equal(joined, data.Bytes()) {
+		t.Error("batches do not cover the data")
+	}
+}
+
+func TestLease(t *testing.T) {
+	free := make(chan []byte, 1)
+	l := newLease(make([]byte, 4), free)
+	l.acquire()
+	l.acquire()
+	l.release()
+	l.release()
+	if len(free) != 0 {
+		t.Fatal("buffer returned while the reader still holds it")
+	}
+	l.release()
+	if len(free) != 1 {
+		t.Fatal("expected buffer to be returned after the last release")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on double release")
+		}
+	}()
+	l.release()
+}
+
+func TestProcessRaceSafe(t *testing.T) {
+	// 小缓冲区保证批次跨越多个缓冲区，缓冲区被反复复用
+	var data bytes.Buffer
+	for i := 0; data.Len() < 1<<20; i++ {
+		fmt.Fprintf(&data, "station%d;%d.%d\n", i%97, i%50-25, i%10)
+	}
+	expected, err := ProcessNaive(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raceSafe := range []bool{false, true} {
+		results, err := ProcessReader(bytes.NewReader(data.Bytes()), WithWorkers(4), WithMaxMemory(1<<20), WithRaceSafe(raceSafe))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("race safe %v: expected %v, got %v", raceSafe, expected, results)
+		}
+	}
+}
+
+func TestProcessProgress(t *testing.T) {
+	const data = "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	path := writeMeasurements(t, data)
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		p := &Progress{}
+		if _, err := Process(path, WithEngine(engine), WithProgress(p)); err != nil {
+			t.Fatal(err)
+		}
+		if p.Rows() != 3 || p.Total() != int64(len(data)) || p.Bytes() == 0 || p.Bytes() > p.Total() {
+			t.Errorf("%s: unexpected progress rows=%d bytes=%d total=%d", engine, p.Rows(), p.Bytes(), p.Total())
+		}
+	}
+}
+
+func TestProcessRange(t *testing.T) {
+	data := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-0.1\nHamburg;7.7\n"
+	path := writeMeasurements(t, data)
+	expected, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		// 分界点落在行首、行中间和换行符上
+		for _, cut := range []int64{1, 13, 14, 30, 50, int64(len(data)) - 1} {
+			var merged *MergedStatistics
+			for _, r := range [][2]int64{{0, cut}, {cut, 0}} {
+				s, err := ProcessState(path, WithEngine(engine), WithWorkers(2), WithRange(r[0], r[1]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if merged == nil {
+					merged = s
+				} else {
+					merged.Merge(s)
+				}
+			}
+			if actual := merged.Results(); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s/%d: expected %+v, got %+v", engine, cut, expected, actual)
+			}
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	data := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-0.1\nHamburg;7.7\n"
+	r := strings.NewReader(data)
+	for _, parts := range []int{1, 2, 3, 6, 20} {
+		offsets, err := SplitLines(r, int64(len(data)), parts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(offsets) != parts+1 || offsets[0] != 0 || offsets[parts] != int64(len(data)) {
+			t.Fatalf("%d: unexpected offsets %v", parts, offsets)
+		}
+		for i := 1; i < parts; i++ {
+			if off := offsets[i]; off < offsets[i-1] || off > 0 && off < int64(len(data)) && data[off-1] != '\n' {
+				t.Errorf("%d: offset %d is not at a line start: %v", parts, off, offsets)
+			}
+		}
+	}
+	if _, err := SplitLines(r, int64(len(data)), 0); err == nil {
+		t.Error("expected error for 0 parts")
+	}
+}
@@ -0,0 +1,234 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// HyperLogLog的精度，2^14个寄存器，标准误差约为0.8%
+const hllPrecision = 14
+
+// HyperLogLog 估计不同站点名的个数，占用16KB，可以合并
+type HyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// Add 记录一个站点名
+func (h *HyperLogLog) Add(name []byte) {
+	x := xxhash64(name)
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	h.registers[idx] = max(h.registers[idx], rank)
+}
+
+// Merge 合并o中记录的站点名
+func (h *HyperLogLog) Merge(o *HyperLogLog) {
+	for i, r := range o.registers {
+		h.registers[i] = max(h.registers[i], r)
+	}
+}
+
+// Estimate 返回不同站点名的近似个数，基数较小时使用线性计数
+func (h *HyperLogLog) Estimate() uint64 {
+	const m = float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(e))
+}
+
+// 每个worker统计出现次数的站点名数量的上限，不超过该数量的站点的次数是精确的
+const frequentCapacity = 1024
+
+// frequent 用Misra-Gries算法记录出现次数最多的站点名，次数至多少计总行数/(frequentCapacity+1)
+type frequent map[string]int64
+
+func (f frequent) add(name []byte) {
+	if _, ok := f[string(name)]; ok || len(f) < frequentCapacity {
+		f[string(name)]++
+		return
+	}
+	// 所有计数减1，新的站点名也抵消一次，总计数每次减少frequentCapacity+1，均摊开销为常数
+	for k, n := range f {
+		if n == 1 {
+			delete(f, k)
+		} else {
+			f[k] = n - 1
+		}
+	}
+}
+
+// merge 累加o的计数，超出容量时减去第frequentCapacity+1大的计数，只保留仍为正的站点名
+func (f frequent) merge(o frequent) {
+	for k, n := range o {
+		f[k] += n
+	}
+	if len(f) <= frequentCapacity {
+		return
+	}
+	counts := make([]int64, 0, len(f))
+	for _, n := range f {
+		counts = append(counts, n)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] > counts[j] })
+	cut := counts[frequentCapacity]
+	for k, n := range f {
+		if n <= cut {
+			delete(f, k)
+		} else {
+			f[k] = n - cut
+		}
+	}
+}
+
+// NameCount 是站点名及其出现次数
+type NameCount struct {
+	Name  string
+	Count int64
+}
+
+// Cardinality 是Inspect的结果
+type Cardinality struct {
+	// Rows 是输入的行数
+	Rows int64
+	// Distinct 是不同站点名的近似个数
+	Distinct uint64
+	// Top 是出现次数最多的站点名，按次数从多到少排序。站点名不超过1024个时次数是精确的，
+	// 否则是下界，误差不超过Rows/1025
+	Top []NameCount
+}
+
+// cardinalityWorker 是一个worker的部分结果
+type cardinalityWorker struct {
+	rows     int64
+	hll      HyperLogLog
+	frequent frequent
+}
+
+// add 记录lines中每一行的站点名，lines由完整的行组成，最后一行可以没有换行符
+func (c *cardinalityWorker) add(lines []byte) {
+	for len(lines) > 0 {
+		end := bytes.IndexByte(lines, '\n')
+		if end < 0 {
+			end = len(lines)
+		}
+		line := lines[:end]
+		lines = lines[min(end+1, len(lines)):]
+		if len(line) == 0 {
+			continue
+		}
+		name := line
+		if i := bytes.IndexByte(line, ';'); i >= 0 {
+			name = line[:i]
+		}
+		c.rows++
+		c.hll.Add(name)
+		c.frequent.add(name)
+	}
+}
+
+// Inspect 不做聚合，只统计path的行数、不同站点名的近似个数和出现次数最多的top个站点名，
+// 内存占用与站点数无关，可以在处理大文件之前快速检查。支持WithWorkers，输入必须是未压缩的普通文件
+func Inspect(path string, top int, opts ...Option) (*Cardinality, error) {
+	o := newOptions(opts)
+	workers := o.workers
+	if workers <= 0 {
+		workers = min(8, runtime.NumCPU())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errors.New("inspect requires a regular file")
+	}
+
+	sched := newScheduler(file, info.Size(), workers)
+	parts := make([]*cardinalityWorker, workers)
+	errs := make([]error, workers)
+	wg := &sync.WaitGroup{}
+	for i := range parts {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			c := &cardinalityWorker{frequent: make(frequent)}
+			parts[idx] = c
+			buf := make([]byte, chunkBufferSize)
+			for {
+				start, end, err := sched.next()
+				if err != nil || start == end {
+					errs[idx] = err
+					return
+				}
+				if errs[idx] = readLines(file, buf, start, end, c.add); errs[idx] != nil {
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	merged := parts[0]
+	for _, c := range parts[1:] {
+		merged.rows += c.rows
+		merged.hll.Merge(&c.hll)
+		merged.frequent.merge(c.frequent)
+	}
+	result := &Cardinality{Rows: merged.rows, Distinct: merged.hll.Estimate()}
+	for name, n := range merged.frequent {
+		result.Top = append(result.Top, NameCount{name, n})
+	}
+	sort.Slice(result.Top, func(i, j int) bool {
+		a, b := result.Top[i], result.Top[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Name < b.Name)
+	})
+	result.Top = result.Top[:min(top, len(result.Top))]
+	return result, nil
+}
+
+// readLines 用buf读取[start, end)区间，每次把其中完整的行传给f，区间起点总是某一行的开头
+func readLines(file io.ReaderAt, buf []byte, start, end int64, f func([]byte)) error {
+	carry := 0
+	for start < end {
+		n, err := file.ReadAt(buf[carry:min(len(buf), carry+int(end-start))], start)
+		if n == 0 && err != nil {
+			return err
+		}
+		start += int64(n)
+		data := buf[:carry+n]
+		if start >= end {
+			f(data)
+			return nil
+		}
+		last := bytes.LastIndexByte(data, '\n')
+		if last < 0 {
+			return errLineTooLong
+		}
+		f(data[:last+1])
+		carry = copy(buf, data[last+1:])
+	}
+	return nil
+}
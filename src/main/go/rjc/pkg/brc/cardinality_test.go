@@ -0,0 +1,52 @@
+package brc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100_000} {
+		h := &HyperLogLog{}
+		other := &HyperLogLog{}
+		for i := 0; i < n; i++ {
+			h.Add([]byte(fmt.Sprintf("station %d", i)))
+			// 重复的站点名不影响估计
+			other.Add([]byte(fmt.Sprintf("station %d", i/2)))
+		}
+		h.Merge(other)
+		if got := h.Estimate(); math.Abs(float64(got)-float64(n)) > 0.03*float64(n) {
+			t.Errorf("%d: got %d", n, got)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	path := writeMeasurements(t, strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n", 1000)+"Hamburg;1.0")
+	for _, workers := range []int{1, 4} {
+		c, err := Inspect(path, 2, WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &Cardinality{Rows: 4001, Distinct: 3, Top: []NameCount{{"Hamburg", 2001}, {"Bulawayo", 1000}}}
+		if !reflect.DeepEqual(c, expected) {
+			t.Errorf("%d workers: expected %+v, got %+v", workers, expected, c)
+		}
+	}
+
+	// 站点名超过frequentCapacity时出现次数最多的仍然保留
+	var b strings.Builder
+	for i := 0; i < 3*frequentCapacity; i++ {
+		fmt.Fprintf(&b, "station %d;1.0\nHot;2.0\n", i)
+	}
+	c, err := Inspect(writeMeasurements(t, b.String()), 1, WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Top) != 1 || c.Top[0].Name != "Hot" || c.Rows != 6*frequentCapacity {
+		t.Errorf("unexpected %+v", c)
+	}
+}
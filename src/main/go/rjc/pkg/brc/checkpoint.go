@@ -0,0 +1,99 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithCheckpoint 每隔interval将已处理的输入偏移和聚合状态写入path，
+// 配合WithResume可以在进程中断后从上次的位置继续。仅EngineScanner支持checkpoint
+func WithCheckpoint(path string, interval time.Duration) Option {
+	return func(o *options) {
+		o.checkpoint = path
+		o.checkpointInterval = interval
+	}
+}
+
+// WithResume 从WithCheckpoint指定的文件恢复：跳过已处理的输入并合并保存的状态，
+// checkpoint文件不存在时从头开始处理
+func WithResume(resume bool) Option {
+	return func(o *options) {
+		o.resume = resume
+	}
+}
+
+// saveCheckpoint 原子地写入checkpoint，调用时statistics恰好包含offset之前的全部数据
+func (j *job) saveCheckpoint(statistics []*Statistic, offset int64) (err error) {
+	merged := mergeStatistics(statistics...)
+	if j.saved != nil {
+		merged.Merge(j.saved)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(j.checkpoint), "."+filepath.Base(j.checkpoint)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err = writeState(f, merged, offset, j.size); err != nil {
+		return err
+	}
+	if err = f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), j.checkpoint)
+}
+
+// loadCheckpoint 读取checkpoint并将r定位到保存的偏移，返回新的r
+func (j *job) loadCheckpoint(r io.Reader, compressed bool) (io.Reader, error) {
+	f, err := os.Open(j.checkpoint)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	saved, offset, size, err := readState(f)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint %s: %w", j.checkpoint, err)
+	}
+	if size != 0 && j.size != 0 && size != j.size {
+		return nil, fmt.Errorf("checkpoint %s was written for a %d byte input, got %d bytes", j.checkpoint, size, j.size)
+	}
+
+	// 压缩输入的偏移是解压后的偏移，只能通过读取跳过
+	if seeker, ok := r.(io.Seeker); ok && !compressed {
+		_, err = seeker.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, r, offset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("skip to checkpoint offset %d: %w", offset, err)
+	}
+	j.saved, j.start = saved, offset
+	if j.progress != nil {
+		j.progress.bytes.Add(offset)
+	}
+	return r, nil
+}
+
+// checkpointDue 判断距离上次checkpoint是否已经超过间隔
+func (j *job) checkpointDue(last time.Time) bool {
+	return j.checkpoint != "" && j.checkpointInterval > 0 && time.Since(last) >= j.checkpointInterval
+}
@@ -0,0 +1,128 @@
+package brc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	s := NewStatistic()
+	s.EnableSketches()
+	s.EnableCounts()
+	s.ParseAndAddLines([]byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;0.0\n"))
+	merged := mergeStatistics(s)
+
+	buf := &bytes.Buffer{}
+	if err := writeState(buf, merged, 42, 100); err != nil {
+		t.Fatal(err)
+	}
+	decoded, offset, size, err := readState(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 42 || size != 100 {
+		t.Errorf("expected offset 42 and size 100, got %d and %d", offset, size)
+	}
+	if !reflect.DeepEqual(decoded.Results(), merged.Results()) {
+		t.Errorf("expected %v, got %v", merged.Results(), decoded.Results())
+	}
+
+	if _, _, _, err := readState(bytes.NewReader(buf.Bytes()[:buf.Len()-3])); err == nil {
+		t.Error("expected error for truncated state")
+	}
+}
+
+func TestProcessResume(t *testing.T) {
+	const head = "Hamburg;12.0\nBulawayo;8.9\n"
+	const tail = "Hamburg;-3.4\nPalembang;38.8\nBulawayo;-0.1\n"
+	path := writeMeasurements(t, head+tail)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+
+	// 模拟在处理完head之后中断
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte(head))
+	f, err := os.Create(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeState(f, mergeStatistics(s), int64(len(head)), int64(len(head+tail))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	results, err := Process(path, WithCheckpoint(checkpoint, time.Hour), WithResume(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+
+	// 输入大小不一致时拒绝恢复
+	other := writeMeasurements(t, tail)
+	if _, err := Process(other, WithCheckpoint(checkpoint, time.Hour), WithResume(true)); err == nil {
+		t.Error("expected error for checkpoint of a different input")
+	}
+}
+
+func TestProcessWritesCheckpoint(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\n")
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+	if _, err := Process(path, WithCheckpoint(checkpoint, time.Nanosecond)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, _, _, err := readState(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Process(path, WithEngine(EngineChunk), WithCheckpoint(checkpoint, time.Hour)); err == nil {
+		t.Error("expected error for checkpoint with chunk engine")
+	}
+}
+
+func TestMergeSavedStates(t *testing.T) {
+	lines := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\nBulawayo;-0.1\nHamburg;7.7\n"
+	half := strings.Index(lines, "Palembang")
+	opts := []Option{WithPercentiles(50), WithExactMedian(true)}
+	expected, err := Process(writeMeasurements(t, lines), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var merged *MergedStatistics
+	for _, part := range []string{lines[:half], lines[half:]} {
+		s, err := ProcessState(writeMeasurements(t, part), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := s.WriteState(buf); err != nil {
+			t.Fatal(err)
+		}
+		s, err = ReadState(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if merged == nil {
+			merged = s
+		} else {
+			merged.Merge(s)
+		}
+	}
+	if actual := merged.Results(opts...); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
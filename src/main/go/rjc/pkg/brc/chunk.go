@@ -0,0 +1,79 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+const chunkBufferSize = 8 * 1024 * 1024
+
+var errLineTooLong = errors.New("line too long")
+
+// 读取并解析[start, end)区间，区间起点总是某一行的开头
+func (j *job) parseRange(file io.ReaderAt, s *Statistic, buf []byte, start, end int64) error {
+	carry := 0
+	for start < end && !j.skip(start-int64(carry)) && !j.interrupted() {
+		n, err := file.ReadAt(buf[carry:min(len(buf), carry+int(end-start))], start)
+		if n == 0 && err != nil {
+			return err
+		}
+		offset := start - int64(carry)
+		start += int64(n)
+		data := buf[:carry+n]
+		if start >= end {
+			j.parse(s, data, offset)
+			return nil
+		}
+
+		last := bytes.LastIndexByte(data, '\n')
+		if last < 0 {
+			return errLineTooLong
+		}
+		j.parse(s, data[:last+1], offset)
+		carry = copy(buf, data[last+1:])
+	}
+	return nil
+}
+
+// 每个worker从scheduler领取文件区间，独立读取并解析
+func (j *job) chunkStatistics(file io.ReaderAt) ([]*Statistic, error) {
+	num := j.workers
+	p, err := j.newPlacement(file, j.size)
+	if err != nil {
+		return nil, err
+	}
+
+	statistics := make([]*Statistic, num)
+	errs := make([]error, num)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			sched, err := p.start(idx)
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+			statistics[idx] = j.newStatistic()
+			buf := make([]byte, j.memory.chunkBufferSize)
+			for !j.interrupted() {
+				start, end, err := sched.next()
+				if err == nil && start < end {
+					err = j.parseRange(file, statistics[idx], buf, start, end)
+				}
+				if err != nil || start == end {
+					errs[idx] = err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return statistics, nil
+}
@@ -0,0 +1,83 @@
+package brc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Threshold 是用颜色标出站点统计量的条件
+type Threshold struct {
+	// Metric 是MetricMin、MetricMean或MetricMax
+	Metric Metric
+	// Below 为true时标出小于Value的值，否则标出大于Value的值
+	Below bool
+	// Value 以opts.Unit的度为单位
+	Value float64
+	// Color 是red、green、yellow、blue、magenta或cyan
+	Color string
+}
+
+// colorCodes 是各颜色的ANSI转义序列的参数
+var colorCodes = map[string]string{
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+}
+
+// ParseThresholds 解析以逗号分隔的`metric>value:color`或`metric<value:color`，如`max>35:red,min<-20:blue`
+func ParseThresholds(spec string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		cond, color, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid threshold %q, want metric>value:color", item)
+		}
+		if _, ok := colorCodes[color]; !ok {
+			return nil, fmt.Errorf("unknown color %q, want red, green, yellow, blue, magenta or cyan", color)
+		}
+		t := Threshold{Color: color}
+		i := strings.IndexAny(cond, "<>")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid threshold %q, want metric>value:color", item)
+		}
+		t.Metric, t.Below = Metric(cond[:i]), cond[i] == '<'
+		switch t.Metric {
+		case MetricMin, MetricMean, MetricMax:
+		default:
+			return nil, fmt.Errorf("unknown metric %q, want min, mean or max", t.Metric)
+		}
+		var err error
+		if t.Value, err = strconv.ParseFloat(cond[i+1:], 64); err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %w", item, err)
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// colorize 用第一个满足条件的opts.Thresholds的颜色包裹站点by统计量格式化后的值cell
+func (s *Station) colorize(by Metric, cell string, opts WriteOptions) string {
+	for _, t := range opts.Thresholds {
+		if t.Metric != by {
+			continue
+		}
+		var v float64
+		switch by {
+		case MetricMin:
+			v = opts.temperature(float64(s.Min) / 10)
+		case MetricMax:
+			v = opts.temperature(float64(s.Max) / 10)
+		default:
+			v = opts.temperature(s.mean())
+		}
+		if (t.Below && v < t.Value) || (!t.Below && v > t.Value) {
+			return "\x1b[" + colorCodes[t.Color] + "m" + cell + "\x1b[0m"
+		}
+	}
+	return cell
+}
@@ -0,0 +1,57 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds("max>35:red, min<-20.5:blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Threshold{{MetricMax, false, 35, "red"}, {MetricMin, true, -20.5, "blue"}}
+	if len(thresholds) != 2 || thresholds[0] != expected[0] || thresholds[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, thresholds)
+	}
+	for _, spec := range []string{"", "max>35", "max=35:red", "count>3:red", "max>x:red", "max>35:pink"} {
+		if _, err := ParseThresholds(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestWriteColor(t *testing.T) {
+	thresholds, err := ParseThresholds("max>2.0:red,mean>1.0:yellow,min<-1.0:blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := testResults().Write(buf, WriteOptions{Thresholds: thresholds}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "{a=\x1b[34m-2.0\x1b[0m/-2.0/-2.0, b=1.5/\x1b[33m2.0\x1b[0m/\x1b[31m2.5\x1b[0m}\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// 阈值以输出单位比较，对齐时不计算转义序列
+	thresholds, err = ParseThresholds("max>36:red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := testResults().WriteTable(buf, 0, WriteOptions{Unit: UnitFahrenheit, Thresholds: thresholds}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "" +
+		"station   min  mean   max  count\n" +
+		"-------  ----  ----  ----  -----\n" +
+		"a        28.4  28.4  28.4      1\n" +
+		"b        34.7  35.6  \x1b[31m36.5\x1b[0m      2\n" +
+		"-------  ----  ----  ----  -----\n" +
+		"total    28.4  33.2  36.5      3\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, buf.String())
+	}
+}
@@ -0,0 +1,90 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression 是输入数据的压缩格式
+type Compression string
+
+const (
+	// CompressionAuto 根据文件头的magic number自动识别
+	CompressionAuto Compression = ""
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func detectCompression(header []byte) Compression {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// 自动识别时，文件通过ReadAt读取文件头，其它流通过bufio.Reader预读，不消耗数据
+func sniffCompression(r io.Reader) (io.Reader, Compression, error) {
+	if m, ok := r.(*memoryReader); ok {
+		return r, detectCompression(m.data[:min(len(m.data), len(zstdMagic))]), nil
+	}
+	header := make([]byte, len(zstdMagic))
+	if file, ok := r.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			n, err := file.ReadAt(header, 0)
+			if n == 0 && err != nil && err != io.EOF {
+				return nil, "", err
+			}
+			return r, detectCompression(header[:n]), nil
+		}
+	}
+	if o, ok := r.(*remoteObject); ok {
+		n, err := o.ReadAt(header, 0)
+		if n == 0 && err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		return r, detectCompression(header[:n]), nil
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	header, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+	return br, detectCompression(header), nil
+}
+
+// decompress 返回解压后的流，close用于释放解码器
+func decompress(r io.Reader, c Compression) (_ io.Reader, close func(), err error) {
+	switch c {
+	case CompressionGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, func() { zr.Close() }, nil
+	case CompressionZstd:
+		// 并发解码多个block，避免解压成为瓶颈
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(0))
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown compression %q, want gzip or zstd", c)
+	}
+}
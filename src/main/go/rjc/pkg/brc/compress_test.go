@@ -0,0 +1,62 @@
+package brc
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressedInput(t *testing.T) {
+	const data = "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	const expected = "{Bulawayo=8.9/8.9/8.9, Hamburg=-3.4/4.3/12.0}\n"
+
+	for _, tc := range []struct {
+		compression Compression
+		compress    func(w io.Writer) io.WriteCloser
+	}{
+		{CompressionGzip, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{CompressionZstd, func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return zw
+		}},
+	} {
+		compressed := &bytes.Buffer{}
+		zw := tc.compress(compressed)
+		io.WriteString(zw, data)
+		zw.Close()
+
+		path := filepath.Join(t.TempDir(), "measurements.txt."+string(tc.compression))
+		if err := os.WriteFile(path, compressed.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, process := range map[string]func() (Results, error){
+			"auto file":     func() (Results, error) { return Process(path) },
+			"explicit file": func() (Results, error) { return Process(path, WithCompression(tc.compression)) },
+			"auto stream":   func() (Results, error) { return ProcessReader(bytes.NewReader(compressed.Bytes())) },
+		} {
+			results, err := process()
+			if err != nil {
+				t.Fatalf("%s %s: %v", tc.compression, name, err)
+			}
+			buf := &strings.Builder{}
+			results.WriteTo(buf)
+			if buf.String() != expected {
+				t.Errorf("%s %s: expected %q, got %q", tc.compression, name, expected, buf.String())
+			}
+		}
+
+		if _, err := Process(path, WithEngine(EngineChunk)); err == nil {
+			t.Errorf("%s: expected error for chunk engine", tc.compression)
+		}
+	}
+}
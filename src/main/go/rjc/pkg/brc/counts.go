@@ -0,0 +1,116 @@
+package brc
+
+import "fmt"
+
+// 挑战规定温度在[-99.9, 99.9]之间，以0.1度为单位共1999个取值
+const (
+	minValue  = -999
+	maxValue  = 999
+	numValues = maxValue - minValue + 1
+)
+
+// Counts 是单个站点每个取值出现的次数，用于计算精确的中位数。超出范围的值计入两端，
+// 这样的站点由checkCountsRange报告为*CountsRangeError
+type Counts [numValues]uint64
+
+func (c *Counts) add(val int64) {
+	c[min(maxValue, max(minValue, val))-minValue]++
+}
+
+func (c *Counts) merge(o *Counts) {
+	for i, n := range o {
+		c[i] += n
+	}
+}
+
+// nth 返回从小到大第k个值（从0开始）
+func (c *Counts) nth(k uint64) int64 {
+	var seen uint64
+	for i, n := range c {
+		seen += n
+		if seen > k {
+			return int64(i) + minValue
+		}
+	}
+	return maxValue
+}
+
+// Median 返回精确的中位数，单位为0.1度；值的个数为偶数时取中间两个值的平均
+func (c *Counts) Median() float64 {
+	var count uint64
+	for _, n := range c {
+		count += n
+	}
+	if count == 0 {
+		return 0
+	}
+	if count%2 == 1 {
+		return float64(c.nth(count / 2))
+	}
+	return float64(c.nth(count/2-1)+c.nth(count/2)) / 2
+}
+
+// Histogram 将[lo, hi]等分为n个左闭右开的桶，返回n+1个边界和每个桶的计数，单位为0.1度。
+// 桶宽取整数，最后一个桶的上界可能大于hi
+func (c *Counts) Histogram(lo, hi int64, n int) (bounds []int64, counts []uint64) {
+	lo, hi = max(lo, minValue), min(hi, maxValue)
+	width := max(1, (hi-lo+int64(n))/int64(n))
+	bounds = make([]int64, n+1)
+	for i := range bounds {
+		bounds[i] = lo + int64(i)*width
+	}
+	counts = make([]uint64, n)
+	for v := lo; v <= hi; v++ {
+		counts[min(int64(n-1), (v-lo)/width)] += c[v-minValue]
+	}
+	return bounds, counts
+}
+
+// CountsRangeError 表示开启WithExactMedian或WithHistogram时某个站点有超出[-99.9, 99.9]的值，
+// 这些值无法精确地记录在Counts中，中位数和直方图会是错误的
+type CountsRangeError struct {
+	Station string
+	// Value 是超出范围的最小值或最大值，单位为0.1度
+	Value int64
+}
+
+func (e *CountsRangeError) Error() string {
+	return fmt.Sprintf("station %q has value %s outside [-99.9, 99.9], exact medians and histograms are not possible", e.Station, formatTenths(e.Value))
+}
+
+// checkCountsRange 检查记录了Counts的站点的最小值和最大值，有超出范围的值时返回站点名最小的站点的*CountsRangeError。
+// 开启Counts时总是记录最小值和最大值
+func (s *MergedStatistics) checkCountsRange() error {
+	var err *CountsRangeError
+	for i := range s.measures {
+		m := &s.measures[i]
+		if m.Counts == nil || m.Min >= minValue && m.Max <= maxValue {
+			continue
+		}
+		if name := s.names[i]; err == nil || name < err.Station {
+			err = &CountsRangeError{Station: name, Value: m.Max}
+			if m.Min < minValue {
+				err.Value = m.Min
+			}
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+// WithExactMedian 为每个站点记录每个取值出现的次数，输出精确的中位数。
+// 每个站点每个worker额外占用约16KB内存，有超出[-99.9, 99.9]的值时处理返回*CountsRangeError
+func WithExactMedian(exact bool) Option {
+	return func(o *options) {
+		o.exactMedian = exact
+	}
+}
+
+// WithHistogram 为每个站点记录每个取值出现的次数，使WriteOptions.Buckets可以输出直方图，内存占用和取值范围同WithExactMedian
+func WithHistogram(histogram bool) Option {
+	return func(o *options) {
+		o.histogram = histogram
+	}
+}
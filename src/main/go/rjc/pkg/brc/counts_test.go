@@ -0,0 +1,120 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountsMedian(t *testing.T) {
+	for _, tc := range []struct {
+		values []int64
+		median float64
+	}{
+		{nil, 0},
+		{[]int64{5}, 5},
+		{[]int64{3, -1, 7}, 3},
+		{[]int64{1, 2, 3, 10}, 2.5},
+		{[]int64{-999, 999}, 0},
+		{[]int64{-5000, -5000, 5000}, -999},
+	} {
+		c := &Counts{}
+		for _, v := range tc.values {
+			c.add(v)
+		}
+		if got := c.Median(); got != tc.median {
+			t.Errorf("%v: expected median %v, got %v", tc.values, tc.median, got)
+		}
+	}
+}
+
+func TestProcessExactMedian(t *testing.T) {
+	path := writeMeasurements(t, "a;1.0\na;2.0\na;3.0\na;40.0\nb;-7.5\nb;-7.4\n")
+	for _, engine := range []Engine{EngineScanner, EngineChunk} {
+		results, err := Process(path, WithEngine(engine), WithWorkers(3), WithExactMedian(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := results.Write(buf, WriteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		want := "{a=1.0/11.5/40.0/2.5, b=-7.5/-7.4/-7.4/-7.4}\n"
+		if buf.String() != want {
+			t.Errorf("%s: expected %q, got %q", engine, want, buf.String())
+		}
+	}
+}
+
+func TestProcessCountsRange(t *testing.T) {
+	path := writeMeasurements(t, "a;1.0\nb;150.0\nc;-120.5\n")
+	for _, opt := range []Option{WithExactMedian(true), WithHistogram(true)} {
+		_, err := Process(path, WithWorkers(2), opt)
+		var rangeErr *CountsRangeError
+		if !errors.As(err, &rangeErr) || rangeErr.Station != "b" || rangeErr.Value != 1500 {
+			t.Errorf("expected range error for b, got %v", err)
+		}
+	}
+	if _, err := Process(path); err != nil {
+		t.Errorf("out of range values without counts: %v", err)
+	}
+}
+
+func TestCountsHistogram(t *testing.T) {
+	c := &Counts{}
+	for _, v := range []int64{-10, -5, 0, 0, 7, 20} {
+		c.add(v)
+	}
+	bounds, counts := c.Histogram(-10, 20, 3)
+	if !reflect.DeepEqual(bounds, []int64{-10, 1, 12, 23}) || !reflect.DeepEqual(counts, []uint64{4, 1, 1}) {
+		t.Errorf("unexpected histogram %v %v", bounds, counts)
+	}
+	bounds, counts = c.Histogram(20, 20, 2)
+	if !reflect.DeepEqual(bounds, []int64{20, 21, 22}) || !reflect.DeepEqual(counts, []uint64{1, 0}) {
+		t.Errorf("unexpected histogram %v %v", bounds, counts)
+	}
+}
+
+func TestWriteHistogram(t *testing.T) {
+	path := writeMeasurements(t, "a;-1.0\na;-0.5\na;0.0\na;0.0\na;0.7\na;2.0\nb;3.0\n")
+	results, err := Process(path, WithHistogram(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := WriteOptions{Buckets: 3}
+	buf := &bytes.Buffer{}
+	if err := results.WriteJSON(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":{"min":-1.0,"mean":0.2,"max":2.0,"count":6,"histogram":{"bounds":[-1.0,0.1,1.2,2.3],"counts":[4,1,1]}},` +
+		`"b":{"min":3.0,"mean":3.0,"max":3.0,"count":1,"histogram":{"bounds":[3.0,3.1,3.2,3.3],"counts":[1,0,0]}}}` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected %s, got %s", want, buf.String())
+	}
+
+	buf.Reset()
+	if err := results.WriteCSV(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	want = "station,min,mean,max,count,histogram_bounds,histogram_counts\n" +
+		"a,-1.0,0.2,2.0,6,-1.0 0.1 1.2 2.3,4 1 1\n" +
+		"b,3.0,3.0,3.0,1,3.0 3.1 3.2 3.3,1 0 0\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	// 没有开启WithHistogram时不输出直方图
+	results, err = Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := results.WriteCSV(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "histogram") {
+		t.Errorf("unexpected histogram in %q", buf.String())
+	}
+}
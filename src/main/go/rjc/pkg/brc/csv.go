@@ -0,0 +1,90 @@
+package brc
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formatTenths 将以0.1度为单位的整数格式化为一位小数，0不带负号
+func formatTenths(v int64) string {
+	b := make([]byte, 0, 8)
+	if v < 0 {
+		b = append(b, '-')
+		v = -v
+	}
+	b = strconv.AppendInt(b, v/10, 10)
+	return string(append(b, '.', byte('0'+v%10)))
+}
+
+// formatValue 将以度为单位的值保留一位小数，与参考实现的Math.round一样.5向正无穷方向舍入
+func formatValue(v float64) string {
+	return formatTenths(int64(math.Floor(v*10 + 0.5)))
+}
+
+// WriteCSV 输出带表头的`station,min,mean,max,count`格式，中位数、分位数、opts.Columns和opts.Metadata的各列作为额外的列追加在最后，
+// 设置了opts.Buckets时再追加histogram_bounds和histogram_counts两列，其中的值以空格分隔
+func (r Results) WriteCSV(w io.Writer, opts WriteOptions) error {
+	cw := csv.NewWriter(w)
+	if len(opts.Aggregates) > 0 {
+		return r.writeAggregatesCSV(cw, opts)
+	}
+	header := []string{"station", "min", "mean", "max", "count"}
+	if len(r) > 0 {
+		for _, f := range r[0].extraFields(opts) {
+			header = append(header, f.name)
+		}
+		if opts.Metadata != nil {
+			header = append(header, opts.Metadata.columns...)
+		}
+		if _, _, ok := r[0].histogram(opts); ok {
+			header = append(header, "histogram_bounds", "histogram_counts")
+		}
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := range r {
+		s := &r[i]
+		lo, mean, hi := s.formatMinMeanMax(opts)
+		record := []string{
+			s.Name,
+			lo,
+			mean,
+			hi,
+			strconv.Itoa(s.Count),
+		}
+		for _, f := range s.extraFields(opts) {
+			record = append(record, formatValue(f.value))
+		}
+		record = append(record, s.metadataFields(opts)...)
+		if bounds, counts, ok := s.histogram(opts); ok {
+			record = append(record, strings.Join(bounds, " "), strings.Join(counts, " "))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeAggregatesCSV 输出`station`和opts.Aggregates中的各列
+func (r Results) writeAggregatesCSV(cw *csv.Writer, opts WriteOptions) error {
+	header := []string{"station"}
+	for _, a := range opts.Aggregates {
+		header = append(header, string(a))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := range r {
+		if err := cw.Write(append([]string{r[i].Name}, r[i].aggregateValues(opts)...)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,170 @@
+package brc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseDerived 解析`name=expr`格式的派生统计量，expr是min、max、mean、sum、count、stddev、variance
+// 和数字通过+、-、*、/和括号组成的算术表达式，如`range=max-min`、`spread=max-mean`。
+// 换算单位时先分别换算各个统计量再计算表达式
+func ParseDerived(spec string) (Column, error) {
+	name, expr, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return Column{}, fmt.Errorf("invalid derived metric %q, want name=expr", spec)
+	}
+	p := &exprParser{input: expr}
+	eval, err := p.parse()
+	if err != nil {
+		return Column{}, fmt.Errorf("invalid derived metric %q: %w", spec, err)
+	}
+	return Column{
+		Name:  name,
+		Value: func(s *Station) float64 { return eval(s, WriteOptions{}) },
+		value: eval,
+	}, nil
+}
+
+// exprFunc 计算表达式在站点s上的值，温度换算为opts.Unit
+type exprFunc func(s *Station, opts WriteOptions) float64
+
+// exprVariables 是表达式中可以使用的统计量
+var exprVariables = map[string]exprFunc{
+	"min":      func(s *Station, opts WriteOptions) float64 { return opts.temperature(float64(s.Min) / 10) },
+	"max":      func(s *Station, opts WriteOptions) float64 { return opts.temperature(float64(s.Max) / 10) },
+	"mean":     func(s *Station, opts WriteOptions) float64 { return opts.temperature(s.mean()) },
+	"sum":      func(s *Station, opts WriteOptions) float64 { return opts.temperature(s.mean()) * float64(s.Count) },
+	"count":    func(s *Station, opts WriteOptions) float64 { return float64(s.Count) },
+	"stddev":   func(s *Station, opts WriteOptions) float64 { return opts.difference(s.Stddev(), 1) },
+	"variance": func(s *Station, opts WriteOptions) float64 { return opts.difference(s.Variance(), 2) },
+}
+
+// exprParser 是递归下降的表达式解析器：
+//
+//	expr   = term {("+" | "-") term}
+//	term   = factor {("*" | "/") factor}
+//	factor = number | variable | "(" expr ")" | "-" factor
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) parse() (exprFunc, error) {
+	f, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.input[p.pos:], p.pos)
+	}
+	return f, nil
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// peek 跳过空格后返回下一个字符，没有时返回0
+func (p *exprParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) expr() (exprFunc, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) term() (exprFunc, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) factor() (exprFunc, error) {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		f, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ')' at offset %d", p.pos)
+		}
+		p.pos++
+		return f, nil
+	case c == '-':
+		p.pos++
+		f, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return func(s *Station, opts WriteOptions) float64 { return -f(s, opts) }, nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(*Station, WriteOptions) float64 { return v }, nil
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] >= 'a' && p.input[p.pos] <= 'z' {
+			p.pos++
+		}
+		f, ok := exprVariables[p.input[start:p.pos]]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q, want min, max, mean, sum, count, stddev or variance", p.input[start:p.pos])
+		}
+		return f, nil
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+	}
+}
+
+func binaryExpr(op byte, left, right exprFunc) exprFunc {
+	switch op {
+	case '+':
+		return func(s *Station, opts WriteOptions) float64 { return left(s, opts) + right(s, opts) }
+	case '-':
+		return func(s *Station, opts WriteOptions) float64 { return left(s, opts) - right(s, opts) }
+	case '*':
+		return func(s *Station, opts WriteOptions) float64 { return left(s, opts) * right(s, opts) }
+	default:
+		return func(s *Station, opts WriteOptions) float64 { return left(s, opts) / right(s, opts) }
+	}
+}
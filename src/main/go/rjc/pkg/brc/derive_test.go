@@ -0,0 +1,61 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseDerived(t *testing.T) {
+	b := testResults()[1]
+	for spec, expected := range map[string]float64{
+		"range=max-min":         1.0,
+		"spread = max - mean":   0.5,
+		"x=-min+2*(max-1)":      1.5,
+		"x=sum/count":           2.0,
+		"x=(max-min)/stddev":    2.0,
+		"x=variance*4 - -1":     2.0,
+		"x=1 + 2 * 3 - 4 / 2.5": 5.4,
+	} {
+		c, err := ParseDerived(spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		if v := c.Value(&b); v < expected-1e-9 || v > expected+1e-9 {
+			t.Errorf("%q: expected %v, got %v", spec, expected, v)
+		}
+	}
+	for _, spec := range []string{"", "range", "=max", "x=", "x=max-", "x=(max", "x=max)", "x=median", "x=max min", "x=1..2", "x=max%2"} {
+		if _, err := ParseDerived(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestWriteDerived(t *testing.T) {
+	rng, err := ParseDerived("range=max-min")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid, err := ParseDerived("mid=(min+max)/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		opts     WriteOptions
+		expected string
+	}{
+		{WriteOptions{}, "{a=-2.0/-2.0/-2.0/0.0/-2.0, b=1.5/2.0/2.5/1.0/2.0}\n"},
+		// 温差按比例换算，温度按公式换算
+		{WriteOptions{Unit: UnitFahrenheit}, "{a=28.4/28.4/28.4/0.0/28.4, b=34.7/35.6/36.5/1.8/35.6}\n"},
+	} {
+		buf := &bytes.Buffer{}
+		tc.opts.Columns = []Column{rng, mid}
+		if err := testResults().Write(buf, tc.opts); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("unit %s: expected %q, got %q", tc.opts.Unit, tc.expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// maxRunSize 是一个run和一行的最大字节数，保证run中的偏移可以用int32表示
+const maxRunSize = 1 << 30
+
+// mergeFanIn 是一次归并最多同时打开的run数，run更多时先分组归并为更少的run，避免超过打开文件数的限制
+var mergeFanIn = 256
+
+// ExternalSort 按站点名对r中的行做外部归并排序并写入w，同一站点的行保持输入中的顺序，最后一行没有换行时补上换行。
+// 每次读取约runSize字节的完整行作为一个run，由workers个goroutine并行排序后写入tmpDir下的临时文件，
+// 最后每次最多mergeFanIn个run做k路归并，直到得到排序后的输出，内存占用与站点数量无关。
+// 排序中的run除了数据本身，还有每行24字节的[]sortLine索引，挑战数据平均每行约14字节，索引约为run的1.7倍，
+// 因此内存占用约为workers*2.7*runSize，再加上正在读取的runSize。
+// workers为0表示min(8, runtime.NumCPU())，tmpDir为空表示os.TempDir()
+func ExternalSort(r io.Reader, w io.Writer, tmpDir string, runSize int, workers int) error {
+	if runSize <= 0 || runSize > maxRunSize {
+		return errors.New("run size must be positive and at most 1GB")
+	}
+	if workers <= 0 {
+		workers = min(8, runtime.NumCPU())
+	}
+	var runs []string
+	defer func() {
+		for _, run := range runs {
+			if run != "" {
+				os.Remove(run)
+			}
+		}
+	}()
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		err   error
+		slots = make(chan struct{}, workers)
+	)
+	setErr := func(e error) {
+		mu.Lock()
+		if err == nil {
+			err = e
+		}
+		mu.Unlock()
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return err != nil
+	}
+	var carry []byte
+	for !failed() {
+		buf := make([]byte, max(runSize, 2*len(carry)))
+		n := copy(buf, carry)
+		m, readErr := io.ReadFull(r, buf[n:])
+		data := buf[:n+m]
+		eof := readErr == io.EOF || readErr == io.ErrUnexpectedEOF
+		if readErr != nil && !eof {
+			setErr(readErr)
+			break
+		}
+		end := len(data)
+		if !eof {
+			// 一行比run还长时carry会增长，下一次读取更大的缓冲区
+			end = bytes.LastIndexByte(data, '\n') + 1
+		}
+		carry = append(carry[:0], data[end:]...)
+		if len(carry) > maxRunSize {
+			setErr(errors.New("line longer than 1GB"))
+			break
+		}
+		if end > 0 {
+			mu.Lock()
+			i := len(runs)
+			runs = append(runs, "")
+			mu.Unlock()
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(data []byte) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				name, err := writeSortedRun(tmpDir, data)
+				mu.Lock()
+				runs[i] = name
+				mu.Unlock()
+				if err != nil {
+					setErr(err)
+				}
+			}(data[:end])
+		}
+		if eof {
+			break
+		}
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	for len(runs) > mergeFanIn {
+		if runs, err = mergePass(tmpDir, runs); err != nil {
+			return err
+		}
+	}
+	return mergeRuns(w, runs)
+}
+
+// mergePass 将runs中每mergeFanIn个相邻的run归并为tmpDir下的一个新run并删除它们，返回仍然存在的run。
+// 相邻的run归并后保持原来的顺序，所以多轮归并的结果仍然是稳定的
+func mergePass(tmpDir string, runs []string) (next []string, err error) {
+	for i := 0; i < len(runs); i += mergeFanIn {
+		group := runs[i:min(i+mergeFanIn, len(runs))]
+		if len(group) == 1 {
+			next = append(next, group[0])
+			continue
+		}
+		name, err := mergeToRun(tmpDir, group)
+		if err != nil {
+			return append(next, runs[i:]...), err
+		}
+		for _, run := range group {
+			os.Remove(run)
+		}
+		next = append(next, name)
+	}
+	return next, nil
+}
+
+// mergeToRun 将runs归并到tmpDir下的临时文件，返回文件名
+func mergeToRun(tmpDir string, runs []string) (name string, err error) {
+	f, err := os.CreateTemp(tmpDir, "brc-sort-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err = mergeRuns(f, runs); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// sortKey 返回行中的站点名，即最后一个';'之前的部分，没有';'时是整行
+func sortKey(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	if i := bytes.LastIndexByte(line, ';'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// sortLine 是run中的一行data[start:end]，data[start:keyEnd]是它的站点名。
+// prefix是站点名的前8个字节，大多数比较不需要访问data；结构体中没有指针，交换时也没有写屏障
+type sortLine struct {
+	prefix             uint64
+	start, keyEnd, end int32
+}
+
+// keyPrefix 以大端序返回key的前8个字节，不足8个字节时补0，前缀的大小关系与key一致或相等
+func keyPrefix(key []byte) uint64 {
+	var b [8]byte
+	copy(b[:], key)
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// writeSortedRun 将data中的行按站点名稳定排序后写入tmpDir下的临时文件，返回文件名
+func writeSortedRun(tmpDir string, data []byte) (name string, err error) {
+	var lines []sortLine
+	for offset := 0; offset < len(data); {
+		i := bytes.IndexByte(data[offset:], '\n') + 1
+		if i == 0 {
+			i = len(data) - offset
+		}
+		key := sortKey(data[offset : offset+i])
+		lines = append(lines, sortLine{keyPrefix(key), int32(offset), int32(offset + len(key)), int32(offset + i)})
+		offset += i
+	}
+	// 站点名相同时按行的位置排序，结果与稳定排序相同但快得多
+	slices.SortFunc(lines, func(a, b sortLine) int {
+		if a.prefix != b.prefix {
+			return cmp.Compare(a.prefix, b.prefix)
+		}
+		if c := bytes.Compare(data[a.start:a.keyEnd], data[b.start:b.keyEnd]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.start, b.start)
+	})
+
+	f, err := os.CreateTemp(tmpDir, "brc-sort-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	bw := bufio.NewWriterSize(f, 1024*1024)
+	for _, l := range lines {
+		line := data[l.start:l.end]
+		bw.Write(line)
+		if line[len(line)-1] != '\n' {
+			bw.WriteByte('\n')
+		}
+	}
+	if err = bw.Flush(); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// runReader 是归并中的一个run，line是它当前最小的一行
+type runReader struct {
+	f         *os.File
+	r         *bufio.Reader
+	line, key []byte
+	// index 是run在输入中的顺序，站点名相同时先输出前面的run，保证排序是稳定的
+	index int
+}
+
+type runHeap []*runReader
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].key, h[j].key); c != 0 {
+		return c < 0
+	}
+	return h[i].index < h[j].index
+}
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)   { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// next 读取run的下一行，run结束时返回false
+func (rr *runReader) next() (bool, error) {
+	line, err := rr.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// 超长的行需要复制出来拼接
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			line, err = rr.r.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
+	if err == io.EOF && len(line) == 0 {
+		return false, nil
+	}
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	rr.line, rr.key = line, sortKey(line)
+	return true, nil
+}
+
+// mergeRuns 对已排序的run做k路归并并写入w，每个run读完时关闭它的文件
+func mergeRuns(w io.Writer, runs []string) error {
+	h := make(runHeap, 0, len(runs))
+	defer func() {
+		for _, rr := range h {
+			rr.f.Close()
+		}
+	}()
+	for i, run := range runs {
+		f, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		rr := &runReader{f: f, r: bufio.NewReaderSize(f, 64*1024), index: i}
+		ok, err := rr.next()
+		if !ok {
+			f.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		h = append(h, rr)
+	}
+	heap.Init(&h)
+	bw := bufio.NewWriterSize(w, 1024*1024)
+	for len(h) > 0 {
+		rr := h[0]
+		if _, err := bw.Write(rr.line); err != nil {
+			return err
+		}
+		ok, err := rr.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+			rr.f.Close()
+		}
+	}
+	return bw.Flush()
+}
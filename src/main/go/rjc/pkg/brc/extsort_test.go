@@ -0,0 +1,57 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExternalSort(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprintf("station %d;%d.%d\n", r.Intn(50), i/10, i%10))
+	}
+	// 比run还长的行，以及没有换行的最后一行
+	lines = append(lines, strings.Repeat("x", 300)+";1.0\n", "station 7;-1.0")
+	input := strings.Join(lines, "")
+
+	expected := slices.Clone(lines)
+	expected[len(expected)-1] += "\n"
+	slices.SortStableFunc(expected, func(a, b string) int {
+		return strings.Compare(a[:strings.LastIndexByte(a, ';')], b[:strings.LastIndexByte(b, ';')])
+	})
+	for _, runSize := range []int{64, 1000, 1 << 20} {
+		buf := &bytes.Buffer{}
+		if err := ExternalSort(strings.NewReader(input), buf, t.TempDir(), runSize, 3); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != strings.Join(expected, "") {
+			t.Errorf("run size %d: output is not the stable sort of the input", runSize)
+		}
+	}
+
+	// 每次归并两个run时需要多轮归并，每轮结束后只剩下最后一轮的run
+	defer func(fanIn int) { mergeFanIn = fanIn }(mergeFanIn)
+	mergeFanIn = 2
+	tmpDir := t.TempDir()
+	buf := &bytes.Buffer{}
+	if err := ExternalSort(strings.NewReader(input), buf, tmpDir, 64, 3); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != strings.Join(expected, "") {
+		t.Errorf("fan-in 2: output is not the stable sort of the input")
+	}
+	if left, _ := os.ReadDir(tmpDir); len(left) != 0 {
+		t.Errorf("expected temporary runs to be removed, got %d files", len(left))
+	}
+
+	buf = &bytes.Buffer{}
+	if err := ExternalSort(strings.NewReader(""), buf, t.TempDir(), 64, 0); err != nil || buf.Len() != 0 {
+		t.Errorf("expected empty output, got %q, %v", buf, err)
+	}
+}
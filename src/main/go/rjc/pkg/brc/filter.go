@@ -0,0 +1,46 @@
+package brc
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// WithFilterPrefix 只聚合名字以prefix开头的站点
+func WithFilterPrefix(prefix string) Option {
+	return func(o *options) {
+		o.filterPrefix = prefix
+	}
+}
+
+// WithFilterRegex 只聚合名字匹配re的站点，与WithFilterPrefix同时指定时需要两者都满足
+func WithFilterRegex(re *regexp.Regexp) Option {
+	return func(o *options) {
+		o.filterRegex = re
+	}
+}
+
+// newFilter 返回在Statistic.Add中使用的过滤函数，没有过滤条件时返回nil。
+// 正则匹配的结果按站点名缓存，每个站点只匹配一次，因此每个Statistic需要独立的过滤函数
+func (o *options) newFilter() func(name []byte) bool {
+	prefix, re := []byte(o.filterPrefix), o.filterRegex
+	if len(prefix) == 0 && re == nil {
+		return nil
+	}
+	if re == nil {
+		return func(name []byte) bool {
+			return bytes.HasPrefix(name, prefix)
+		}
+	}
+	cache := make(map[string]bool)
+	return func(name []byte) bool {
+		if !bytes.HasPrefix(name, prefix) {
+			return false
+		}
+		match, ok := cache[string(name)]
+		if !ok {
+			match = re.Match(name)
+			cache[string(name)] = match
+		}
+		return match
+	}
+}
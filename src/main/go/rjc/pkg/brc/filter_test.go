@@ -0,0 +1,32 @@
+package brc
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestProcessFilter(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nHalifax;1.0\nPalembang;38.8\nHanoi;20.0\n")
+	for _, tc := range []struct {
+		opts     []Option
+		expected string
+	}{
+		{[]Option{WithFilterPrefix("Ha")}, "{Halifax=1.0/1.0/1.0, Hamburg=-3.4/4.3/12.0, Hanoi=20.0/20.0/20.0}\n"},
+		{[]Option{WithFilterRegex(regexp.MustCompile("an"))}, "{Hanoi=20.0/20.0/20.0, Palembang=38.8/38.8/38.8}\n"},
+		{[]Option{WithFilterPrefix("Ha"), WithFilterRegex(regexp.MustCompile("[xi]$"))}, "{Halifax=1.0/1.0/1.0, Hanoi=20.0/20.0/20.0}\n"},
+		{[]Option{WithFilterPrefix("Z")}, ""},
+	} {
+		for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+			results, err := Process(path, append(tc.opts, WithEngine(engine))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			results.WriteTo(buf)
+			if buf.String() != tc.expected {
+				t.Errorf("%s: expected %q, got %q", engine, tc.expected, buf.String())
+			}
+		}
+	}
+}
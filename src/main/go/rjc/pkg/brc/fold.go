@@ -0,0 +1,89 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/transform"
+)
+
+// CaseFolding 决定聚合前如何折叠站点名的大小写
+type CaseFolding string
+
+const (
+	// FoldNone 不折叠大小写，默认使用
+	FoldNone CaseFolding = ""
+	// FoldASCII 只将ASCII大写字母转换为小写
+	FoldASCII CaseFolding = "ascii"
+	// FoldUnicode 使用完整的Unicode大小写折叠，如"STRASSE"与"straße"折叠为相同的站点名
+	FoldUnicode CaseFolding = "unicode"
+)
+
+// WithFoldCase 在聚合前折叠站点名的大小写，使"Tokyo"和"TOKYO"聚合在一起，结果中的站点名是折叠后的形式。
+// 不需要转换的站点名不会被复制，需要转换时复用每个worker的缓冲区，不会为每一行分配内存
+func WithFoldCase(fold CaseFolding) Option {
+	return func(o *options) {
+		o.foldCase = fold
+	}
+}
+
+func checkFoldCase(fold CaseFolding) error {
+	switch fold {
+	case FoldNone, FoldASCII, FoldUnicode:
+		return nil
+	default:
+		return fmt.Errorf("unknown case folding %q, want ascii or unicode", fold)
+	}
+}
+
+// foldName 返回name折叠大小写后的形式，需要转换时结果位于s的缓冲区中，在下一次调用前有效
+func (s *Statistic) foldName(name []byte) []byte {
+	upper := -1
+	for i, c := range name {
+		if c >= utf8.RuneSelf {
+			if s.foldCase == FoldUnicode {
+				return s.foldUnicode(name)
+			}
+		} else if 'A' <= c && c <= 'Z' && upper < 0 {
+			upper = i
+			if s.foldCase == FoldASCII {
+				break
+			}
+		}
+	}
+	if upper < 0 {
+		return name
+	}
+	s.foldBuf = append(s.foldBuf[:0], name...)
+	for i, c := range s.foldBuf[upper:] {
+		if 'A' <= c && c <= 'Z' {
+			s.foldBuf[upper+i] = c + 'a' - 'A'
+		}
+	}
+	return s.foldBuf
+}
+
+func (s *Statistic) foldUnicode(name []byte) []byte {
+	if s.folder == nil {
+		folder := cases.Fold()
+		s.folder = &folder
+	}
+	if cap(s.foldBuf) < len(name) {
+		s.foldBuf = make([]byte, 2*len(name))
+	}
+	for {
+		s.folder.Reset()
+		buf := s.foldBuf[:cap(s.foldBuf)]
+		n, _, err := s.folder.Transform(buf, name, true)
+		if errors.Is(err, transform.ErrShortDst) {
+			s.foldBuf = make([]byte, 2*len(buf))
+			continue
+		}
+		if err != nil {
+			return name
+		}
+		return buf[:n]
+	}
+}
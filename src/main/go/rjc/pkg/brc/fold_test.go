@@ -0,0 +1,56 @@
+package brc
+
+import "testing"
+
+func TestFoldName(t *testing.T) {
+	tests := []struct {
+		fold           CaseFolding
+		name, expected string
+	}{
+		{FoldASCII, "tokyo", "tokyo"},
+		{FoldASCII, "TOKYO", "tokyo"},
+		{FoldASCII, "ZÜRICH", "zÜrich"},
+		{FoldUnicode, "ZÜRICH", "zürich"},
+		{FoldUnicode, "STRASSE", "strasse"},
+		{FoldUnicode, "Straße", "strasse"},
+		{FoldUnicode, "zürich", "zürich"},
+	}
+	for _, test := range tests {
+		s := NewStatistic()
+		s.foldCase = test.fold
+		if name := s.foldName([]byte(test.name)); string(name) != test.expected {
+			t.Errorf("%s %q: expected %q, got %q", test.fold, test.name, test.expected, name)
+		}
+	}
+}
+
+func TestFoldNameNoAllocs(t *testing.T) {
+	s := NewStatistic()
+	s.foldCase = FoldUnicode
+	lower, upper, unicode := []byte("tokyo"), []byte("TOKYO"), []byte("ZÜRICH")
+	s.foldName(unicode)
+	if allocs := testing.AllocsPerRun(100, func() {
+		s.foldName(lower)
+		s.foldName(upper)
+		s.foldName(unicode)
+	}); allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestProcessFoldCase(t *testing.T) {
+	path := writeMeasurements(t, "Tokyo;10.0\nTOKYO;20.0\ntokyo;30.0\nZürich;1.0\nZÜRICH;3.0\n")
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		results, err := Process(path, WithEngine(engine), WithFoldCase(FoldUnicode))
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if len(results) != 2 || results[0].Name != "tokyo" || results[0].Count != 3 ||
+			results[1].Name != "zürich" || results[1].Count != 2 {
+			t.Errorf("%s: unexpected results %v", engine, results)
+		}
+	}
+	if _, err := Process(path, WithFoldCase("upper")); err == nil {
+		t.Error("expected error for unknown case folding")
+	}
+}
@@ -0,0 +1,105 @@
+package brc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// 读到文件末尾后检查是否有新数据的间隔
+	followPollInterval = 100 * time.Millisecond
+	// 读缓冲区的初始大小，遇到更长的行时加倍
+	followBufferSize = 1024 * 1024
+)
+
+// Follow 像`tail -f`一样持续读取path：读到文件末尾后等待追加的数据，每隔interval在有新数据时
+// 以当前的聚合结果调用emit，直到ctx结束时最后调用一次emit并返回nil。
+// 文件被截断时从头重新读取，已聚合的数据保留。只有完整的行会被聚合，末尾没有换行的行等待后续数据
+func Follow(ctx context.Context, path string, interval time.Duration, emit func(Results) error, opts ...Option) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	o := newOptions(opts)
+	if o.ctx == nil {
+		// ctx结束时WithRateLimit的等待也立即返回
+		o.ctx = ctx
+	}
+	j := &job{options: o, workers: 1}
+	if j.hash, err = hashFunc(o.hash); err != nil {
+		return err
+	}
+	if err = checkFoldCase(o.foldCase); err != nil {
+		return err
+	}
+	if err = o.checkWindow(); err != nil {
+		return err
+	}
+	s := j.newStatistic()
+	buf := make([]byte, followBufferSize)
+	var carry int
+	// offset是buf[0]在文件中的偏移
+	var offset int64
+	emitted := s.rows
+	last := time.Now()
+	flush := func() error {
+		if j.err != nil {
+			return j.err
+		}
+		if s.rows == emitted {
+			return nil
+		}
+		emitted = s.rows
+		last = time.Now()
+		merged := mergeStatistics(s)
+		if err := merged.checkCountsRange(); err != nil {
+			return err
+		}
+		return emit(merged.results(o))
+	}
+
+	for {
+		n, err := file.Read(buf[carry:])
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n > 0 {
+			data := buf[:carry+n]
+			end := bytes.LastIndexByte(data, '\n') + 1
+			j.parse(s, data[:end], offset)
+			offset += int64(end)
+			carry = copy(buf, data[end:])
+			if carry == len(buf) {
+				buf = append(buf, make([]byte, len(buf))...)
+			}
+		}
+		if ctx.Err() != nil {
+			return flush()
+		}
+		if time.Since(last) >= interval {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if n > 0 {
+			continue
+		}
+
+		if info, err := file.Stat(); err == nil && info.Size() < offset+int64(carry) {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset, carry = 0, 0
+		}
+		select {
+		case <-ctx.Done():
+			return flush()
+		case <-time.After(followPollInterval):
+		}
+	}
+}
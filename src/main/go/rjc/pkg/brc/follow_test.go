@@ -0,0 +1,63 @@
+package brc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+	path := writeMeasurements(t, "a;1.0\nb;2.0\na;3")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	emitted := make(chan string, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(ctx, path, 10*time.Millisecond, func(r Results) error {
+			buf := &bytes.Buffer{}
+			r.WriteTo(buf)
+			emitted <- buf.String()
+			return nil
+		})
+	}()
+
+	wait := func(expected string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case got := <-emitted:
+				if got == expected {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %q", expected)
+			}
+		}
+	}
+	// 末尾不完整的行等到换行写入后才被聚合
+	wait("{a=1.0/1.0/1.0, b=2.0/2.0/2.0}\n")
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(".0\nb;-4.0\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	wait("{a=1.0/2.0/3.0, b=-4.0/-1.0/2.0}\n")
+
+	// 截断后从头读取，已聚合的数据保留
+	if err := os.WriteFile(path, []byte("c;5.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wait("{a=1.0/2.0/3.0, b=-4.0/-1.0/2.0, c=5.0/5.0/5.0}\n")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// referenceParse 是ParseAndAddLines的逐行参考实现：跳过行首的'\r'和'\n'，站点名是到下一个';'为止的
+// 所有字节（可能跨越换行），取值由';'之后到'\n'为止的所有数字组成，以'-'开头时为负数
+func referenceParse(data []byte) map[string]M {
+	stations := make(map[string]M)
+	for {
+		data = bytes.TrimLeft(data, "\r\n")
+		name, rest, ok := bytes.Cut(data, []byte{';'})
+		if !ok {
+			return stations
+		}
+		field, next, _ := bytes.Cut(rest, []byte{'\n'})
+		val := int64(0)
+		for _, c := range field {
+			if '0' <= c && c <= '9' {
+				val = val*10 + int64(c-'0')
+			}
+		}
+		if bytes.HasPrefix(field, []byte{'-'}) {
+			val = -val
+		}
+		m, ok := stations[string(name)]
+		if !ok {
+			m = newM()
+		}
+		m.Add(val)
+		stations[string(name)] = m
+		data = next
+	}
+}
+
+// checkResults 比较Statistic的结果与参考实现的结果
+func checkResults(t *testing.T, parser string, data []byte, s *Statistic, expected map[string]M) {
+	t.Helper()
+	results := s.Results()
+	if len(results) != len(expected) {
+		t.Fatalf("%s: expected %d stations, got %d for %q", parser, len(expected), len(results), data)
+	}
+	for _, r := range results {
+		if m, ok := expected[r.Name]; !ok || m != r.M {
+			t.Fatalf("%s: station %q: expected %+v, got %+v for %q", parser, r.Name, m, r.M, data)
+		}
+	}
+}
+
+func FuzzParseAndAddLines(f *testing.F) {
+	for _, seed := range []string{
+		"Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n",
+		"Hamburg;12.0\nBulawayo;8",
+		"Hamburg;",
+		"Hamburg",
+		";1.0\n",
+		"Foo;1.0\r\nBar;-2.0\r\n\r\nFoo;3.0\r",
+		"a;1.0\n\n\nb;-0.0\nc;99.9\nd;-99.9\n",
+		"\xff\xfe;1.0\nSão Paulo;-1.5\n\xe4\xb8\x8a\xe6\xb5\xb7;0.1\n",
+		"no separator\nx;1.0\n",
+		"a;1;2;3\n--1.0\n;;;\n",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		expected := referenceParse(data)
+		for parser, parse := range map[string]func(s *Statistic, lines []byte){
+			"generic": (*Statistic).parseAndAddLinesGeneric,
+			"masked":  (*Statistic).parseAndAddLinesMasked,
+		} {
+			// 解析完成后覆盖输入，结果中的站点名不能引用输入的内存
+			buf := bytes.Clone(data)
+			// 较小的哈希表加快每次执行，同时覆盖扩容
+			s := &Statistic{table: newTable(16)}
+			parse(s, buf)
+			for i := range buf {
+				buf[i] = 0xff
+			}
+			checkResults(t, parser, data, s, expected)
+		}
+
+		// 严格模式接受的输入与参考实现的结果相同；行首的'\r'在严格模式下属于站点名，不做比较
+		if bytes.HasPrefix(data, []byte{'\r'}) || bytes.Contains(data, []byte("\n\r")) {
+			return
+		}
+		s := &Statistic{table: newTable(16)}
+		if s.ParseAndAddLinesStrict(bytes.Clone(data), 0) == nil {
+			checkResults(t, "strict", data, s, expected)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package brc
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected outputs in testdata/golden")
+
+// 参考实现的样例与Java实现共享，只读；testdata/golden中是Go实现额外的样例，可以用-update重新生成
+const samplesDir = "../../../../../test/resources/samples"
+
+// TestGolden 用所有引擎处理每个样例文件，与提交的预期输出比较
+func TestGolden(t *testing.T) {
+	samples, _ := filepath.Glob(filepath.Join(samplesDir, "*.txt"))
+	golden, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.txt"))
+	if len(samples) == 0 || len(golden) == 0 {
+		t.Fatal("no sample files")
+	}
+
+	for _, path := range append(samples, golden...) {
+		name := filepath.Base(path)
+		want := strings.TrimSuffix(path, ".txt") + ".out"
+		if *update && strings.HasPrefix(path, "testdata") {
+			results, err := Process(path, WithStrict(true))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			buf := &bytes.Buffer{}
+			if _, err := results.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(want, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected, err := os.ReadFile(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		check := func(desc string, results Results, err error) {
+			t.Helper()
+			if err != nil {
+				t.Fatalf("%s/%s: %v", name, desc, err)
+			}
+			buf := &bytes.Buffer{}
+			if _, err := results.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), expected) {
+				t.Errorf("%s/%s: expected %q, got %q", name, desc, expected, buf.String())
+			}
+		}
+		for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+			for _, workers := range []int{1, 8} {
+				for _, strict := range []bool{false, true} {
+					results, err := Process(path, WithEngine(engine), WithWorkers(workers), WithStrict(strict))
+					check(string(engine), results, err)
+				}
+			}
+		}
+		results, err := ProcessBytes(data)
+		check("bytes", results, err)
+		results, err = ProcessReader(bytes.NewReader(data))
+		check("reader", results, err)
+	}
+}
@@ -0,0 +1,107 @@
+package brc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"unicode/utf8"
+)
+
+// GroupBy 在聚合前将站点名映射为更粗的分组，如国家或地区，结果中的站点名是分组名。
+// 零值表示不分组
+type GroupBy struct {
+	prefix  int
+	re      *regexp.Regexp
+	mapping map[string]string
+}
+
+// GroupByPrefix 按站点名的前n个字符分组
+func GroupByPrefix(n int) GroupBy {
+	return GroupBy{prefix: n}
+}
+
+// GroupByRegex 按re的第一个捕获组分组，没有捕获组时使用整个匹配，不匹配的站点保留原名
+func GroupByRegex(re *regexp.Regexp) GroupBy {
+	return GroupBy{re: re}
+}
+
+// GroupByMap 按站点名到分组名的映射分组，不在映射中的站点保留原名
+func GroupByMap(mapping map[string]string) GroupBy {
+	return GroupBy{mapping: mapping}
+}
+
+// ReadGroupMap 读取每行`station,group`格式的CSV映射
+func ReadGroupMap(r io.Reader) (map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	mapping := make(map[string]string)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return mapping, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := mapping[record[0]]; ok && prev != record[1] {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: station %q mapped to both %q and %q", line, record[0], prev, record[1])
+		}
+		mapping[record[0]] = record[1]
+	}
+}
+
+// WithGroupBy 在聚合前按g对站点名分组，分组在大小写折叠和过滤之后进行
+func WithGroupBy(g GroupBy) Option {
+	return func(o *options) {
+		o.groupBy = g
+	}
+}
+
+// newGroup 返回在Statistic.Add中使用的分组函数，不分组时返回nil。
+// 与newFilter相同，正则匹配的结果按站点名缓存，因此每个Statistic需要独立的分组函数
+func (o *options) newGroup() func(name []byte) []byte {
+	g := o.groupBy
+	switch {
+	case g.prefix > 0:
+		return func(name []byte) []byte {
+			i := 0
+			for n := 0; n < g.prefix && i < len(name); n++ {
+				_, size := utf8.DecodeRune(name[i:])
+				i += size
+			}
+			return name[:i]
+		}
+	case g.re != nil:
+		cache := make(map[string][]byte)
+		return func(name []byte) []byte {
+			group, ok := cache[string(name)]
+			if !ok {
+				group = name
+				if m := g.re.FindSubmatch(name); m != nil {
+					group = m[0]
+					if len(m) > 1 {
+						group = m[1]
+					}
+				}
+				group = append([]byte(nil), group...)
+				cache[string(name)] = group
+			}
+			return group
+		}
+	case g.mapping != nil:
+		mapping := make(map[string][]byte, len(g.mapping))
+		for name, group := range g.mapping {
+			mapping[name] = []byte(group)
+		}
+		return func(name []byte) []byte {
+			if group, ok := mapping[string(name)]; ok {
+				return group
+			}
+			return name
+		}
+	default:
+		return nil
+	}
+}
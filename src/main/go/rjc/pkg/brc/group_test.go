@@ -0,0 +1,51 @@
+package brc
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestProcessGroupBy(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nHalifax;1.0\nÖrebro;5.0\nHanoi;20.0\n")
+	for _, tc := range []struct {
+		opts     []Option
+		expected string
+	}{
+		{[]Option{WithGroupBy(GroupByPrefix(1))}, "{B=8.9/8.9/8.9, H=-3.4/7.4/20.0, Ö=5.0/5.0/5.0}\n"},
+		{[]Option{WithGroupBy(GroupByPrefix(3))}, "{Bul=8.9/8.9/8.9, Hal=1.0/1.0/1.0, Ham=-3.4/4.3/12.0, Han=20.0/20.0/20.0, Öre=5.0/5.0/5.0}\n"},
+		{[]Option{WithGroupBy(GroupByRegex(regexp.MustCompile("^H(a.)")))}, "{Bulawayo=8.9/8.9/8.9, al=1.0/1.0/1.0, am=-3.4/4.3/12.0, an=20.0/20.0/20.0, Örebro=5.0/5.0/5.0}\n"},
+		{[]Option{WithGroupBy(GroupByRegex(regexp.MustCompile("[aeiou]")))}, "{a=-3.4/7.4/20.0, e=5.0/5.0/5.0, u=8.9/8.9/8.9}\n"},
+		{[]Option{WithGroupBy(GroupByMap(map[string]string{"Hamburg": "DE", "Halifax": "CA"}))}, "{Bulawayo=8.9/8.9/8.9, CA=1.0/1.0/1.0, DE=-3.4/4.3/12.0, Hanoi=20.0/20.0/20.0, Örebro=5.0/5.0/5.0}\n"},
+		// 先过滤再分组
+		{[]Option{WithFilterPrefix("Ha"), WithGroupBy(GroupByPrefix(1))}, "{H=-3.4/7.4/20.0}\n"},
+	} {
+		for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+			results, err := Process(path, append(tc.opts, WithEngine(engine))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			results.WriteTo(buf)
+			if buf.String() != tc.expected {
+				t.Errorf("%s: expected %q, got %q", engine, tc.expected, buf.String())
+			}
+		}
+	}
+}
+
+func TestReadGroupMap(t *testing.T) {
+	mapping, err := ReadGroupMap(strings.NewReader("Hamburg,DE\n\"Washington, D.C.\",US\nHamburg,DE\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping) != 2 || mapping["Hamburg"] != "DE" || mapping["Washington, D.C."] != "US" {
+		t.Errorf("unexpected mapping %v", mapping)
+	}
+	for _, data := range []string{"Hamburg\n", "Hamburg,DE,EU\n", "Hamburg,DE\nHamburg,US\n"} {
+		if _, err := ReadGroupMap(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package brc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+)
+
+// Hash 是哈希表使用的站点名哈希函数
+type Hash string
+
+const (
+	// HashFNV 是FNV-1a，站点名很短时最快，默认使用
+	HashFNV Hash = "fnv"
+	// HashXXHash 是XXH64，每次处理8字节，站点名较长时更快
+	HashXXHash Hash = "xxhash"
+	// HashWyhash 是Go runtime使用的wyhash变体，基于64位乘法，分布较好
+	HashWyhash Hash = "wyhash"
+	// HashCRC32C 是CPU支持时由硬件指令计算的CRC32C，只有32位
+	HashCRC32C Hash = "crc32c"
+)
+
+// WithHash 指定各worker哈希表使用的哈希函数，默认HashFNV。
+// 不同的站点名分布下各个哈希函数的速度和冲突情况不同，可以配合WithHashStats比较
+func WithHash(hash Hash) Option {
+	return func(o *options) {
+		o.hash = hash
+	}
+}
+
+// HashStats 记录各worker哈希表的查找情况，用于比较不同哈希函数在实际站点名上的表现
+type HashStats struct {
+	// Stations 是各worker哈希表中的站点数之和
+	Stations int64
+	// Lookups 是查找次数，即聚合的行数
+	Lookups int64
+	// Probes 是查找时越过的被其它站点占用的槽位数
+	Probes int64
+	// Collisions 是64位哈希值相同但站点名不同的次数
+	Collisions int64
+}
+
+// WithHashStats 在Process返回后将哈希表的查找情况写入stats
+func WithHashStats(stats *HashStats) Option {
+	return func(o *options) {
+		o.hashStats = stats
+	}
+}
+
+// hashFunc 返回hash对应的哈希函数，HashFNV返回nil，由Statistic直接调用hashName
+func hashFunc(hash Hash) (func([]byte) uint64, error) {
+	switch hash {
+	case "", HashFNV:
+		return nil, nil
+	case HashXXHash:
+		return xxhash64, nil
+	case HashWyhash:
+		return wyhash, nil
+	case HashCRC32C:
+		return crc32c, nil
+	default:
+		return nil, fmt.Errorf("unknown hash %q, want fnv, xxhash, wyhash or crc32c", hash)
+	}
+}
+
+// collectHashStats 汇总statistics中各哈希表的查找情况
+func collectHashStats(statistics []*Statistic) HashStats {
+	var stats HashStats
+	for _, s := range statistics {
+		stats.Stations += int64(s.table.size)
+		stats.Lookups += s.rows
+		stats.Probes += s.table.probes
+		stats.Collisions += s.table.collisions
+	}
+	return stats
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+// xxhash64 是种子为0的XXH64
+func xxhash64(b []byte) uint64 {
+	n := len(b)
+	var h uint64
+	if n >= 32 {
+		// 常量表达式不允许溢出，通过变量按模2^64计算
+		p1, p2 := xxPrime1, xxPrime2
+		v1 := p1 + p2
+		v2 := p2
+		v3 := uint64(0)
+		v4 := -p1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b[0:8]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:16]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:24]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:32]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = xxPrime5
+	}
+	h += uint64(n)
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+const (
+	wyp0 = 0xa0761d6478bd642f
+	wyp1 = 0xe7037ed1a0b428db
+	wyp2 = 0x8ebc6af09c88c6e3
+	wyp3 = 0x589965cc75374cc3
+	wyp4 = 0x1d8e4e27c47d124f
+)
+
+func wymix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+// wyhash 与Go runtime的memhashFallback相同，种子固定为0
+func wyhash(b []byte) uint64 {
+	n := len(b)
+	seed := uint64(wyp0)
+	var x, y uint64
+	switch {
+	case n == 0:
+		return seed
+	case n < 4:
+		x = uint64(b[0]) | uint64(b[n>>1])<<8 | uint64(b[n-1])<<16
+	case n == 4:
+		x = uint64(binary.LittleEndian.Uint32(b))
+		y = x
+	case n < 8:
+		x = uint64(binary.LittleEndian.Uint32(b))
+		y = uint64(binary.LittleEndian.Uint32(b[n-4:]))
+	case n == 8:
+		x = binary.LittleEndian.Uint64(b)
+		y = x
+	case n <= 16:
+		x = binary.LittleEndian.Uint64(b)
+		y = binary.LittleEndian.Uint64(b[n-8:])
+	default:
+		p := b
+		if len(p) > 48 {
+			seed1, seed2 := seed, seed
+			for ; len(p) > 48; p = p[48:] {
+				seed = wymix(binary.LittleEndian.Uint64(p)^wyp1, binary.LittleEndian.Uint64(p[8:])^seed)
+				seed1 = wymix(binary.LittleEndian.Uint64(p[16:])^wyp2, binary.LittleEndian.Uint64(p[24:])^seed1)
+				seed2 = wymix(binary.LittleEndian.Uint64(p[32:])^wyp3, binary.LittleEndian.Uint64(p[40:])^seed2)
+			}
+			seed ^= seed1 ^ seed2
+		}
+		for ; len(p) > 16; p = p[16:] {
+			seed = wymix(binary.LittleEndian.Uint64(p)^wyp1, binary.LittleEndian.Uint64(p[8:])^seed)
+		}
+		// 最后16字节可能与已处理的部分重叠
+		x = binary.LittleEndian.Uint64(b[n-16:])
+		y = binary.LittleEndian.Uint64(b[n-8:])
+	}
+	return wymix(wyp4^uint64(n), wymix(x^wyp1, y^seed))
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func crc32c(b []byte) uint64 {
+	return uint64(crc32.Checksum(b, castagnoli))
+}
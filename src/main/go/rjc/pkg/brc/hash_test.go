@@ -0,0 +1,84 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXXHash64(t *testing.T) {
+	long := strings.Repeat("ab;c", 30)
+	tests := []struct {
+		data     string
+		expected uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{long[:7], 0x229b3fffb51f2884},
+		{long[:14], 0x2ac0b0b85643cffa},
+		{long[:35], 0x4e7da474e78f2199},
+		{long[:49], 0xe8ded7431d9d5532},
+	}
+	for _, test := range tests {
+		if h := xxhash64([]byte(test.data)); h != test.expected {
+			t.Errorf("xxhash64(%q): expected %x, got %x", test.data, test.expected, h)
+		}
+	}
+}
+
+func TestCRC32C(t *testing.T) {
+	if h := crc32c([]byte("123456789")); h != 0xe3069283 {
+		t.Errorf("expected e3069283, got %x", h)
+	}
+}
+
+func TestHashDistinct(t *testing.T) {
+	// 覆盖wyhash各个长度分支
+	for _, hash := range []Hash{HashXXHash, HashWyhash, HashCRC32C} {
+		fn, err := hashFunc(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[uint64]string)
+		for n := 0; n <= 100; n++ {
+			name := strings.Repeat("x", n)
+			for _, s := range []string{name, name + "y", "y" + name} {
+				h := fn([]byte(s))
+				if prev, ok := seen[h]; ok && prev != s {
+					t.Errorf("%s: %q and %q have the same hash %x", hash, prev, s, h)
+				}
+				seen[h] = s
+			}
+		}
+	}
+}
+
+func TestProcessHash(t *testing.T) {
+	var data bytes.Buffer
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&data, "station%d;%d.%d\n", i%10000, i%50-25, i%10)
+	}
+	path := writeMeasurements(t, data.String())
+	expected, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []Hash{HashFNV, HashXXHash, HashWyhash, HashCRC32C} {
+		var stats HashStats
+		results, err := Process(path, WithHash(hash), WithHashStats(&stats), WithWorkers(2))
+		if err != nil {
+			t.Fatalf("%s: %v", hash, err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("%s: results differ from the default hash", hash)
+		}
+		if stats.Lookups != 20000 || stats.Stations < 10000 || stats.Probes < stats.Collisions {
+			t.Errorf("%s: unexpected stats %+v", hash, stats)
+		}
+	}
+
+	if _, err := Process(path, WithHash("md5")); err == nil {
+		t.Error("expected error for unknown hash")
+	}
+}
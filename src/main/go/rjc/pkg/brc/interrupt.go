@@ -0,0 +1,103 @@
+package brc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// WithContext 在ctx结束时停止领取新的数据，等待正在解析的批次完成后合并已经解析的部分。
+// 此时Process、ProcessState和Count返回部分结果和*InterruptedError，ctx在处理完所有数据后才结束时不影响结果
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// InterruptedError 表示WithContext的ctx结束时处理被提前停止，结果只包含已经解析的数据
+type InterruptedError struct {
+	// Bytes 是已经解析的字节数，Size 是输入的总大小，未知时为0。Parquet输入不统计字节数
+	Bytes int64
+	Size  int64
+	// Rows 是已经聚合的行数
+	Rows int64
+	Err  error
+}
+
+func (e *InterruptedError) Error() string {
+	if e.Size > 0 {
+		return fmt.Sprintf("interrupted after %d of %d bytes (%d rows): %v", e.Bytes, e.Size, e.Rows, e.Err)
+	}
+	return fmt.Sprintf("interrupted after %d bytes (%d rows): %v", e.Bytes, e.Rows, e.Err)
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// interrupted 判断WithContext的ctx是否已经结束，结束时记录下来，之后aggregate返回InterruptedError
+func (j *job) interrupted() bool {
+	if j.ctx == nil || j.ctx.Err() == nil {
+		return false
+	}
+	j.stopped.Store(true)
+	return true
+}
+
+// interruptedError 在处理被提前停止时返回InterruptedError，否则返回nil
+func (j *job) interruptedError(merged *MergedStatistics) error {
+	if !j.stopped.Load() {
+		return nil
+	}
+	return &InterruptedError{Bytes: j.parsed.Load(), Size: j.size, Rows: merged.rows, Err: context.Cause(j.ctx)}
+}
+
+// interruptible 让阻塞在管道或FIFO读取中的r在ctx结束时返回，返回的stop函数停止监听ctx。
+// 支持deadline的文件在ctx结束时设置deadline，否则（如阻塞模式的stdin）在另一个goroutine中读取，
+// ctx结束时放弃尚未返回的读取
+func interruptible(ctx context.Context, r io.Reader) (io.Reader, func() bool) {
+	if f, ok := r.(*os.File); ok && f.SetReadDeadline(time.Time{}) == nil {
+		return r, context.AfterFunc(ctx, func() { f.SetReadDeadline(time.Now()) })
+	}
+	return &contextReader{ctx: ctx, r: r, results: make(chan readResult, 1)}, func() bool { return true }
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+// contextReader 在另一个goroutine中读取r，ctx结束时Read立即返回，同一时间只有一次读取。
+// 读取的目标是contextReader自己的buf，收到结果后才复制到调用方的缓冲区，
+// 被放弃的读取即使之后返回也不会写入调用方已经重新使用的缓冲区
+type contextReader struct {
+	ctx     context.Context
+	r       io.Reader
+	buf     []byte
+	results chan readResult
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	// ctx结束之后不再读取，被放弃的读取留在results中的结果也不会被返回
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(cr.buf) < len(p) {
+		cr.buf = make([]byte, len(p))
+	}
+	buf := cr.buf[:len(p)]
+	go func() {
+		n, err := cr.r.Read(buf)
+		cr.results <- readResult{n, err}
+	}()
+	select {
+	case res := <-cr.results:
+		return copy(p, buf[:res.n]), res.err
+	case <-cr.ctx.Done():
+		// 读取仍在进行，buf交给那个goroutine
+		cr.buf = nil
+		return 0, cr.ctx.Err()
+	}
+}
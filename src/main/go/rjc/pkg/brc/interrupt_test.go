@@ -0,0 +1,97 @@
+package brc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProcessInterrupted(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, engine := range []Engine{EngineScanner, EngineChunk, EngineMmap} {
+		results, err := Process(path, WithContext(cancelled), WithEngine(engine))
+		var ierr *InterruptedError
+		if !errors.As(err, &ierr) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: expected InterruptedError, got %v", engine, err)
+		}
+		if ierr.Bytes != 0 || ierr.Rows != 0 || ierr.Size != 39 || len(results) != 0 {
+			t.Errorf("%s: expected nothing processed, got %+v and %v", engine, ierr, results)
+		}
+
+		// ctx在处理完成之后才结束时返回完整的结果
+		results, err = Process(path, WithContext(context.Background()), WithEngine(engine))
+		if err != nil || len(results) != 2 {
+			t.Errorf("%s: expected complete results, got %v, %v", engine, results, err)
+		}
+	}
+}
+
+func TestProcessReaderInterrupted(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+	if _, err := pw.WriteString("Hamburg;12.0\nBulawayo;8.9\n"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	// 写入端没有关闭，只有ctx结束才能让读取返回
+	results, err := ProcessReader(pr, WithContext(ctx), WithStreaming(1, 16))
+	var ierr *InterruptedError
+	if !errors.As(err, &ierr) {
+		t.Fatalf("expected InterruptedError, got %v", err)
+	}
+	if ierr.Rows != 2 || len(results) != 2 {
+		t.Errorf("expected the 2 rows read before the interruption, got %+v and %v", ierr, results)
+	}
+}
+
+func TestContextReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	reads := 0
+	// 第一次读取返回之后才结束ctx：第二次读取开始时结束ctx，之后阻塞在管道上
+	r, stop := interruptible(ctx, readerFunc(func(p []byte) (int, error) {
+		if reads++; reads == 2 {
+			cancel()
+		}
+		return pr.Read(p)
+	}))
+	defer stop()
+	go pw.Write([]byte("abc"))
+	buf := make([]byte, 8)
+	if n, err := r.Read(buf); n != 3 || err != nil || string(buf[:n]) != "abc" {
+		t.Fatalf("expected abc, got %q, %v", buf[:n], err)
+	}
+
+	clear(buf)
+	if _, err := r.Read(buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	// 被放弃的读取之后完成时不会写入buf，结果也不会被之后的Read返回
+	if _, err := pw.Write([]byte("xyz")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, make([]byte, 8)) {
+		t.Errorf("abandoned read wrote %q into the caller's buffer", buf)
+	}
+	if n, err := r.Read(buf); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled after cancellation, got %d, %v", n, err)
+	}
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
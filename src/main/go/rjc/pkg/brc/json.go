@@ -0,0 +1,96 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type jsonStation struct {
+	Min   json.Number `json:"min"`
+	Mean  json.Number `json:"mean"`
+	Max   json.Number `json:"max"`
+	Count int         `json:"count"`
+}
+
+// WriteJSON 以`{"站点": {"min": ..., "mean": ..., "max": ..., "count": ...}}`格式输出结果，
+// 站点按名称排序，中位数、分位数和opts.Columns作为额外的字段追加在count之后，
+// 元数据中的站点再追加opts.Metadata的各列，值为字符串，
+// 设置了opts.Buckets时再追加`"histogram": {"bounds": [...], "counts": [...]}`
+func (r Results) WriteJSON(w io.Writer, opts WriteOptions) error {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i := range r {
+		s := &r[i]
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(s.Name)
+		if err != nil {
+			return err
+		}
+		if len(opts.Aggregates) > 0 {
+			buf.Write(name)
+			buf.WriteString(":{")
+			for k, v := range s.aggregateValues(opts) {
+				if k > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, "%q:%s", opts.Aggregates[k], v)
+			}
+			buf.WriteByte('}')
+			continue
+		}
+		lo, mean, hi := s.formatMinMeanMax(opts)
+		value, err := json.Marshal(jsonStation{
+			Min:   json.Number(lo),
+			Mean:  json.Number(mean),
+			Max:   json.Number(hi),
+			Count: s.Count,
+		})
+		if err != nil {
+			return err
+		}
+		value = value[:len(value)-1]
+		for _, f := range s.extraFields(opts) {
+			value = fmt.Appendf(value, ",%q:%s", f.name, formatValue(f.value))
+		}
+		if opts.Metadata != nil {
+			if values, ok := opts.Metadata.stations[s.Name]; ok {
+				for k, column := range opts.Metadata.columns {
+					c, err := json.Marshal(column)
+					if err != nil {
+						return err
+					}
+					v, err := json.Marshal(values[k])
+					if err != nil {
+						return err
+					}
+					value = fmt.Appendf(value, ",%s:%s", c, v)
+				}
+			}
+		}
+		if bounds, counts, ok := s.histogram(opts); ok {
+			value = fmt.Appendf(value, `,"histogram":{"bounds":[%s],"counts":[%s]}`,
+				strings.Join(bounds, ","), strings.Join(counts, ","))
+		}
+		value = append(value, '}')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	if opts.Pretty {
+		out := &bytes.Buffer{}
+		if err := json.Indent(out, buf.Bytes(), "", "  "); err != nil {
+			return err
+		}
+		buf = out
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,36 @@
+package brc
+
+import (
+	"log/slog"
+	"time"
+)
+
+// WithLogger 用l以Debug级别记录每个批次的解析耗时、每个worker的利用率和合并的统计信息。
+// 未指定时不记录，也不会为计时产生额外的开销
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// logBatch 将一个批次的解析耗时累计到s的忙碌时间中，开启WithLogger时记录下来，开启WithDumpPartials时记录批次的位置
+func (j *job) logBatch(s *Statistic, offset int64, size int, start time.Time) {
+	d := time.Since(start)
+	s.busy += d
+	s.batches++
+	s.bytes += int64(size)
+	if j.dumpPartials != "" {
+		s.spans = append(s.spans, batchSpan{offset, size})
+	}
+	if j.logger != nil {
+		j.logger.Debug("parsed batch", "offset", offset, "bytes", size, "duration", d)
+	}
+}
+
+// logWorkers 记录每个worker处理的批次数、行数和忙碌时间占wall的比例
+func (j *job) logWorkers(statistics []*Statistic, wall time.Duration) {
+	for i, s := range statistics {
+		j.logger.Debug("worker done", "worker", i, "batches", s.batches, "rows", s.rows,
+			"busy", s.busy, "utilization", float64(s.busy)/float64(max(wall, 1)))
+	}
+}
@@ -0,0 +1,23 @@
+package brc
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestProcessLogger(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n")
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if _, err := Process(path, WithLogger(logger), WithWorkers(2), WithEngine(EngineChunk)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, msg := range []string{`msg="parsed batch"`, `msg="worker done" worker=1`, "msg=merged engine=chunk workers=2 stations=2 rows=3"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("expected %s in\n%s", msg, out)
+		}
+	}
+}
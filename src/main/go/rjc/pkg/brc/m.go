@@ -0,0 +1,131 @@
+package brc
+
+import (
+	"fmt"
+	"math"
+)
+
+// M 是单个站点的累计值，温度以0.1度为单位
+type M struct {
+	Count int
+	Min   int64
+	Max   int64
+	Sum   int64
+	// SumSq 是平方和，用于计算方差
+	SumSq int64
+	// Sketch 仅在开启分位数统计时非nil
+	Sketch *Sketch
+	// Counts 仅在开启精确中位数时非nil
+	Counts *Counts
+}
+
+func newM() M {
+	return M{
+		Count: 0,
+		Min:   math.MaxInt64,
+		Max:   math.MinInt64,
+	}
+}
+
+func (m *M) Add(val int64) {
+	m.Count++
+	m.Sum += val
+	m.SumSq += val * val
+	if val < m.Min {
+		m.Min = val
+	}
+	if val > m.Max {
+		m.Max = val
+	}
+	if m.Sketch != nil {
+		m.Sketch.Add(val)
+	}
+	if m.Counts != nil {
+		m.Counts.add(val)
+	}
+}
+
+// OverflowError 表示开启溢出检查时某个站点的累计值超出了int64的范围
+type OverflowError struct {
+	Station string
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("accumulator overflow for station %q", e.Station)
+}
+
+// maxSquareRoot 是平方不超过math.MaxInt64的最大整数
+const maxSquareRoot = 3037000499
+
+// addOverflows 判断a+b是否超出int64的范围
+func addOverflows(a, b int64) bool {
+	return (a+b > a) != (b > 0)
+}
+
+// addChecked 与Add相同，但在Sum或SumSq溢出时返回false并且不修改m
+func (m *M) addChecked(val int64) bool {
+	if val > maxSquareRoot || val < -maxSquareRoot || addOverflows(m.Sum, val) || addOverflows(m.SumSq, val*val) {
+		return false
+	}
+	m.Add(val)
+	return true
+}
+
+// mergeChecked 与Merge相同，返回Sum或SumSq是否溢出，溢出时m的累计值不再有效
+func (m *M) mergeChecked(o *M) bool {
+	ok := !addOverflows(m.Sum, o.Sum) && !addOverflows(m.SumSq, o.SumSq)
+	m.Merge(o)
+	return ok
+}
+
+// Merge 将o的累计值合并到m中
+func (m *M) Merge(o *M) {
+	m.Count += o.Count
+	m.Sum += o.Sum
+	m.SumSq += o.SumSq
+	if o.Min < m.Min {
+		m.Min = o.Min
+	}
+	if o.Max > m.Max {
+		m.Max = o.Max
+	}
+	if o.Sketch != nil {
+		if m.Sketch == nil {
+			m.Sketch = NewSketch()
+		}
+		m.Sketch.Merge(o.Sketch)
+	}
+	if o.Counts != nil {
+		if m.Counts == nil {
+			m.Counts = &Counts{}
+		}
+		m.Counts.merge(o.Counts)
+	}
+}
+
+func (m *M) mean() float64 {
+	return float64(m.Sum) / float64(m.Count*10)
+}
+
+// roundedMean 返回以0.1度为单位的平均值，与参考实现的Math.round一样.5向正无穷方向舍入。
+// 全部使用整数运算，避免浮点数在.5附近和总和很大时的误差
+func (m *M) roundedMean() int64 {
+	n := int64(m.Count)
+	q, r := (2*m.Sum+n)/(2*n), (2*m.Sum+n)%(2*n)
+	if r < 0 {
+		q--
+	}
+	return q
+}
+
+// Variance 返回总体方差，单位为度的平方
+func (m *M) Variance() float64 {
+	mean := float64(m.Sum) / float64(m.Count)
+	v := float64(m.SumSq)/float64(m.Count) - mean*mean
+	return max(v, 0) / 100
+}
+
+// Stddev 返回总体标准差，单位为度
+func (m *M) Stddev() float64 {
+	return math.Sqrt(m.Variance())
+}
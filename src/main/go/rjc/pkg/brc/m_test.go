@@ -0,0 +1,44 @@
+package brc
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAddChecked(t *testing.T) {
+	m := newM()
+	if !m.addChecked(maxSquareRoot) || !m.addChecked(-1) || m.Count != 2 {
+		t.Fatalf("unexpected overflow: %+v", m)
+	}
+	before := m
+	if m.addChecked(-maxSquareRoot) || m != before {
+		t.Errorf("expected overflow of SumSq without modifying m, got %+v", m)
+	}
+	if m.addChecked(maxSquareRoot + 1) {
+		t.Error("expected overflow of the square")
+	}
+
+	a, b := M{Sum: math.MaxInt64 - 1}, M{Sum: 1}
+	if !a.mergeChecked(&b) || a.mergeChecked(&b) {
+		t.Errorf("expected overflow on the second merge, got %+v", a)
+	}
+	a, b = M{Sum: math.MinInt64 + 1}, M{Sum: -2}
+	if a.mergeChecked(&b) {
+		t.Error("expected negative overflow")
+	}
+}
+
+func TestProcessOverflowCheck(t *testing.T) {
+	path := writeMeasurements(t, "a;1.0\nhuge;3000000000.0\nhuge;3000000000.0\nb;2.0\n")
+	if _, err := Process(path); err != nil {
+		t.Fatalf("unexpected error without overflow check: %v", err)
+	}
+	for _, engine := range []Engine{EngineScanner, EngineChunk} {
+		_, err := Process(path, WithEngine(engine), WithOverflowCheck(true))
+		var oerr *OverflowError
+		if !errors.As(err, &oerr) || oerr.Station != "huge" {
+			t.Errorf("%s: expected overflow of huge, got %v", engine, err)
+		}
+	}
+}
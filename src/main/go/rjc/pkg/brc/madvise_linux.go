@@ -0,0 +1,13 @@
+package brc
+
+import "golang.org/x/sys/unix"
+
+// advise 提示内核data将被顺序读取并提前预读；hugePages为true时请求使用透明大页，减少TLB miss。
+// 这些都只是提示，内核不支持（如文件系统不支持文件映射的大页）时忽略错误
+func advise(data []byte, hugePages bool) {
+	_ = unix.Madvise(data, unix.MADV_SEQUENTIAL)
+	_ = unix.Madvise(data, unix.MADV_WILLNEED)
+	if hugePages {
+		_ = unix.Madvise(data, unix.MADV_HUGEPAGE)
+	}
+}
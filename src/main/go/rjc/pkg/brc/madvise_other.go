@@ -0,0 +1,5 @@
+//go:build !linux
+
+package brc
+
+func advise(data []byte, hugePages bool) {}
@@ -0,0 +1,76 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Malformed 收集WithMalformed跳过的不合法的行，在Process返回后读取
+type Malformed struct {
+	// Count 是跳过的行数
+	Count int64
+	// Lines 是偏移最小的至多limit个不合法的行，按偏移排序
+	Lines []*ParseError
+
+	limit int
+	mu    sync.Mutex
+}
+
+// NewMalformed 创建最多保留limit个不合法的行的Malformed
+func NewMalformed(limit int) *Malformed {
+	return &Malformed{limit: limit}
+}
+
+func (m *Malformed) add(err *ParseError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Count++
+	i := sort.Search(len(m.Lines), func(i int) bool { return m.Lines[i].Offset > err.Offset })
+	if i >= m.limit {
+		return
+	}
+	m.Lines = append(m.Lines, nil)
+	copy(m.Lines[i+1:], m.Lines[i:])
+	m.Lines[i] = err
+	m.Lines = m.Lines[:min(len(m.Lines), m.limit)]
+}
+
+// WithMalformed 与WithStrict一样校验每一行，但跳过不合法的行而不是停止，跳过的行记录在m中。
+// 同时指定WithStrict时也不会停止，WithStrictSpec、WithQuoted和WithWindow的校验规则仍然适用
+func WithMalformed(m *Malformed) Option {
+	return func(o *options) {
+		o.malformed = m
+	}
+}
+
+// parseStrict 按严格模式解析lines，返回第一个不合法的行
+func (j *job) parseStrict(s *Statistic, lines []byte, offset int64) error {
+	switch {
+	case j.window > 0:
+		return s.ParseAndAddLinesWindowed(lines, offset, true)
+	case j.quoted:
+		return s.ParseAndAddLinesQuoted(lines, offset, true)
+	default:
+		return s.ParseAndAddLinesStrict(lines, offset)
+	}
+}
+
+// parseSkipping 按严格模式解析lines，把不合法的行记录到j.malformed后从下一行继续
+func (j *job) parseSkipping(s *Statistic, lines []byte, offset int64) {
+	for len(lines) > 0 {
+		var err *ParseError
+		if !errors.As(j.parseStrict(s, lines, offset), &err) {
+			return
+		}
+		j.malformed.add(err)
+		rel := err.Offset - offset
+		end := bytes.IndexByte(lines[rel:], '\n')
+		if end < 0 {
+			return
+		}
+		lines = lines[rel+int64(end)+1:]
+		offset += rel + int64(end) + 1
+	}
+}
@@ -0,0 +1,47 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProcessMalformed(t *testing.T) {
+	data := "Hamburg;12.0\nbad line\nBulawayo;8.9\nHamburg;1\n;3.0\nHamburg;-3.4\nPalembang;38.8"
+	path := writeMeasurements(t, data)
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		m := NewMalformed(2)
+		results, err := Process(path, WithMalformed(m), WithStrict(true), WithEngine(engine), WithWorkers(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		results.WriteTo(buf)
+		if expected := "{Bulawayo=8.9/8.9/8.9, Hamburg=-3.4/4.3/12.0, Palembang=38.8/38.8/38.8}\n"; buf.String() != expected {
+			t.Errorf("%s: expected %q, got %q", engine, expected, buf.String())
+		}
+		if m.Count != 3 || len(m.Lines) != 2 || m.Lines[0].Offset != 13 || m.Lines[0].Line != "bad line" ||
+			m.Lines[1].Offset != 35 || m.Lines[1].Line != "Hamburg;1" {
+			t.Errorf("%s: unexpected malformed lines %d %v", engine, m.Count, m.Lines)
+		}
+	}
+
+	// WithStrictSpec的规则仍然适用
+	m := NewMalformed(10)
+	path = writeMeasurements(t, "a;1.0\n\xff;10.0\n")
+	if _, err := Process(path, WithMalformed(m), WithStrictSpec(true)); err != nil {
+		t.Fatal(err)
+	}
+	if m.Count != 1 || m.Lines[0].Reason == "" {
+		t.Errorf("unexpected malformed lines %d %v", m.Count, m.Lines)
+	}
+}
+
+func TestMalformedAdd(t *testing.T) {
+	m := NewMalformed(2)
+	for _, offset := range []int64{30, 10, 40, 20} {
+		m.add(&ParseError{Offset: offset})
+	}
+	if m.Count != 4 || len(m.Lines) != 2 || m.Lines[0].Offset != 10 || m.Lines[1].Offset != 20 {
+		t.Errorf("unexpected %d %v", m.Count, m.Lines)
+	}
+}
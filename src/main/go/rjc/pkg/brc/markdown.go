@@ -0,0 +1,116 @@
+package brc
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// cells 返回表格形式输出的表头、每个站点的一行以及每列是否为数值，
+// 列与WriteCSV相同，但不包括直方图
+func (r Results) cells(opts WriteOptions) (header []string, rows [][]string, numeric []bool) {
+	header = []string{"station"}
+	if len(opts.Aggregates) > 0 {
+		for _, a := range opts.Aggregates {
+			header = append(header, string(a))
+		}
+	} else {
+		header = append(header, "min", "mean", "max", "count")
+		if len(r) > 0 {
+			for _, f := range r[0].extraFields(opts) {
+				header = append(header, f.name)
+			}
+		}
+	}
+	numeric = make([]bool, len(header))
+	for i := 1; i < len(numeric); i++ {
+		numeric[i] = true
+	}
+	if opts.Metadata != nil {
+		header = append(header, opts.Metadata.columns...)
+		numeric = append(numeric, make([]bool, len(opts.Metadata.columns))...)
+	}
+
+	rows = make([][]string, len(r))
+	for i := range r {
+		s := &r[i]
+		row := []string{s.Name}
+		if len(opts.Aggregates) > 0 {
+			row = append(row, s.aggregateValues(opts)...)
+		} else {
+			lo, mean, hi := s.formatMinMeanMax(opts)
+			row = append(row, lo, mean, hi, strconv.Itoa(s.Count))
+			for _, f := range s.extraFields(opts) {
+				row = append(row, formatValue(f.value))
+			}
+		}
+		rows[i] = append(row, s.metadataFields(opts)...)
+	}
+	return header, rows, numeric
+}
+
+// columnWidths 返回每列最宽的单元格的字符数
+func columnWidths(header []string, rows [][]string) []int {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	return widths
+}
+
+// pad 将cell填充到width个字符，right为true时右对齐
+func pad(cell string, width int, right bool) string {
+	spaces := strings.Repeat(" ", max(0, width-utf8.RuneCountInString(cell)))
+	if right {
+		return spaces + cell
+	}
+	return cell + spaces
+}
+
+// markdownEscaper 转义会破坏表格结构的字符
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`)
+
+// WriteMarkdown 输出对齐的Markdown表格，列与WriteCSV相同，数值列右对齐
+func (r Results) WriteMarkdown(w io.Writer, opts WriteOptions) error {
+	header, rows, numeric := r.cells(opts)
+	for _, row := range append([][]string{header}, rows...) {
+		for i := range row {
+			if !numeric[i] {
+				row[i] = markdownEscaper.Replace(row[i])
+			}
+		}
+	}
+	widths := columnWidths(header, rows)
+	for i := range widths {
+		// 分隔行至少需要3个'-'
+		widths[i] = max(widths[i], 3)
+	}
+	buf := &bytes.Buffer{}
+	writeRow := func(row []string) {
+		buf.WriteByte('|')
+		for i, cell := range row {
+			buf.WriteString(" " + pad(cell, widths[i], numeric[i]) + " |")
+		}
+		buf.WriteByte('\n')
+	}
+	writeRow(header)
+	buf.WriteByte('|')
+	for i, width := range widths {
+		dashes := strings.Repeat("-", width)
+		if numeric[i] {
+			buf.WriteString(" " + dashes[1:] + ": |")
+		} else {
+			buf.WriteString(" " + dashes + " |")
+		}
+	}
+	buf.WriteByte('\n')
+	for _, row := range rows {
+		writeRow(row)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,43 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("b;1.5\na|b;-12.0\nb;2.5\nZürich;10.0\n"))
+	buf := &bytes.Buffer{}
+	if err := s.Results().WriteMarkdown(buf, WriteOptions{Columns: []Column{StddevColumn}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"| station |   min |  mean |   max | count | stddev |\n" +
+		"| ------- | ----: | ----: | ----: | ----: | -----: |\n" +
+		"| Zürich  |  10.0 |  10.0 |  10.0 |     1 |    0.0 |\n" +
+		"| a\\|b    | -12.0 | -12.0 | -12.0 |     1 |    0.0 |\n" +
+		"| b       |   1.5 |   2.0 |   2.5 |     2 |    0.5 |\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	m, err := ReadMetadata(strings.NewReader("station,id\nb,7\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	opts := WriteOptions{Aggregates: []Aggregate{AggregateCount}, Metadata: m}
+	if err := testResults().WriteMarkdown(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected = "" +
+		"| station | count | id  |\n" +
+		"| ------- | ----: | --- |\n" +
+		"| a       |     1 |     |\n" +
+		"| b       |     2 | 7   |\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,86 @@
+package brc
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+const (
+	// 缓冲区的最小大小，远大于合法的一行
+	minBufferSize = 64 * 1024
+	// 限制内存时哈希表预分配的最小容量
+	minTableSize = 1 << 10
+)
+
+// WithMaxMemory 将读取缓冲区和哈希表预分配的内存限制在bytes字节以内，0表示使用默认大小。
+// 哈希表最多占用预算的1/4，其余用于缓冲区：EngineScanner缩小或减少轮转的缓冲区，
+// EngineChunk缩小每个worker的缓冲区，必要时减少worker数量。
+// EngineMmap的映射由page cache承担，EngineParquet按row group分配内存，两者都不受限制；
+// 站点数超过预分配的容量时哈希表仍会扩容
+func WithMaxMemory(bytes int64) Option {
+	return func(o *options) {
+		o.maxMemory = bytes
+	}
+}
+
+// memoryPlan 是一次聚合使用的worker数量、缓冲区和哈希表大小
+type memoryPlan struct {
+	workers         int
+	scanBuffers     int
+	scanBufferSize  int
+	chunkBufferSize int
+	tableSize       int
+}
+
+func tableBytes(size int) int64 {
+	return int64(size) * int64(unsafe.Sizeof(entry{}))
+}
+
+// planMemory 在budget字节内为engine分配内存，budget为0时使用默认大小
+func planMemory(engine Engine, workers int, budget int64) (memoryPlan, error) {
+	p := memoryPlan{
+		workers:         workers,
+		scanBuffers:     scanBuffers,
+		scanBufferSize:  scanBufferSize,
+		chunkBufferSize: chunkBufferSize,
+		tableSize:       initialTableSize,
+	}
+	if budget == 0 {
+		return p, nil
+	}
+	if budget < 0 {
+		return p, fmt.Errorf("invalid max memory %d", budget)
+	}
+
+	// 每个worker一个哈希表，合并时再使用一个
+	for tableBytes(p.tableSize)*int64(p.workers+1) > budget/4 && p.tableSize > minTableSize {
+		p.tableSize /= 2
+	}
+	remaining := budget - tableBytes(p.tableSize)*int64(p.workers+1)
+
+	switch engine {
+	case EngineScanner, EngineIOUring:
+		// 预算不足以轮转两个缓冲区时，读取和解析不再同时进行
+		for p.scanBuffers > 1 && remaining/int64(p.scanBuffers) < minBufferSize {
+			p.scanBuffers--
+		}
+		p.scanBufferSize = int(min(int64(scanBufferSize), remaining/int64(p.scanBuffers)))
+		if p.scanBufferSize < minBufferSize {
+			return p, fmt.Errorf("max memory %d is too small for the %s engine", budget, engine)
+		}
+	case EngineChunk:
+		for p.workers > 1 && remaining/int64(p.workers) < minBufferSize {
+			p.workers--
+			remaining += tableBytes(p.tableSize)
+		}
+		p.chunkBufferSize = int(min(int64(chunkBufferSize), remaining/int64(p.workers)))
+		if p.chunkBufferSize < minBufferSize {
+			return p, fmt.Errorf("max memory %d is too small for the %s engine", budget, engine)
+		}
+	default:
+		if remaining < 0 {
+			return p, fmt.Errorf("max memory %d is too small for the %s engine", budget, engine)
+		}
+	}
+	return p, nil
+}
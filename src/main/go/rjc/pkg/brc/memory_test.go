@@ -0,0 +1,68 @@
+package brc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPlanMemory(t *testing.T) {
+	p, err := planMemory(EngineScanner, 8, 0)
+	if err != nil || p.scanBuffers != scanBuffers || p.scanBufferSize != scanBufferSize || p.tableSize != initialTableSize {
+		t.Errorf("expected default plan, got %+v, %v", p, err)
+	}
+
+	for _, engine := range []Engine{EngineScanner, EngineChunk, EngineMmap} {
+		for _, budget := range []int64{1 << 20, 16 << 20, 512 << 20} {
+			p, err := planMemory(engine, 8, budget)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", engine, budget, err)
+			}
+			used := tableBytes(p.tableSize) * int64(p.workers+1)
+			switch engine {
+			case EngineScanner:
+				used += int64(p.scanBuffers * p.scanBufferSize)
+			case EngineChunk:
+				used += int64(p.workers * p.chunkBufferSize)
+			}
+			if used > budget || p.workers < 1 {
+				t.Errorf("%s/%d: plan %+v uses %d bytes", engine, budget, p, used)
+			}
+		}
+	}
+
+	// 预算只够一个worker时减少worker数量
+	p, err = planMemory(EngineChunk, 8, 256<<10)
+	if err != nil || p.workers >= 8 {
+		t.Errorf("expected fewer workers, got %+v, %v", p, err)
+	}
+	for _, engine := range []Engine{EngineScanner, EngineChunk} {
+		if _, err := planMemory(engine, 8, 64<<10); err == nil {
+			t.Errorf("%s: expected error", engine)
+		}
+	}
+}
+
+func TestProcessMaxMemory(t *testing.T) {
+	// 每行都不同，保证数据跨越多个缓冲区
+	var data bytes.Buffer
+	for i := 0; data.Len() < 1<<20; i++ {
+		data.WriteString("station")
+		data.WriteByte(byte('a' + i%26))
+		data.WriteString(";1.0\n")
+	}
+	path := writeMeasurements(t, data.String())
+	expected, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []Engine{EngineScanner, EngineChunk} {
+		results, err := Process(path, WithEngine(engine), WithMaxMemory(1<<20))
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("%s: expected %v, got %v", engine, expected, results)
+		}
+	}
+}
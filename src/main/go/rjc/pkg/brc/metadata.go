@@ -0,0 +1,84 @@
+package brc
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Metadata 是站点的元数据表，如国家和经纬度，用于按元数据的列聚合或在输出中补充站点信息
+type Metadata struct {
+	columns  []string
+	stations map[string][]string
+}
+
+// ReadMetadata 读取带表头的CSV元数据表，第一列是站点名，其余各列是元数据，如`station,country,lat,lon`
+func ReadMetadata(r io.Reader) (*Metadata, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing metadata header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 2 {
+		return nil, errors.New("metadata needs a station column and at least one more column")
+	}
+	m := &Metadata{columns: header[1:], stations: make(map[string][]string)}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m.stations[record[0]]; ok {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: duplicate station %q", line, record[0])
+		}
+		m.stations[record[0]] = record[1:]
+	}
+}
+
+// Columns 返回站点名之外的各列的名字
+func (m *Metadata) Columns() []string {
+	return m.columns
+}
+
+// Lookup 返回站点name在column列的值，站点或列不存在时返回false
+func (m *Metadata) Lookup(name, column string) (string, bool) {
+	i := slices.Index(m.columns, column)
+	values, ok := m.stations[name]
+	if i < 0 || !ok {
+		return "", false
+	}
+	return values[i], true
+}
+
+// GroupBy 返回按column列聚合的分组方式，不在元数据中的站点保留原名
+func (m *Metadata) GroupBy(column string) (GroupBy, error) {
+	i := slices.Index(m.columns, column)
+	if i < 0 {
+		return GroupBy{}, fmt.Errorf("unknown metadata column %q, have %v", column, m.columns)
+	}
+	mapping := make(map[string]string, len(m.stations))
+	for name, values := range m.stations {
+		mapping[name] = values[i]
+	}
+	return GroupByMap(mapping), nil
+}
+
+// metadataFields 返回站点的各列元数据，站点不在元数据中时各列为空字符串
+func (s *Station) metadataFields(opts WriteOptions) []string {
+	if opts.Metadata == nil {
+		return nil
+	}
+	if values, ok := opts.Metadata.stations[s.Name]; ok {
+		return values
+	}
+	return make([]string, len(opts.Metadata.columns))
+}
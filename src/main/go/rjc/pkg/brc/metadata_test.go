@@ -0,0 +1,91 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testMetadata = "station,country,lat,lon\n" +
+	"Hamburg,DE,53.55,9.99\n" +
+	"Berlin,DE,52.52,13.40\n" +
+	"\"Washington, D.C.\",US,38.91,-77.04\n"
+
+func TestReadMetadata(t *testing.T) {
+	m, err := ReadMetadata(strings.NewReader(testMetadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns := strings.Join(m.Columns(), ","); columns != "country,lat,lon" {
+		t.Errorf("unexpected columns %s", columns)
+	}
+	if v, ok := m.Lookup("Washington, D.C.", "lon"); !ok || v != "-77.04" {
+		t.Errorf("unexpected lon %q, %v", v, ok)
+	}
+	if _, ok := m.Lookup("Hanoi", "country"); ok {
+		t.Error("expected missing station")
+	}
+	if _, ok := m.Lookup("Hamburg", "population"); ok {
+		t.Error("expected missing column")
+	}
+	for _, data := range []string{"", "station\n", "station,country\nHamburg\n", "station,country\nHamburg,DE\nHamburg,DE\n"} {
+		if _, err := ReadMetadata(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+}
+
+func TestProcessGroupByMetadata(t *testing.T) {
+	m, err := ReadMetadata(strings.NewReader(testMetadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GroupBy("population"); err == nil {
+		t.Error("expected error for unknown column")
+	}
+	g, err := m.GroupBy("country")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeMeasurements(t, "Hamburg;12.0\nBerlin;8.0\nWashington, D.C.;20.0\nHanoi;30.0\n")
+	results, err := Process(path, WithGroupBy(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	results.WriteTo(buf)
+	if expected := "{DE=8.0/10.0/12.0, Hanoi=30.0/30.0/30.0, US=20.0/20.0/20.0}\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	m, err := ReadMetadata(strings.NewReader(testMetadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("Hamburg;12.0\nHanoi;30.0\n"))
+	opts := WriteOptions{Metadata: m}
+
+	buf := &bytes.Buffer{}
+	if err := s.Results().WriteCSV(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := "station,min,mean,max,count,country,lat,lon\n" +
+		"Hamburg,12.0,12.0,12.0,1,DE,53.55,9.99\n" +
+		"Hanoi,30.0,30.0,30.0,1,,,\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := s.Results().WriteJSON(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"Hamburg":{"min":12.0,"mean":12.0,"max":12.0,"count":1,"country":"DE","lat":"53.55","lon":"9.99"},` +
+		`"Hanoi":{"min":30.0,"mean":30.0,"max":30.0,"count":1}}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+}
@@ -0,0 +1,61 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+)
+
+// 将整个文件映射到内存，每个worker从scheduler领取按换行符对齐的区间直接解析
+func (j *job) mmapStatistics(file *os.File) (statistics []*Statistic, err error) {
+	data, err := mmap(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := munmap(data); err == nil {
+			err = e
+		}
+	}()
+	if len(data) > 0 {
+		advise(data, j.hugePages)
+	}
+	return j.bytesStatistics(data)
+}
+
+// bytesStatistics 由各个worker从scheduler领取data中按换行符对齐的区间直接解析
+func (j *job) bytesStatistics(data []byte) ([]*Statistic, error) {
+	p, err := j.newPlacement(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	statistics := make([]*Statistic, j.workers)
+	errs := make([]error, j.workers)
+	wg := &sync.WaitGroup{}
+	for i := range statistics {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			sched, err := p.start(idx)
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+			statistics[idx] = j.newStatistic()
+			for !j.interrupted() {
+				start, end, err := sched.next()
+				if err != nil || start == end {
+					errs[idx] = err
+					return
+				}
+				j.parse(statistics[idx], data[start:end], start)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return statistics, nil
+}
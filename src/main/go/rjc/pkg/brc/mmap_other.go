@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package brc
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("mmap engine is not supported on this platform")
+
+func mmap(file *os.File) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,48 @@
+package brc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ProcessNaive 是单线程的朴素实现：用bufio.Scanner逐行读取，strings.Cut和strconv.ParseFloat解析，
+// 累加到以站点名为key的map中。它比Process慢得多，但显然正确，用于检查优化后的实现。
+// 忽略空行，行尾的"\r"被去掉，遇到不合法的行时返回错误；不支持压缩的输入和分位数等选项
+func ProcessNaive(r io.Reader) (Results, error) {
+	stations := make(map[string]*M)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(text, ";")
+		v, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("line %d: invalid measurement %q", line, text)
+		}
+		m, ok := stations[name]
+		if !ok {
+			m = new(M)
+			*m = newM()
+			stations[name] = m
+		}
+		m.Add(int64(math.Round(v * 10)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(stations))
+	measures := make([]M, 0, len(stations))
+	for name, m := range stations {
+		names = append(names, name)
+		measures = append(measures, *m)
+	}
+	return newResults(names, measures), nil
+}
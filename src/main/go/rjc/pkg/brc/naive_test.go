@@ -0,0 +1,38 @@
+package brc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessNaive(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join(samplesDir, "*.txt"))
+	golden, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.txt"))
+	for _, path := range append(paths, golden...) {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ProcessNaive(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		results, err := Process(path, WithWorkers(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("%s: expected %+v, got %+v", path, expected, results)
+		}
+	}
+
+	for _, data := range []string{"a;1.0\nb", "a;1.0\nb;x\n", "a;1.0\n;", "a;1.0\r\nb 2.0\r\n"} {
+		if _, err := ProcessNaive(strings.NewReader(data)); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("%q: expected error on line 2, got %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package brc
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// WithNormalizeKeys 在聚合前将站点名规范化为NFC，组合与分解形式的"é"等视觉上相同的站点名聚合在一起。
+// 不合法的UTF-8序列替换为U+FFFD，严格模式下返回*ParseError。
+// 已经是NFC的站点名（包括所有ASCII站点名）不会被复制
+func WithNormalizeKeys(normalize bool) Option {
+	return func(o *options) {
+		o.normalizeKeys = normalize
+	}
+}
+
+// normalizeName 返回name的NFC形式，需要转换时结果位于s的缓冲区中，在下一次调用前有效
+func (s *Statistic) normalizeName(name []byte) []byte {
+	if utf8.Valid(name) {
+		if norm.NFC.QuickSpan(name) == len(name) {
+			return name
+		}
+	} else {
+		s.validBuf = appendValidUTF8(s.validBuf[:0], name)
+		name = s.validBuf
+	}
+	// 与norm.NFC.Append不同，norm.Iter可以复用，不需要每次分配内存
+	if s.normIter == nil {
+		s.normIter = &norm.Iter{}
+	}
+	s.normBuf = s.normBuf[:0]
+	s.normIter.Init(norm.NFC, name)
+	for !s.normIter.Done() {
+		s.normBuf = append(s.normBuf, s.normIter.Next()...)
+	}
+	return s.normBuf
+}
+
+// validName 判断开启WithNormalizeKeys时name是否是合法的UTF-8，用于严格模式
+func (s *Statistic) validName(name []byte) bool {
+	return !s.normalize || utf8.Valid(name)
+}
+
+// appendValidUTF8 将b追加到dst，不合法的UTF-8序列替换为U+FFFD
+func appendValidUTF8(dst, b []byte) []byte {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		} else {
+			dst = append(dst, b[:size]...)
+		}
+		b = b[size:]
+	}
+	return dst
+}
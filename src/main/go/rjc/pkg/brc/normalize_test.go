@@ -0,0 +1,48 @@
+package brc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProcessNormalizeKeys(t *testing.T) {
+	// 组合形式和分解形式的"Zürich"，以及一个不合法的UTF-8序列
+	const data = "Zürich;10.0\nZürich;20.0\nBad\xffName;1.0\nHamburg;5.0\n"
+	path := writeMeasurements(t, data)
+
+	results, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Errorf("expected 4 stations without normalization, got %v", results)
+	}
+
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		results, err = Process(path, WithEngine(engine), WithNormalizeKeys(true))
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if len(results) != 3 || results[0].Name != "Bad�Name" || results[1].Name != "Hamburg" ||
+			results[2].Name != "Zürich" || results[2].Count != 2 {
+			t.Errorf("%s: unexpected results %v", engine, results)
+		}
+	}
+
+	_, err = Process(path, WithNormalizeKeys(true), WithStrict(true))
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != "Bad\xffName;1.0" {
+		t.Errorf("expected error for the invalid name, got %v", err)
+	}
+}
+
+func TestNormalizeNameNoCopy(t *testing.T) {
+	s := NewStatistic()
+	name := []byte("Hamburg")
+	if n := s.normalizeName(name); &n[0] != &name[0] {
+		t.Error("expected NFC name to be returned as is")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { s.normalizeName([]byte("Zürich")) }); allocs > 1 {
+		t.Errorf("expected buffers to be reused, got %v allocations", allocs)
+	}
+}
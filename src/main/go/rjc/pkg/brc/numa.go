@@ -0,0 +1,133 @@
+package brc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sysfs中NUMA节点的目录，测试时替换
+var numaNodeDir = "/sys/devices/system/node"
+
+// WithNUMA 在多个NUMA节点的机器上按节点划分文件，每个节点的worker只处理本节点的部分，
+// 并将worker的线程绑定到节点的CPU上，worker的Statistic在绑定之后分配，
+// 由内核的first-touch策略分配在本节点的内存中。只对EngineChunk和EngineMmap有效，
+// 只有一个节点或不支持绑定线程的平台上没有效果
+func WithNUMA(numa bool) Option {
+	return func(o *options) {
+		o.numa = numa
+	}
+}
+
+// parseCPUList 解析sysfs中`0-3,8,10-11`格式的CPU列表
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, field := range strings.Split(strings.TrimSpace(s), ",") {
+		if field == "" {
+			continue
+		}
+		lo, hi, found := strings.Cut(field, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q", s)
+		}
+		last := first
+		if found {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu list %q", s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// readNUMANodes 按节点编号返回dir下每个节点中当前进程可以使用的CPU，没有可用CPU的节点被忽略
+func readNUMANodes(dir string) ([][]int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "node[0-9]*", "cpulist"))
+	if err != nil {
+		return nil, err
+	}
+	node := func(path string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(path)), "node"))
+		return n
+	}
+	sort.Slice(paths, func(a, b int) bool { return node(paths[a]) < node(paths[b]) })
+
+	var nodes [][]int
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		cpus, err := parseCPUList(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if cpus = allowedCPUs(cpus); len(cpus) > 0 {
+			nodes = append(nodes, cpus)
+		}
+	}
+	return nodes, nil
+}
+
+// placement 将worker分配到scheduler和NUMA节点上，未开启NUMA时所有worker共享一个scheduler
+type placement struct {
+	scheds []*scheduler
+	nodes  [][]int
+}
+
+// newPlacement 开启NUMA时将worker轮流分配到各个节点，按每个节点的worker数量
+// 将[j.start, j.end)划分成按行对齐的区间，每个节点的worker从自己区间的scheduler领取任务
+func (j *job) newPlacement(r io.ReaderAt, size int64) (*placement, error) {
+	p := &placement{scheds: []*scheduler{j.newScheduler(r, size)}}
+	if !j.numa {
+		return p, nil
+	}
+	nodes, err := readNUMANodes(numaNodeDir)
+	if err != nil || len(nodes) <= 1 {
+		return p, err
+	}
+	nodes = nodes[:min(len(nodes), j.workers)]
+
+	start, end := p.scheds[0].pos, p.scheds[0].size
+	var buf [256]byte
+	p.scheds, p.nodes = nil, nodes
+	assigned := 0
+	for k := range nodes {
+		workers := (j.workers - k + len(nodes) - 1) / len(nodes)
+		lo, err := nextLineStart(r, start+(end-start)*int64(assigned)/int64(j.workers), end, buf[:])
+		if err != nil {
+			return nil, err
+		}
+		assigned += workers
+		hi, err := nextLineStart(r, start+(end-start)*int64(assigned)/int64(j.workers), end, buf[:])
+		if err != nil {
+			return nil, err
+		}
+		s := newScheduler(r, hi, workers)
+		s.pos = lo
+		s.sample = j.newSampler(k)
+		p.scheds = append(p.scheds, s)
+	}
+	return p, nil
+}
+
+// start 在第idx个worker的goroutine中调用，开启NUMA时将当前线程绑定到所在节点的CPU，
+// 返回worker使用的scheduler。绑定后的线程不再解除锁定，goroutine退出时线程随之销毁，
+// 不会把修改过的CPU亲和性带回线程池
+func (p *placement) start(idx int) (*scheduler, error) {
+	if p.nodes == nil {
+		return p.scheds[0], nil
+	}
+	if err := pinThread(p.nodes[idx%len(p.nodes)]); err != nil {
+		return nil, err
+	}
+	return p.scheds[idx%len(p.scheds)], nil
+}
@@ -0,0 +1,32 @@
+package brc
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// allowedCPUs 过滤掉当前进程的CPU亲和性不允许使用的CPU，如容器限制的cpuset
+func allowedCPUs(cpus []int) []int {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return cpus
+	}
+	var allowed []int
+	for _, cpu := range cpus {
+		if set.IsSet(cpu) {
+			allowed = append(allowed, cpu)
+		}
+	}
+	return allowed
+}
+
+// pinThread 将当前goroutine锁定在当前线程上，并将线程绑定到cpus
+func pinThread(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	runtime.LockOSThread()
+	return unix.SchedSetaffinity(0, &set)
+}
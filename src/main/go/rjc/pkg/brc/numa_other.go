@@ -0,0 +1,11 @@
+//go:build !linux
+
+package brc
+
+func allowedCPUs(cpus []int) []int {
+	return cpus
+}
+
+func pinThread(cpus []int) error {
+	return nil
+}
@@ -0,0 +1,59 @@
+package brc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("0-3,8,10-11\n")
+	if expected := []int{0, 1, 2, 3, 8, 10, 11}; err != nil || !reflect.DeepEqual(cpus, expected) {
+		t.Errorf("expected %v, got %v, %v", expected, cpus, err)
+	}
+	if cpus, err := parseCPUList("\n"); err != nil || len(cpus) != 0 {
+		t.Errorf("expected no cpus, got %v, %v", cpus, err)
+	}
+	for _, s := range []string{"a", "3-1", "0-"} {
+		if _, err := parseCPUList(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestProcessNUMA(t *testing.T) {
+	// 三个节点都只包含CPU 0，保证在任何机器上都可以绑定
+	dir := t.TempDir()
+	for _, node := range []string{"node0", "node1", "node10"} {
+		if err := os.MkdirAll(filepath.Join(dir, node), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, node, "cpulist"), []byte("0\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(dir string) { numaNodeDir = dir }(numaNodeDir)
+	numaNodeDir = dir
+	if nodes, err := readNUMANodes(dir); err != nil || len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %v, %v", nodes, err)
+	}
+
+	path := writeMeasurements(t, strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n", 1000))
+	expected, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []Engine{EngineMmap, EngineChunk} {
+		for _, workers := range []int{1, 2, 3, 8} {
+			results, err := Process(path, WithEngine(engine), WithWorkers(workers), WithNUMA(true))
+			if err != nil {
+				t.Fatalf("%s/%d: %v", engine, workers, err)
+			}
+			if !reflect.DeepEqual(results, expected) {
+				t.Errorf("%s/%d: expected %v, got %v", engine, workers, expected, results)
+			}
+		}
+	}
+}
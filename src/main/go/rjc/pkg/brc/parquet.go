@@ -0,0 +1,781 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Parquet输入中使用的列名
+const (
+	parquetStationColumn     = "station"
+	parquetTemperatureColumn = "temperature"
+)
+
+var parquetMagic = []byte("PAR1")
+
+// Parquet的物理类型
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Parquet的编码、压缩和page类型，只列出支持的部分
+const (
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+	encodingRLEDictionary   = 8
+
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+
+	convertedDecimal   = 5
+	repetitionOptional = 1
+)
+
+// parquetColumn 是schema中的一个顶层列
+type parquetColumn struct {
+	name     string
+	typ      int32
+	optional bool
+	// decimal表示DECIMAL类型，scale是其小数位数
+	decimal bool
+	scale   int32
+}
+
+// parquetChunk 是column chunk在文件中的位置
+type parquetChunk struct {
+	codec     int32
+	numValues int64
+	offset    int64
+	size      int64
+}
+
+type parquetRowGroup struct {
+	numRows              int64
+	station, temperature parquetChunk
+}
+
+type parquetFile struct {
+	station, temperature parquetColumn
+	rowGroups            []parquetRowGroup
+}
+
+func isParquet(r io.ReaderAt) bool {
+	header := make([]byte, len(parquetMagic))
+	n, _ := r.ReadAt(header, 0)
+	return bytes.Equal(header[:n], parquetMagic)
+}
+
+// readParquetMetadata 读取文件末尾的FileMetaData，只保留station和temperature两列
+func readParquetMetadata(r io.ReaderAt, size int64) (*parquetFile, error) {
+	footer := make([]byte, 8)
+	if size < 12 {
+		return nil, errors.New("parquet file too short")
+	}
+	if _, err := r.ReadAt(footer, size-8); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(footer[4:], parquetMagic) {
+		return nil, errors.New("missing parquet footer magic")
+	}
+	n := int64(binary.LittleEndian.Uint32(footer))
+	if n > size-12 {
+		return nil, errors.New("invalid parquet footer length")
+	}
+	meta := make([]byte, n)
+	if _, err := r.ReadAt(meta, size-8-n); err != nil {
+		return nil, err
+	}
+
+	f := &parquetFile{}
+	var columns []parquetColumn
+	var chunks [][]parquetChunk
+	var paths [][]string
+	var numRows []int64
+	tr := &thriftReader{data: meta}
+	tr.readStruct(func(id int16, typ byte) bool {
+		switch {
+		case id == 2 && typ == thriftList:
+			_, n := tr.list()
+			for i := 0; i < n && tr.err == nil; i++ {
+				columns = append(columns, readParquetSchemaElement(tr))
+			}
+		case id == 4 && typ == thriftList:
+			_, n := tr.list()
+			for i := 0; i < n && tr.err == nil; i++ {
+				rows, cs, ps := readParquetRowGroup(tr)
+				numRows = append(numRows, rows)
+				chunks = append(chunks, cs)
+				paths = append(paths, ps)
+			}
+		default:
+			return false
+		}
+		return true
+	})
+	if tr.err != nil {
+		return nil, fmt.Errorf("parquet metadata: %w", tr.err)
+	}
+
+	// 第一个元素是schema的根，其余的顶层列都必须是不嵌套的基本类型
+	stationIdx, temperatureIdx := -1, -1
+	for i, c := range columns {
+		switch {
+		case i == 0:
+		case c.typ < 0:
+			return nil, fmt.Errorf("parquet column %q: nested columns are not supported", c.name)
+		case c.name == parquetStationColumn:
+			stationIdx = i - 1
+			f.station = c
+		case c.name == parquetTemperatureColumn:
+			temperatureIdx = i - 1
+			f.temperature = c
+		}
+	}
+	if stationIdx < 0 || temperatureIdx < 0 {
+		return nil, fmt.Errorf("parquet file must have %q and %q columns", parquetStationColumn, parquetTemperatureColumn)
+	}
+	if f.station.typ != parquetByteArray {
+		return nil, fmt.Errorf("parquet column %q must be a string", parquetStationColumn)
+	}
+	switch f.temperature.typ {
+	case parquetInt32, parquetInt64, parquetFloat, parquetDouble:
+	default:
+		return nil, fmt.Errorf("parquet column %q must be a double or an int", parquetTemperatureColumn)
+	}
+
+	for i := range chunks {
+		rg := parquetRowGroup{numRows: numRows[i]}
+		found := 0
+		for k, p := range paths[i] {
+			switch p {
+			case parquetStationColumn:
+				rg.station = chunks[i][k]
+				found++
+			case parquetTemperatureColumn:
+				rg.temperature = chunks[i][k]
+				found++
+			}
+		}
+		if found != 2 {
+			return nil, fmt.Errorf("parquet row group %d is missing a column", i)
+		}
+		f.rowGroups = append(f.rowGroups, rg)
+	}
+	return f, nil
+}
+
+// 非叶子节点的typ为-1
+func readParquetSchemaElement(tr *thriftReader) parquetColumn {
+	c := parquetColumn{typ: -1}
+	var converted int64 = -1
+	var children int64
+	tr.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			c.typ = int32(tr.int())
+		case 3:
+			c.optional = tr.int() == repetitionOptional
+		case 4:
+			c.name = string(tr.binary())
+		case 5:
+			children = tr.int()
+		case 6:
+			converted = tr.int()
+		case 7:
+			c.scale = int32(tr.int())
+		default:
+			return false
+		}
+		return true
+	})
+	if children > 0 {
+		c.typ = -1
+	}
+	c.decimal = converted == convertedDecimal
+	return c
+}
+
+// 返回row group的行数、各个column chunk及其路径，嵌套的路径为空字符串
+func readParquetRowGroup(tr *thriftReader) (int64, []parquetChunk, []string) {
+	var rows int64
+	var chunks []parquetChunk
+	var paths []string
+	tr.readStruct(func(id int16, typ byte) bool {
+		switch {
+		case id == 1 && typ == thriftList:
+			_, n := tr.list()
+			for i := 0; i < n && tr.err == nil; i++ {
+				c, path := readParquetColumnChunk(tr)
+				chunks = append(chunks, c)
+				paths = append(paths, path)
+			}
+		case id == 3:
+			rows = tr.int()
+		default:
+			return false
+		}
+		return true
+	})
+	return rows, chunks, paths
+}
+
+func readParquetColumnChunk(tr *thriftReader) (parquetChunk, string) {
+	var c parquetChunk
+	var path []string
+	external := false
+	tr.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			external = len(tr.binary()) > 0
+		case 3:
+			var dataOffset, dictOffset int64 = 0, -1
+			tr.readStruct(func(id int16, typ byte) bool {
+				switch id {
+				case 3:
+					_, n := tr.list()
+					for i := 0; i < n && tr.err == nil; i++ {
+						path = append(path, string(tr.binary()))
+					}
+				case 4:
+					c.codec = int32(tr.int())
+				case 5:
+					c.numValues = tr.int()
+				case 7:
+					c.size = tr.int()
+				case 9:
+					dataOffset = tr.int()
+				case 11:
+					dictOffset = tr.int()
+				default:
+					return false
+				}
+				return true
+			})
+			c.offset = dataOffset
+			if dictOffset > 0 && dictOffset < dataOffset {
+				c.offset = dictOffset
+			}
+		default:
+			return false
+		}
+		return true
+	})
+	if external || len(path) != 1 {
+		return c, ""
+	}
+	return c, path[0]
+}
+
+// parquetPageHeader 只包含解码需要的字段
+type parquetPageHeader struct {
+	typ              int32
+	uncompressedSize int32
+	compressedSize   int32
+	numValues        int32
+	encoding         int32
+	defEncoding      int32
+	// 以下仅用于DATA_PAGE_V2
+	defLength    int32
+	repLength    int32
+	isCompressed bool
+}
+
+func readParquetPageHeader(tr *thriftReader) parquetPageHeader {
+	h := parquetPageHeader{isCompressed: true, defEncoding: encodingRLE}
+	tr.readStruct(func(id int16, typ byte) bool {
+		switch id {
+		case 1:
+			h.typ = int32(tr.int())
+		case 2:
+			h.uncompressedSize = int32(tr.int())
+		case 3:
+			h.compressedSize = int32(tr.int())
+		case 5, 7:
+			// DataPageHeader和DictionaryPageHeader的前两个字段相同
+			tr.readStruct(func(id int16, typ byte) bool {
+				switch id {
+				case 1:
+					h.numValues = int32(tr.int())
+				case 2:
+					h.encoding = int32(tr.int())
+				case 3:
+					h.defEncoding = int32(tr.int())
+				default:
+					return false
+				}
+				return true
+			})
+		case 8:
+			tr.readStruct(func(id int16, typ byte) bool {
+				switch id {
+				case 1:
+					h.numValues = int32(tr.int())
+				case 4:
+					h.encoding = int32(tr.int())
+				case 5:
+					h.defLength = int32(tr.int())
+				case 6:
+					h.repLength = int32(tr.int())
+				case 7:
+					h.isCompressed = typ == thriftTrue
+				default:
+					return false
+				}
+				return true
+			})
+		default:
+			return false
+		}
+		return true
+	})
+	return h
+}
+
+// parquetColumnReader 按行顺序逐个返回column chunk中的值，同一时刻只解码一个page
+type parquetColumnReader struct {
+	col   *parquetColumn
+	chunk *parquetChunk
+	r     *bufio.Reader
+	zstd  *zstd.Decoder
+
+	compressed []byte
+	page       []byte
+	levels     []uint32
+	indices    []uint32
+
+	// 字典和当前page的值，只使用与列类型对应的一个
+	dictNames [][]byte
+	dictTemps []int64
+	names     [][]byte
+	temps     []int64
+
+	// 当前page中每一行是否非null，required列为nil
+	valid []bool
+	row   int
+	value int
+}
+
+func newParquetColumnReader(r io.ReaderAt, col *parquetColumn, chunk *parquetChunk) *parquetColumnReader {
+	return &parquetColumnReader{
+		col:   col,
+		chunk: chunk,
+		r:     bufio.NewReaderSize(io.NewSectionReader(r, chunk.offset, chunk.size), 64*1024),
+	}
+}
+
+func (cr *parquetColumnReader) close() {
+	if cr.zstd != nil {
+		cr.zstd.Close()
+	}
+}
+
+// next 返回下一行的值，null时ok为false；station列返回name，temperature列返回以0.1度为单位的val
+func (cr *parquetColumnReader) next() (name []byte, val int64, ok bool, err error) {
+	for cr.row >= len(cr.valid) && cr.value >= len(cr.names)+len(cr.temps) {
+		if err := cr.nextPage(); err != nil {
+			return nil, 0, false, err
+		}
+	}
+	if cr.valid != nil {
+		valid := cr.valid[cr.row]
+		cr.row++
+		if !valid {
+			return nil, 0, false, nil
+		}
+	}
+	i := cr.value
+	cr.value++
+	if cr.col.typ == parquetByteArray {
+		return cr.names[i], 0, true, nil
+	}
+	return nil, cr.temps[i], true, nil
+}
+
+// 读取并解码下一个数据page，遇到字典page时先保存字典
+func (cr *parquetColumnReader) nextPage() error {
+	for {
+		h, err := cr.readPageHeader()
+		if err != nil {
+			return err
+		}
+		if h.compressedSize < 0 || h.uncompressedSize < 0 {
+			return errors.New("invalid parquet page size")
+		}
+		cr.compressed = grow(cr.compressed, int(h.compressedSize))
+		if _, err := io.ReadFull(cr.r, cr.compressed); err != nil {
+			return err
+		}
+
+		switch h.typ {
+		case pageDictionary:
+			data, err := cr.decompress(cr.compressed, int(h.uncompressedSize))
+			if err != nil {
+				return err
+			}
+			// 字典引用page的内存，不能与数据page共用缓冲区
+			data = bytes.Clone(data)
+			cr.dictNames, cr.dictTemps = nil, nil
+			if _, err := cr.plain(data, int(h.numValues), &cr.dictNames, &cr.dictTemps); err != nil {
+				return err
+			}
+		case pageData:
+			data, err := cr.decompress(cr.compressed, int(h.uncompressedSize))
+			if err != nil {
+				return err
+			}
+			if cr.col.optional {
+				if h.defEncoding != encodingRLE || len(data) < 4 {
+					return fmt.Errorf("unsupported parquet definition level encoding %d", h.defEncoding)
+				}
+				n := binary.LittleEndian.Uint32(data)
+				if int64(n) > int64(len(data)-4) {
+					return errors.New("invalid parquet definition levels")
+				}
+				if err := cr.decodeLevels(data[4:4+n], int(h.numValues)); err != nil {
+					return err
+				}
+				data = data[4+n:]
+			}
+			return cr.decodeValues(data, h)
+		case pageDataV2:
+			levels := int(h.defLength) + int(h.repLength)
+			if h.repLength != 0 || h.defLength < 0 || levels > len(cr.compressed) {
+				return errors.New("invalid parquet levels in data page v2")
+			}
+			if cr.col.optional {
+				if err := cr.decodeLevels(cr.compressed[:h.defLength], int(h.numValues)); err != nil {
+					return err
+				}
+			}
+			data := cr.compressed[levels:]
+			if h.isCompressed {
+				data, err = cr.decompress(data, int(h.uncompressedSize)-levels)
+				if err != nil {
+					return err
+				}
+			}
+			return cr.decodeValues(data, h)
+		}
+	}
+}
+
+func (cr *parquetColumnReader) readPageHeader() (parquetPageHeader, error) {
+	// page header很小，但可能带有统计信息，逐步扩大预读的范围直到能够完整解析
+	for size := 256; ; size *= 4 {
+		data, err := cr.r.Peek(size)
+		if len(data) == 0 {
+			if err == io.EOF {
+				return parquetPageHeader{}, io.ErrUnexpectedEOF
+			}
+			return parquetPageHeader{}, err
+		}
+		tr := &thriftReader{data: data}
+		h := readParquetPageHeader(tr)
+		if tr.err == nil {
+			cr.r.Discard(tr.pos)
+			return h, nil
+		}
+		if len(data) < size && err != nil || size >= cr.r.Size() {
+			return parquetPageHeader{}, fmt.Errorf("parquet page header: %w", tr.err)
+		}
+	}
+}
+
+func (cr *parquetColumnReader) decompress(data []byte, size int) ([]byte, error) {
+	if size < 0 {
+		return nil, errors.New("invalid parquet page size")
+	}
+	var err error
+	switch cr.chunk.codec {
+	case codecUncompressed:
+		return data, nil
+	case codecSnappy:
+		cr.page, err = snappy.Decode(cr.page[:cap(cr.page)], data)
+	case codecGzip:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			cr.page = grow(cr.page, size)
+			_, err = io.ReadFull(zr, cr.page)
+		}
+	case codecZstd:
+		if cr.zstd == nil {
+			if cr.zstd, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1)); err != nil {
+				return nil, err
+			}
+		}
+		cr.page, err = cr.zstd.DecodeAll(data, cr.page[:0])
+	default:
+		return nil, fmt.Errorf("unsupported parquet compression codec %d", cr.chunk.codec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cr.page) != size {
+		return nil, errors.New("parquet page size mismatch")
+	}
+	return cr.page, nil
+}
+
+// decodeLevels 解码扁平的optional列的definition level，1表示非null
+func (cr *parquetColumnReader) decodeLevels(data []byte, n int) error {
+	var err error
+	cr.levels, err = decodeRLEHybrid(data, 1, n, cr.levels)
+	if err != nil {
+		return err
+	}
+	cr.valid = cr.valid[:0]
+	for _, l := range cr.levels {
+		cr.valid = append(cr.valid, l == 1)
+	}
+	return nil
+}
+
+func (cr *parquetColumnReader) decodeValues(data []byte, h parquetPageHeader) error {
+	n := int(h.numValues)
+	if cr.col.optional {
+		n = 0
+		for _, v := range cr.valid {
+			if v {
+				n++
+			}
+		}
+	} else {
+		cr.valid = nil
+	}
+	cr.row, cr.value = 0, 0
+	cr.names, cr.temps = cr.names[:0], cr.temps[:0]
+
+	switch h.encoding {
+	case encodingPlain:
+		_, err := cr.plain(data, n, &cr.names, &cr.temps)
+		return err
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if len(data) == 0 {
+			return errors.New("invalid parquet dictionary indices")
+		}
+		var err error
+		cr.indices, err = decodeRLEHybrid(data[1:], int(data[0]), n, cr.indices)
+		if err != nil {
+			return err
+		}
+		for _, i := range cr.indices {
+			if int(i) >= len(cr.dictNames)+len(cr.dictTemps) {
+				return errors.New("parquet dictionary index out of range")
+			}
+			if cr.dictNames != nil {
+				cr.names = append(cr.names, cr.dictNames[i])
+			} else {
+				cr.temps = append(cr.temps, cr.dictTemps[i])
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported parquet encoding %d", h.encoding)
+	}
+}
+
+// plain 按PLAIN编码解码n个值，字符串追加到names，温度换算成0.1度后追加到temps
+func (cr *parquetColumnReader) plain(data []byte, n int, names *[][]byte, temps *[]int64) ([]byte, error) {
+	size := 8
+	if cr.col.typ == parquetInt32 || cr.col.typ == parquetFloat {
+		size = 4
+	}
+	if cr.col.typ == parquetByteArray && *names == nil {
+		*names = make([][]byte, 0, n)
+	}
+	for i := 0; i < n; i++ {
+		if cr.col.typ == parquetByteArray {
+			if len(data) < 4 {
+				return nil, errors.New("truncated parquet page")
+			}
+			l := binary.LittleEndian.Uint32(data)
+			if int64(l) > int64(len(data)-4) {
+				return nil, errors.New("truncated parquet page")
+			}
+			*names = append(*names, data[4:4+l])
+			data = data[4+l:]
+			continue
+		}
+		if len(data) < size {
+			return nil, errors.New("truncated parquet page")
+		}
+		*temps = append(*temps, cr.col.tenths(data[:size]))
+		data = data[size:]
+	}
+	return data, nil
+}
+
+// tenths 将PLAIN编码的温度换算成以0.1度为单位的整数：浮点数四舍五入到一位小数，
+// 整数视为摄氏度，DECIMAL按scale换算
+func (c *parquetColumn) tenths(b []byte) int64 {
+	var v int64
+	switch c.typ {
+	case parquetDouble:
+		return int64(math.Round(math.Float64frombits(binary.LittleEndian.Uint64(b)) * 10))
+	case parquetFloat:
+		return int64(math.Round(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) * 10))
+	case parquetInt32:
+		v = int64(int32(binary.LittleEndian.Uint32(b)))
+	default:
+		v = int64(binary.LittleEndian.Uint64(b))
+	}
+	if !c.decimal {
+		return v * 10
+	}
+	return int64(math.Round(float64(v) * math.Pow10(int(1-c.scale))))
+}
+
+// decodeRLEHybrid 解码n个位宽为bitWidth的RLE/bit-packing混合编码的值
+func decodeRLEHybrid(data []byte, bitWidth, n int, out []uint32) ([]uint32, error) {
+	if bitWidth > 32 {
+		return nil, errors.New("invalid parquet bit width")
+	}
+	out = out[:0]
+	byteWidth := (bitWidth + 7) / 8
+	for len(out) < n {
+		header, k := binary.Uvarint(data)
+		if k <= 0 {
+			return nil, errors.New("truncated parquet rle data")
+		}
+		data = data[k:]
+		if header&1 == 0 {
+			count := int(min(header>>1, uint64(n-len(out))))
+			if len(data) < byteWidth {
+				return nil, errors.New("truncated parquet rle data")
+			}
+			var v uint32
+			for i := 0; i < byteWidth; i++ {
+				v |= uint32(data[i]) << (8 * i)
+			}
+			data = data[byteWidth:]
+			for i := 0; i < count; i++ {
+				out = append(out, v)
+			}
+			continue
+		}
+		groups := header >> 1
+		if groups*uint64(bitWidth) > uint64(len(data)) {
+			return nil, errors.New("truncated parquet bit-packed data")
+		}
+		count := int(min(groups*8, uint64(n-len(out))))
+		for i := 0; i < count; i++ {
+			var v uint32
+			for b := 0; b < bitWidth; b++ {
+				bit := i*bitWidth + b
+				v |= uint32(data[bit/8]>>(bit%8)&1) << b
+			}
+			out = append(out, v)
+		}
+		data = data[int(groups)*bitWidth:]
+	}
+	return out, nil
+}
+
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
+
+// parquetStatistics 每个worker依次领取一个row group，同时读取station和temperature两列
+func (j *job) parquetStatistics(file *os.File) ([]*Statistic, error) {
+	if j.window > 0 {
+		return nil, errors.New("parquet input has no timestamps for windows")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	f, err := readParquetMetadata(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	var next atomic.Int64
+	statistics := make([]*Statistic, j.workers)
+	errs := make([]error, j.workers)
+	wg := &sync.WaitGroup{}
+	for i := range statistics {
+		statistics[i] = j.newStatistic()
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			for !j.interrupted() {
+				k := int(next.Add(1) - 1)
+				if k >= len(f.rowGroups) {
+					return
+				}
+				if err := j.parseLiveRowGroup(file, f, &f.rowGroups[k], statistics[idx]); err != nil {
+					errs[idx] = fmt.Errorf("parquet row group %d: %w", k, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return statistics, nil
+}
+
+// parseLiveRowGroup 与parseRowGroup相同，开启WithProgress时在解析期间持有s.live
+func (j *job) parseLiveRowGroup(r io.ReaderAt, f *parquetFile, rg *parquetRowGroup, s *Statistic) error {
+	if j.progress != nil {
+		s.live.Lock()
+		defer s.live.Unlock()
+	}
+	return j.parseRowGroup(r, f, rg, s)
+}
+
+// parseRowGroup 逐行合并两列的值，任一列为null的行被跳过
+func (j *job) parseRowGroup(r io.ReaderAt, f *parquetFile, rg *parquetRowGroup, s *Statistic) error {
+	station := newParquetColumnReader(r, &f.station, &rg.station)
+	defer station.close()
+	temperature := newParquetColumnReader(r, &f.temperature, &rg.temperature)
+	defer temperature.close()
+
+	rows := s.rows
+	for i := int64(0); i < rg.numRows; i++ {
+		name, _, ok1, err := station.next()
+		if err != nil {
+			return err
+		}
+		_, val, ok2, err := temperature.next()
+		if err != nil {
+			return err
+		}
+		if ok1 && ok2 {
+			s.Add(name, val)
+		}
+	}
+	if j.progress != nil {
+		j.progress.bytes.Add(rg.station.size + rg.temperature.size)
+		j.progress.rows.Add(s.rows - rows)
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// thriftWriter 是测试用的compact protocol编码器，只支持生成Parquet文件需要的类型
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID []int16
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.lastID[len(w.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.uvarint(uint64(id<<1) ^ uint64(id>>15))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) begin() { w.lastID = append(w.lastID, 0) }
+func (w *thriftWriter) end()   { w.buf.WriteByte(thriftStop); w.lastID = w.lastID[:len(w.lastID)-1] }
+func (w *thriftWriter) int(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.uvarint(uint64(int64(v)<<1) ^ uint64(int64(v)>>63))
+}
+func (w *thriftWriter) binary(id int16, b string) {
+	w.field(id, thriftBinary)
+	w.uvarint(uint64(len(b)))
+	w.buf.WriteString(b)
+}
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftTrue)
+	} else {
+		w.field(id, thriftFalse)
+	}
+}
+func (w *thriftWriter) list(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	w.buf.WriteByte(byte(n)<<4 | elem)
+}
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+type testParquetColumn struct {
+	name     string
+	typ      int32
+	optional bool
+	decimal  int32
+	// values 中nil表示null
+	values []any
+}
+
+type testParquetOptions struct {
+	codec      int32
+	dictionary bool
+	v2         bool
+	rowGroups  int
+}
+
+func compressPage(t *testing.T, codec int32, data []byte) []byte {
+	switch codec {
+	case codecSnappy:
+		return snappy.Encode(nil, data)
+	case codecZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer enc.Close()
+		return enc.EncodeAll(data, nil)
+	default:
+		return data
+	}
+}
+
+func plainValue(typ int32, v any) []byte {
+	switch typ {
+	case parquetByteArray:
+		s := v.(string)
+		return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s))), s...)
+	case parquetDouble:
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v.(float64)))
+	case parquetInt32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(v.(int32)))
+	default:
+		return binary.LittleEndian.AppendUint64(nil, uint64(v.(int64)))
+	}
+}
+
+// rle 以bit-packing编码values
+func rle(values []uint32, bitWidth int) []byte {
+	groups := (len(values) + 7) / 8
+	out := binary.AppendUvarint(nil, uint64(groups<<1|1))
+	packed := make([]byte, groups*bitWidth)
+	for i, v := range values {
+		for b := 0; b < bitWidth; b++ {
+			bit := i*bitWidth + b
+			packed[bit/8] |= byte(v>>b&1) << (bit % 8)
+		}
+	}
+	return append(out, packed...)
+}
+
+func writePage(t *testing.T, w *thriftWriter, out *bytes.Buffer, typ int32, opts testParquetOptions, numValues int, levels, values []byte, encoding int32) {
+	var data, compressed []byte
+	if typ == pageDataV2 {
+		data = append(append([]byte{}, levels...), values...)
+		compressed = append(append([]byte{}, levels...), compressPage(t, opts.codec, values)...)
+	} else {
+		if levels != nil {
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(levels)))
+			data = append(data, levels...)
+		}
+		data = append(data, values...)
+		compressed = compressPage(t, opts.codec, data)
+	}
+	w.buf.Reset()
+	w.begin()
+	w.i32(1, typ)
+	w.i32(2, int32(len(data)))
+	w.i32(3, int32(len(compressed)))
+	switch typ {
+	case pageDictionary:
+		w.structField(7)
+		w.i32(1, int32(numValues))
+		w.i32(2, encodingPlain)
+		w.end()
+	case pageData:
+		w.structField(5)
+		w.i32(1, int32(numValues))
+		w.i32(2, encoding)
+		w.i32(3, encodingRLE)
+		w.i32(4, encodingRLE)
+		w.end()
+	case pageDataV2:
+		w.structField(8)
+		w.i32(1, int32(numValues))
+		w.i32(2, 0)
+		w.i32(3, int32(numValues))
+		w.i32(4, encoding)
+		w.i32(5, int32(len(levels)))
+		w.i32(6, 0)
+		w.end()
+	}
+	w.end()
+	out.Write(w.buf.Bytes())
+	out.Write(compressed)
+}
+
+// writeTestParquet 生成扁平schema的Parquet文件，所有列的行数必须相同
+func writeTestParquet(t *testing.T, columns []testParquetColumn, opts testParquetOptions) string {
+	t.Helper()
+	out := &bytes.Buffer{}
+	out.Write(parquetMagic)
+	rows := len(columns[0].values)
+	type chunkMeta struct{ offset, dictOffset, size int64 }
+	var chunks [][]chunkMeta
+	w := &thriftWriter{}
+	for g := 0; g < opts.rowGroups; g++ {
+		lo, hi := rows*g/opts.rowGroups, rows*(g+1)/opts.rowGroups
+		var metas []chunkMeta
+		for _, c := range columns {
+			start := int64(out.Len())
+			meta := chunkMeta{offset: start, dictOffset: -1}
+			var levels []byte
+			var present []any
+			if c.optional {
+				defs := make([]uint32, 0, hi-lo)
+				for _, v := range c.values[lo:hi] {
+					if v == nil {
+						defs = append(defs, 0)
+					} else {
+						defs = append(defs, 1)
+					}
+				}
+				levels = rle(defs, 1)
+			}
+			for _, v := range c.values[lo:hi] {
+				if v != nil {
+					present = append(present, v)
+				}
+			}
+			pageType := int32(pageData)
+			if opts.v2 {
+				pageType = pageDataV2
+			}
+			var values []byte
+			encoding := int32(encodingPlain)
+			if opts.dictionary {
+				var dict []byte
+				index := map[any]uint32{}
+				var indices []uint32
+				for _, v := range present {
+					i, ok := index[v]
+					if !ok {
+						i = uint32(len(index))
+						index[v] = i
+						dict = append(dict, plainValue(c.typ, v)...)
+					}
+					indices = append(indices, i)
+				}
+				meta.dictOffset = start
+				writePage(t, w, out, pageDictionary, opts, len(index), nil, dict, 0)
+				meta.offset = int64(out.Len())
+				values = append([]byte{4}, rle(indices, 4)...)
+				encoding = encodingRLEDictionary
+			} else {
+				for _, v := range present {
+					values = append(values, plainValue(c.typ, v)...)
+				}
+			}
+			writePage(t, w, out, pageType, opts, hi-lo, levels, values, encoding)
+			meta.size = int64(out.Len()) - start
+			metas = append(metas, meta)
+		}
+		chunks = append(chunks, metas)
+	}
+
+	w.buf.Reset()
+	w.begin()
+	w.i32(1, 1)
+	w.list(2, thriftStruct, len(columns)+1)
+	w.begin()
+	w.binary(4, "schema")
+	w.i32(5, int32(len(columns)))
+	w.end()
+	for _, c := range columns {
+		w.begin()
+		w.i32(1, c.typ)
+		if c.optional {
+			w.i32(3, repetitionOptional)
+		} else {
+			w.i32(3, 0)
+		}
+		w.binary(4, c.name)
+		if c.decimal > 0 {
+			w.i32(6, convertedDecimal)
+			w.i32(7, c.decimal)
+		}
+		w.end()
+	}
+	w.int(3, int64(rows))
+	w.list(4, thriftStruct, len(chunks))
+	for g, metas := range chunks {
+		w.begin()
+		w.list(1, thriftStruct, len(metas))
+		for i, m := range metas {
+			w.begin()
+			w.int(2, m.offset)
+			w.structField(3)
+			w.i32(1, columns[i].typ)
+			w.list(2, thriftI32, 1)
+			w.uvarint(0)
+			w.list(3, thriftBinary, 1)
+			w.uvarint(uint64(len(columns[i].name)))
+			w.buf.WriteString(columns[i].name)
+			w.i32(4, opts.codec)
+			w.int(5, int64(rows))
+			w.int(6, m.size)
+			w.int(7, m.size)
+			w.int(9, m.offset)
+			if m.dictOffset >= 0 {
+				w.int(11, m.dictOffset)
+			}
+			w.end()
+			w.end()
+		}
+		w.int(2, 0)
+		w.int(3, int64(rows*(g+1)/opts.rowGroups-rows*g/opts.rowGroups))
+		w.bool(4, false)
+		w.end()
+	}
+	w.end()
+	out.Write(w.buf.Bytes())
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(w.buf.Len())))
+	out.Write(parquetMagic)
+
+	path := filepath.Join(t.TempDir(), "measurements.parquet")
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcessParquet(t *testing.T) {
+	stations := []any{"Hamburg", "Bulawayo", "Hamburg", nil, "Palembang", "Bulawayo", "Hamburg", "Oslo"}
+	doubles := []any{12.0, 8.9, -3.4, 1.0, 38.8, -0.1, 7.0, nil}
+	decimals := []any{int32(120), int32(89), int32(-34), int32(10), int32(388), int32(-1), int32(70), nil}
+	const expected = "{Bulawayo=-0.1/4.4/8.9, Hamburg=-3.4/5.2/12.0, Palembang=38.8/38.8/38.8}\n"
+
+	for _, tc := range []struct {
+		name        string
+		temperature testParquetColumn
+		opts        testParquetOptions
+	}{
+		{"plain", testParquetColumn{typ: parquetDouble, values: doubles}, testParquetOptions{rowGroups: 1}},
+		{"dictionary snappy", testParquetColumn{typ: parquetDouble, values: doubles}, testParquetOptions{codec: codecSnappy, dictionary: true, rowGroups: 3}},
+		{"decimal v2 zstd", testParquetColumn{typ: parquetInt32, decimal: 1, values: decimals}, testParquetOptions{codec: codecZstd, v2: true, rowGroups: 2}},
+		{"dictionary v2", testParquetColumn{typ: parquetInt32, decimal: 1, values: decimals}, testParquetOptions{dictionary: true, v2: true, rowGroups: 4}},
+	} {
+		tc.temperature.name, tc.temperature.optional = parquetTemperatureColumn, true
+		station := testParquetColumn{name: parquetStationColumn, typ: parquetByteArray, optional: true, values: stations}
+		other := testParquetColumn{name: "id", typ: parquetInt64, values: []any{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8)}}
+		path := writeTestParquet(t, []testParquetColumn{other, station, tc.temperature}, tc.opts)
+
+		for _, workers := range []int{1, 3} {
+			results, err := Process(path, WithWorkers(workers))
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			buf := &bytes.Buffer{}
+			results.WriteTo(buf)
+			if buf.String() != expected {
+				t.Errorf("%s/%d: expected %q, got %q", tc.name, workers, expected, buf.String())
+			}
+		}
+	}
+}
+
+func TestProcessParquetMissingColumn(t *testing.T) {
+	path := writeTestParquet(t, []testParquetColumn{
+		{name: parquetStationColumn, typ: parquetByteArray, values: []any{"Hamburg"}},
+	}, testParquetOptions{rowGroups: 1})
+	if _, err := Process(path); err == nil {
+		t.Error("expected error for missing temperature column")
+	}
+}
@@ -0,0 +1,70 @@
+package brc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// WithDumpPartials 在合并之前将每个worker的聚合状态写入dir下的worker-NNN.txt，
+// 内容是该worker解析的各批次的偏移和大小，以及按站点名排序的min、max、sum和count，
+// 便于与参考实现或其它worker数量的运行逐个比较，定位出错的worker和批次。不能与WithSharedTable一起使用
+func WithDumpPartials(dir string) Option {
+	return func(o *options) {
+		o.dumpPartials = dir
+	}
+}
+
+// batchSpan 是worker解析的一个批次在输入中的位置
+type batchSpan struct {
+	offset int64
+	size   int
+}
+
+// writePartials 将statistics中每个worker的状态写入j.dumpPartials
+func (j *job) writePartials(statistics []*Statistic) error {
+	if err := os.MkdirAll(j.dumpPartials, 0o755); err != nil {
+		return err
+	}
+	for i, s := range statistics {
+		path := filepath.Join(j.dumpPartials, fmt.Sprintf("worker-%03d.txt", i))
+		if err := writePartial(path, i, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePartial(path string, worker int, s *Statistic) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	type station struct {
+		name string
+		m    *M
+	}
+	var stations []station
+	s.table.each(func(name []byte, m *M) {
+		stations = append(stations, station{string(name), m})
+	})
+	slices.SortFunc(stations, func(a, b station) int { return strings.Compare(a.name, b.name) })
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# worker %d: %d batches, %d rows, %d stations\n", worker, len(s.spans), s.rows, len(stations))
+	for _, b := range s.spans {
+		fmt.Fprintf(w, "# batch offset=%d bytes=%d\n", b.offset, b.size)
+	}
+	fmt.Fprintln(w, "station;min;max;sum;count")
+	for _, st := range stations {
+		fmt.Fprintf(w, "%s;%s;%s;%s;%d\n", st.name, formatTenths(st.m.Min), formatTenths(st.m.Max), formatTenths(st.m.Sum), st.m.Count)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,49 @@
+package brc
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDumpPartials(t *testing.T) {
+	data := strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n", 1000)
+	path := writeMeasurements(t, data)
+	dir := filepath.Join(t.TempDir(), "partials")
+	if _, err := Process(path, WithDumpPartials(dir), WithWorkers(2), WithEngine(EngineChunk)); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := regexp.MustCompile(`(?m)^# batch offset=(\d+) bytes=(\d+)$`)
+	var bytes, hamburg int
+	for _, name := range []string{"worker-000.txt", "worker-001.txt"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range batch.FindAllStringSubmatch(string(content), -1) {
+			n, _ := strconv.Atoi(m[2])
+			bytes += n
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if rest, ok := strings.CutPrefix(line, "Hamburg;"); ok {
+				fields := strings.Split(rest, ";")
+				if fields[0] != "-3.4" || fields[1] != "12.0" {
+					t.Errorf("%s: unexpected min and max in %q", name, line)
+				}
+				n, _ := strconv.Atoi(fields[3])
+				hamburg += n
+			}
+		}
+	}
+	if bytes != len(data) || hamburg != 2000 {
+		t.Errorf("expected batches covering %d bytes and 2000 Hamburg rows, got %d bytes and %d rows", len(data), bytes, hamburg)
+	}
+
+	if _, err := Process(path, WithDumpPartials(dir), WithSharedTable(true)); err == nil {
+		t.Error("expected error with shared table")
+	}
+}
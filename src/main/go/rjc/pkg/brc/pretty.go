@@ -0,0 +1,74 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// total 返回合并所有站点得到的汇总行，分位数和中位数与各站点一样计算，r不能为空
+func (r Results) total() Station {
+	total := Station{Name: "total", M: newM()}
+	for i := range r {
+		total.Merge(&r[i].M)
+	}
+	totals := Results{total}
+	if ps := r[0].Percentiles; len(ps) > 0 {
+		percentiles := make([]float64, len(ps))
+		for i, p := range ps {
+			percentiles[i] = p.P
+		}
+		totals.computePercentiles(percentiles)
+	}
+	if r[0].Median != nil {
+		totals.computeMedians()
+	}
+	return totals[0]
+}
+
+// WriteTable 输出适合在终端中阅读的对齐表格，列与WriteMarkdown相同，最后一行是所有站点的汇总。
+// limit大于0时只输出前limit个站点，汇总行仍然包括所有站点。没有站点时不输出任何内容
+func (r Results) WriteTable(w io.Writer, limit int, opts WriteOptions) error {
+	if len(r) == 0 {
+		return nil
+	}
+	shown := r
+	if limit > 0 && limit < len(r) {
+		shown = r[:limit]
+	}
+	stations := append(append(Results(nil), shown...), r.total())
+	header, rows, numeric := stations.cells(opts)
+	rows, totalRow := rows[:len(rows)-1], rows[len(rows)-1]
+	widths := columnWidths(header, append(rows, totalRow))
+
+	buf := &bytes.Buffer{}
+	// s非nil时按opts.Thresholds为min、mean和max着色，对齐时不计算转义序列
+	writeRow := func(row []string, s *Station) {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = pad(cell, widths[i], numeric[i])
+			if m := Metric(header[i]); s != nil && numeric[i] && (m == MetricMin || m == MetricMean || m == MetricMax) {
+				cells[i] = s.colorize(m, cells[i], opts)
+			}
+		}
+		buf.WriteString(strings.TrimRight(strings.Join(cells, "  "), " "))
+		buf.WriteByte('\n')
+	}
+	rule := make([]string, len(widths))
+	for i, width := range widths {
+		rule[i] = strings.Repeat("-", width)
+	}
+	writeRow(header, nil)
+	writeRow(rule, nil)
+	for i, row := range rows {
+		writeRow(row, &stations[i])
+	}
+	if len(shown) < len(r) {
+		fmt.Fprintf(buf, "... %d more stations\n", len(r)-len(shown))
+	}
+	writeRow(rule, nil)
+	writeRow(totalRow, nil)
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,50 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTable(t *testing.T) {
+	s := NewStatistic()
+	s.EnableCounts()
+	s.ParseAndAddLines([]byte("b;1.5\nZürich;-12.0\nb;2.5\nc;10.0\n"))
+	results := s.Results()
+	results.computeMedians()
+	for _, tc := range []struct {
+		limit    int
+		expected string
+	}{
+		{0, "" +
+			"station    min   mean    max  count  median\n" +
+			"-------  -----  -----  -----  -----  ------\n" +
+			"Zürich   -12.0  -12.0  -12.0      1   -12.0\n" +
+			"b          1.5    2.0    2.5      2     2.0\n" +
+			"c         10.0   10.0   10.0      1    10.0\n" +
+			"-------  -----  -----  -----  -----  ------\n" +
+			"total    -12.0    0.5   10.0      4     2.0\n"},
+		{1, "" +
+			"station    min   mean    max  count  median\n" +
+			"-------  -----  -----  -----  -----  ------\n" +
+			"Zürich   -12.0  -12.0  -12.0      1   -12.0\n" +
+			"... 2 more stations\n" +
+			"-------  -----  -----  -----  -----  ------\n" +
+			"total    -12.0    0.5   10.0      4     2.0\n"},
+	} {
+		buf := &bytes.Buffer{}
+		if err := results.WriteTable(buf, tc.limit, WriteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("limit %d: expected\n%s\ngot\n%s", tc.limit, tc.expected, buf.String())
+		}
+	}
+	if len(results) != 3 || results[1].Name != "b" {
+		t.Errorf("WriteTable modified the results: %v", results)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := (Results{}).WriteTable(buf, 0, WriteOptions{}); err != nil || buf.Len() != 0 {
+		t.Errorf("expected no output, got %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,76 @@
+package brc
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Progress 记录处理进度，所有方法都可以并发调用
+type Progress struct {
+	bytes atomic.Int64
+	rows  atomic.Int64
+	total atomic.Int64
+
+	// job 是正在进行的处理，用于Snapshot
+	mu  sync.Mutex
+	job *job
+}
+
+// Bytes 返回已解析的字节数
+func (p *Progress) Bytes() int64 {
+	return p.bytes.Load()
+}
+
+// Rows 返回已解析的行数
+func (p *Progress) Rows() int64 {
+	return p.rows.Load()
+}
+
+// Total 返回输入的总字节数，未知时（流或压缩输入）返回0
+func (p *Progress) Total() int64 {
+	return p.total.Load()
+}
+
+// Snapshot 返回正在进行的处理到目前为止的聚合结果，按站点名排序。每个worker只在两个批次之间
+// 被短暂暂停以复制其状态，不影响处理本身。没有正在进行的处理或开启了WithSharedTable时返回nil
+func (p *Progress) Snapshot() Results {
+	p.mu.Lock()
+	j := p.job
+	p.mu.Unlock()
+	if j == nil || j.shared != nil {
+		return nil
+	}
+	return j.snapshot().results(j.options)
+}
+
+// attach 在处理期间将p与j关联，返回的函数解除关联
+func (p *Progress) attach(j *job) (detach func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.job = j
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.job = nil
+	}
+}
+
+// snapshot 依次在每个worker的两个批次之间合并其状态
+func (j *job) snapshot() *MergedStatistics {
+	j.liveMu.Lock()
+	defer j.liveMu.Unlock()
+	merged := mergeStatistics()
+	for _, s := range j.live {
+		s.live.Lock()
+		merged.Merge(mergeStatistics(s))
+		s.live.Unlock()
+	}
+	return merged
+}
+
+// closeLive 等待进行中的快照完成，之后的快照不再读取各worker的状态，在合并之前调用
+func (j *job) closeLive() {
+	j.liveMu.Lock()
+	defer j.liveMu.Unlock()
+	j.live = nil
+}
@@ -0,0 +1,61 @@
+package brc
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProgressSnapshot(t *testing.T) {
+	p := &Progress{}
+	if p.Snapshot() != nil {
+		t.Error("expected no snapshot before processing")
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	type result struct {
+		results Results
+		err     error
+	}
+	done := make(chan result)
+	go func() {
+		results, err := ProcessReader(pr, WithProgress(p), WithWorkers(2), WithStreaming(1, 16))
+		done <- result{results, err}
+	}()
+	if _, err := pw.WriteString("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); p.Rows() < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the first rows")
+		}
+	}
+	snapshot := p.Snapshot()
+	if len(snapshot) != 2 || snapshot[1].Name != "Hamburg" || snapshot[1].Count != 2 {
+		t.Errorf("unexpected snapshot %v", snapshot)
+	}
+
+	// 处理过程中的快照不影响最终结果
+	go func() {
+		for i := 0; i < 100; i++ {
+			p.Snapshot()
+		}
+	}()
+	if _, err := pw.WriteString("Hamburg;1.0\n"); err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if len(r.results) != 2 || r.results[1].Count != 3 {
+		t.Errorf("unexpected results %v", r.results)
+	}
+	if p.Snapshot() != nil {
+		t.Error("expected no snapshot after processing")
+	}
+}
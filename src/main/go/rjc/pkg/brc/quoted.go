@@ -0,0 +1,115 @@
+package brc
+
+import "bytes"
+
+// WithQuoted 支持站点名中包含';'的输入：站点名可以用双引号括起来，引号内的'"'写成`""`，
+// 如`"Foo;Bar";12.3`；也可以不加引号，用`\;`和`\\`转义。站点名不能包含换行符。
+// 不含引号和反斜杠的行与默认格式一样直接引用输入中的站点名，只有需要转义的站点名才会被复制。
+// 非严格模式下跳过引号不完整的行，严格模式下返回*ParseError
+func WithQuoted(quoted bool) Option {
+	return func(o *options) {
+		o.quoted = quoted
+	}
+}
+
+// unquoteName 解析line开头的站点名，返回站点名和';'之后的部分。
+// 站点名需要转义时写入buf[:0]并返回扩容后的buf，否则直接引用line
+func unquoteName(line, buf []byte) (name, rest, newBuf []byte, ok bool) {
+	idx := bytes.IndexByte(line, ';')
+	if idx < 0 {
+		return nil, nil, buf, false
+	}
+	if (idx == 0 || line[0] != '"') && bytes.IndexByte(line[:idx], '\\') < 0 {
+		return line[:idx], line[idx+1:], buf, true
+	}
+
+	buf = buf[:0]
+	if line[0] == '"' {
+		for i := 1; i < len(line); i++ {
+			if line[i] != '"' {
+				buf = append(buf, line[i])
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '"' {
+				buf = append(buf, '"')
+				i++
+				continue
+			}
+			// 右引号之后必须紧跟';'
+			if i+1 < len(line) && line[i+1] == ';' {
+				return buf, line[i+2:], buf, true
+			}
+			return nil, nil, buf, false
+		}
+		return nil, nil, buf, false
+	}
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if i+1 == len(line) {
+				return nil, nil, buf, false
+			}
+			i++
+			buf = append(buf, line[i])
+		case ';':
+			return buf, line[i+1:], buf, true
+		default:
+			buf = append(buf, line[i])
+		}
+	}
+	return nil, nil, buf, false
+}
+
+// parseTenthsLenient 与ParseAndAddLines一样只累计数字，忽略其它字符
+func parseTenthsLenient(num []byte) int64 {
+	val := int64(0)
+	for _, c := range num {
+		if c >= '0' && c <= '9' {
+			val = val*10 + int64(c-'0')
+		}
+	}
+	if len(num) > 0 && num[0] == '-' {
+		val = -val
+	}
+	return val
+}
+
+// ParseAndAddLinesQuoted 解析WithQuoted格式的多行数据，offset是lines在输入中的起始偏移。
+// strict为true时与ParseAndAddLinesStrict一样校验温度的格式，遇到第一个不合法的行时返回*ParseError；
+// 否则跳过空行和引号不完整的行
+func (s *Statistic) ParseAndAddLinesQuoted(lines []byte, offset int64, strict bool) error {
+	for len(lines) > 0 {
+		line, next := lines, len(lines)
+		if end := bytes.IndexByte(lines, '\n'); end >= 0 {
+			line, next = lines[:end], end+1
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		var name, num []byte
+		var ok bool
+		name, num, s.unquoted, ok = unquoteName(line, s.unquoted)
+		var val int64
+		if strict {
+			if ok && len(name) > 0 && s.validName(name) {
+				val, ok = parseTenths(num)
+			} else {
+				ok = false
+			}
+			if !ok {
+				return &ParseError{Offset: offset, Line: string(line)}
+			}
+			if s.spec {
+				if reason := specViolation(name, val); reason != "" {
+					return &ParseError{Offset: offset, Line: string(line), Reason: reason}
+				}
+			}
+		} else {
+			val = parseTenthsLenient(num)
+		}
+		if ok {
+			s.Add(name, val)
+		}
+		lines = lines[next:]
+		offset += int64(next)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package brc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnquoteName(t *testing.T) {
+	tests := []struct {
+		line, name, rest string
+		ok               bool
+	}{
+		{"Hamburg;12.0", "Hamburg", "12.0", true},
+		{`"Foo;Bar";12.3`, "Foo;Bar", "12.3", true},
+		{`"Say ""hi""";1.0`, `Say "hi"`, "1.0", true},
+		{`"";1.0`, "", "1.0", true},
+		{`Foo\;Bar;-1.5`, "Foo;Bar", "-1.5", true},
+		{`C:\\Temp;2.0`, `C:\Temp`, "2.0", true},
+		{`Mid"quote;3.0`, `Mid"quote`, "3.0", true},
+		{`"Foo;Bar`, "", "", false},
+		{`"Foo"x;1.0`, "", "", false},
+		{`Foo\`, "", "", false},
+		{"Hamburg", "", "", false},
+	}
+	var buf []byte
+	for _, test := range tests {
+		var name, rest []byte
+		var ok bool
+		name, rest, buf, ok = unquoteName([]byte(test.line), buf)
+		if ok != test.ok || ok && (string(name) != test.name || string(rest) != test.rest) {
+			t.Errorf("%q: expected %q, %q, %v, got %q, %q, %v", test.line, test.name, test.rest, test.ok, name, rest, ok)
+		}
+	}
+}
+
+func TestProcessQuoted(t *testing.T) {
+	const data = "\"Foo;Bar\";12.3\nFoo\\;Bar;-2.3\nHamburg;1.0\n\"Hamburg\";3.0\n\n\"broken;1.0\n"
+	path := writeMeasurements(t, data)
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		results, err := Process(path, WithEngine(engine), WithQuoted(true))
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if len(results) != 2 || results[0].Name != "Foo;Bar" || results[0].Count != 2 || results[0].Max != 123 ||
+			results[1].Name != "Hamburg" || results[1].Count != 2 || results[1].Sum != 40 {
+			t.Errorf("%s: unexpected results %v", engine, results)
+		}
+	}
+
+	_, err := Process(path, WithQuoted(true), WithStrict(true))
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Offset != int64(len(data)-len("\n\"broken;1.0\n")) {
+		t.Errorf("expected error at the empty line, got %v", err)
+	}
+}
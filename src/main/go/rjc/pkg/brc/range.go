@@ -0,0 +1,83 @@
+package brc
+
+import (
+	"errors"
+	"io"
+)
+
+// WithRange 只聚合普通文件中从[start, end)区间内开始的行，end为0表示到文件末尾。
+// 两端都向后对齐到下一行的开头，相邻的区间恰好把每一行分给其中一个，
+// 多台机器可以分别处理共享存储上同一个文件的不同部分
+func WithRange(start, end int64) Option {
+	return func(o *options) {
+		o.rangeStart, o.rangeEnd = start, end
+	}
+}
+
+// applyRange 将处理区间对齐到行首，保存在j.start和j.end中。
+// EngineScanner直接读取区间内的数据，其它引擎由newScheduler只分配区间内的任务
+func (j *job) applyRange(r io.Reader, engine Engine) (io.Reader, error) {
+	file, ok := r.(randomAccess)
+	if _, mem := r.(*memoryReader); !ok || mem || j.size == 0 {
+		return nil, errors.New("range requires an uncompressed regular file")
+	}
+	if j.checkpoint != "" {
+		return nil, errors.New("range cannot be used with checkpoints")
+	}
+	if engine == EngineParquet {
+		return nil, errors.New("parquet engine does not support ranges")
+	}
+	end := j.rangeEnd
+	if end <= 0 || end > j.size {
+		end = j.size
+	}
+	var buf [256]byte
+	start, err := nextLineStart(file, min(j.rangeStart, end), j.size, buf[:])
+	if err != nil {
+		return nil, err
+	}
+	if end, err = nextLineStart(file, end, j.size, buf[:]); err != nil {
+		return nil, err
+	}
+	j.start, j.end = start, max(start, end)
+	if j.progress != nil {
+		j.progress.total.Store(j.end - j.start)
+	}
+	if engine != EngineScanner {
+		return file, nil
+	}
+	if _, err := file.Seek(j.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.LimitReader(file, j.end-j.start), nil
+}
+
+// newScheduler 返回在[j.start, j.end)区间内分配任务的scheduler，没有指定区间时分配整个输入
+func (j *job) newScheduler(r io.ReaderAt, size int64) *scheduler {
+	s := newScheduler(r, size, j.workers)
+	s.pos = j.start
+	s.sample = j.newSampler(0)
+	if j.end > 0 {
+		s.size = j.end
+	}
+	return s
+}
+
+// SplitLines 将大小为size的输入按行切分成大小接近的parts份，返回parts+1个偏移，
+// 第i份是[offsets[i], offsets[i+1])。行比每一份还长时部分区间为空
+func SplitLines(r io.ReaderAt, size int64, parts int) ([]int64, error) {
+	if parts <= 0 {
+		return nil, errors.New("parts must be positive")
+	}
+	var buf [256]byte
+	offsets := make([]int64, parts+1)
+	for i := 1; i < parts; i++ {
+		off, err := nextLineStart(r, max(offsets[i-1], size*int64(i)/int64(parts)), size, buf[:])
+		if err != nil {
+			return nil, err
+		}
+		offsets[i] = off
+	}
+	offsets[parts] = size
+	return offsets, nil
+}
@@ -0,0 +1,95 @@
+package brc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimiter 以令牌桶限制每秒聚合的行数和字节数，可以被多个worker、多次处理以及Aggregator的写入方共用，
+// 用于在共享的机器上让出CPU和IO
+type RateLimiter struct {
+	rows  *tokenBucket
+	bytes *tokenBucket
+	// throttled 是因为限速而等待的总纳秒数
+	throttled atomic.Int64
+}
+
+// NewRateLimiter 返回每秒最多rows行、bytes字节的RateLimiter，不大于0表示对应的量不限制，都不限制时返回nil。
+// 桶的容量为1秒的配额，空闲之后最多可以一次处理1秒的数据
+func NewRateLimiter(rows, bytes float64) *RateLimiter {
+	if rows <= 0 && bytes <= 0 {
+		return nil
+	}
+	return &RateLimiter{rows: newTokenBucket(rows), bytes: newTokenBucket(bytes)}
+}
+
+// WithRateLimit 每解析完一个批次后按l限速，超过速率时worker暂停，流式输入因此停止读取。
+// 等待在WithContext的ctx结束时立即返回
+func WithRateLimit(l *RateLimiter) Option {
+	return func(o *options) {
+		o.rateLimit = l
+	}
+}
+
+// Wait 记录已经处理了rows行、bytes字节，超过速率时阻塞到配额恢复为止；ctx结束时立即返回ctx.Err()。
+// 一次处理的量可以超过桶的容量，超出的部分由之后的等待偿还，因此长期的平均速率不会超过限制
+func (l *RateLimiter) Wait(ctx context.Context, rows, bytes int) error {
+	if l == nil {
+		return nil
+	}
+	now := time.Now()
+	d := max(l.rows.take(float64(rows), now), l.bytes.take(float64(bytes), now))
+	if d <= 0 {
+		return nil
+	}
+	l.throttled.Add(int64(d))
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Throttled 返回因为限速而等待的总时间，多个goroutine同时等待时分别累加
+func (l *RateLimiter) Throttled() time.Duration {
+	if l == nil {
+		return 0
+	}
+	return time.Duration(l.throttled.Load())
+}
+
+// tokenBucket 是每秒补充rate个令牌、容量为rate的令牌桶，rate不大于0时不限制
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate}
+}
+
+// take 在now取出n个令牌，令牌不足时余额为负，返回余额恢复到0需要等待的时间。
+// 之后的调用在负的余额上继续扣除，所以并发的调用方依次排队而不是同时醒来
+func (b *tokenBucket) take(n float64, now time.Time) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,56 @@
+package brc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(100)
+	now := time.Unix(0, 0)
+	// 初始时桶是满的，可以一次取出1秒的配额
+	if d := b.take(100, now); d != 0 {
+		t.Errorf("expected a full bucket, got wait %s", d)
+	}
+	if d := b.take(50, now); d != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms, got %s", d)
+	}
+	// 欠下的令牌由之后的调用继续偿还
+	if d := b.take(50, now.Add(500*time.Millisecond)); d != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms after repaying, got %s", d)
+	}
+	// 空闲再久也最多积累1秒的配额
+	if d := b.take(150, now.Add(time.Hour)); d != 500*time.Millisecond {
+		t.Errorf("expected the burst to be capped at one second, got wait %s", d)
+	}
+	if d := newTokenBucket(0).take(1e9, now); d != 0 {
+		t.Errorf("expected no limit, got wait %s", d)
+	}
+}
+
+func TestProcessRateLimit(t *testing.T) {
+	if NewRateLimiter(0, 0) != nil {
+		t.Error("expected no limiter without limits")
+	}
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n")
+	// 3行超出了每秒2行的配额，批次之后等待500ms
+	l := NewRateLimiter(2, 0)
+	start := time.Now()
+	results, err := Process(path, WithEngine(EngineScanner), WithRateLimit(l))
+	if err != nil || len(results) != 2 {
+		t.Fatalf("expected complete results, got %v, %v", results, err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || l.Throttled() < 400*time.Millisecond {
+		t.Errorf("expected to be throttled for 500ms, took %s, throttled %s", elapsed, l.Throttled())
+	}
+
+	// ctx结束时不再等待
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	Process(path, WithEngine(EngineScanner), WithRateLimit(NewRateLimiter(0, 1)), WithContext(ctx))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the wait to end with the context, took %s", elapsed)
+	}
+}
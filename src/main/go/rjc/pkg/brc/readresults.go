@@ -0,0 +1,159 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 站点名可以包含"="和", "，非贪婪匹配保证每个站点名在第一个满足格式的"="处结束
+var resultEntry = regexp.MustCompile(`^(.*?)=(-?[0-9]+\.[0-9])/(-?[0-9]+\.[0-9])/(-?[0-9]+\.[0-9])(?:, |$)`)
+
+// ReadResults 解析Write输出的`{a=1.0/2.0/3.0, ...}`格式或WriteJSON输出的格式（包括缩进的），
+// 用于合并分别处理不同输入得到的结果。
+// 文本格式没有观测次数，站点的Count为0，Sum为以0.1度为单位的平均值；
+// JSON中的mean只保留了一位小数，没有sum字段时Sum按mean*count还原，这样的站点不能由MergeResults与其它结果合并；
+// 有sum字段（-aggregate包括sum）时使用sum，结果是精确的。格式不正确时返回*ResultsFormatError
+func ReadResults(data []byte) (Results, error) {
+	r, err := readResults(data)
+	if err != nil {
+		return nil, &ResultsFormatError{Err: err}
+	}
+	return r, nil
+}
+
+// ResultsFormatError 表示ReadResults读取的结果格式不正确
+type ResultsFormatError struct {
+	Err error
+}
+
+func (e *ResultsFormatError) Error() string { return e.Err.Error() }
+func (e *ResultsFormatError) Unwrap() error { return e.Err }
+
+func readResults(data []byte) (Results, error) {
+	data = bytes.TrimSpace(data)
+	if json.Valid(data) {
+		return readJSONResults(data)
+	}
+	s := string(data)
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("results are neither json nor enclosed in braces")
+	}
+	s = s[1 : len(s)-1]
+	var r Results
+	for len(s) > 0 {
+		m := resultEntry.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("invalid results near %q", s[:min(len(s), 40)])
+		}
+		st := Station{Name: m[1]}
+		var err error
+		for i, v := range []*int64{&st.Min, &st.Sum, &st.Max} {
+			if *v, err = parseDecimal(m[2+i]); err != nil {
+				return nil, err
+			}
+		}
+		r = append(r, st)
+		s = s[len(m[0]):]
+	}
+	return r, nil
+}
+
+// readJSONResults 解析WriteJSON的输出，每个站点需要min、max和count，以及mean或sum
+func readJSONResults(data []byte) (Results, error) {
+	var stations map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &stations); err != nil {
+		return nil, err
+	}
+	r := make(Results, 0, len(stations))
+	for name, fields := range stations {
+		st := Station{Name: name}
+		value := func(key string) (int64, error) {
+			raw, ok := fields[key]
+			if !ok {
+				return 0, fmt.Errorf("station %q has no %s", name, key)
+			}
+			v, err := parseDecimal(string(raw))
+			if err != nil {
+				return 0, fmt.Errorf("station %q: invalid %s: %w", name, key, err)
+			}
+			return v, nil
+		}
+		var err error
+		if st.Min, err = value("min"); err != nil {
+			return nil, err
+		}
+		if st.Max, err = value("max"); err != nil {
+			return nil, err
+		}
+		if st.Count, err = strconv.Atoi(string(fields["count"])); err != nil || st.Count <= 0 {
+			return nil, fmt.Errorf("station %q has no valid count", name)
+		}
+		if _, ok := fields["sum"]; ok {
+			st.Sum, err = value("sum")
+		} else {
+			var mean int64
+			mean, err = value("mean")
+			st.Sum, st.roundedSum = mean*int64(st.Count), true
+		}
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, st)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Name < r[j].Name })
+	return r, nil
+}
+
+// parseDecimal 将最多一位小数的十进制数解析为以0.1为单位的整数
+func parseDecimal(s string) (int64, error) {
+	whole, frac, ok := strings.Cut(s, ".")
+	if ok && len(frac) != 1 {
+		return 0, fmt.Errorf("invalid number %q, want at most one decimal", s)
+	}
+	if !ok {
+		frac = "0"
+	}
+	v, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
+
+// MergeResults 合并分别处理不同输入得到的结果，返回按站点名排序的结果，不修改inputs。
+// 出现在多个结果中的站点需要精确的观测次数和总和，即ReadResults读取的带sum字段的JSON，否则返回错误；
+// 只出现在一个结果中并且没有观测次数的站点按1次观测输出
+func MergeResults(inputs ...Results) (Results, error) {
+	index := make(map[string]int)
+	var merged Results
+	for _, r := range inputs {
+		for _, s := range r {
+			m := M{Count: s.Count, Min: s.Min, Max: s.Max, Sum: s.Sum, SumSq: s.SumSq}
+			i, ok := index[s.Name]
+			if !ok {
+				index[s.Name] = len(merged)
+				merged = append(merged, Station{Name: s.Name, M: m, roundedSum: s.roundedSum})
+				continue
+			}
+			if m.Count == 0 || merged[i].Count == 0 || s.roundedSum || merged[i].roundedSum {
+				return nil, fmt.Errorf("station %q appears in several results, merging it needs json results with counts and sums, e.g. from -format json -aggregate min,max,sum,count", s.Name)
+			}
+			merged[i].Merge(&m)
+		}
+	}
+	for i := range merged {
+		if merged[i].Count == 0 {
+			merged[i].Count = 1
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged, nil
+}
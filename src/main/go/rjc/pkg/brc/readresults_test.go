@@ -0,0 +1,86 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadResults(t *testing.T) {
+	r, err := ReadResults([]byte("{a=b=-1.5/0.3/2.0, c, d=10.0/10.0/10.0}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 2 || r[0].Name != "a=b" || r[0].Min != -15 || r[0].Sum != 3 || r[0].Max != 20 || r[0].Count != 0 ||
+		r[1].Name != "c, d" || r[1].Sum != 100 {
+		t.Errorf("unexpected text results %+v", r)
+	}
+
+	r, err = ReadResults([]byte(`{
+  "a": {"min": -1.5, "mean": 0.5, "max": 2.0, "count": 4, "stddev": 1.2},
+  "b": {"min": 1.0, "max": 3.0, "count": 3, "sum": 6.1}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 2 || r[0].Count != 4 || r[0].Sum != 20 || r[1].Count != 3 || r[1].Sum != 61 || r[1].Max != 30 {
+		t.Errorf("unexpected json results %+v", r)
+	}
+
+	for _, data := range []string{"a=1.0/2.0/3.0", "{a=1.0/2.0}", `{"a":{"min":1.0,"max":2.0}}`, `{"a":{"min":1.0,"max":2.0,"count":1,"mean":1.55}}`} {
+		var ferr *ResultsFormatError
+		if _, err := ReadResults([]byte(data)); !errors.As(err, &ferr) {
+			t.Errorf("%s: expected format error, got %v", data, err)
+		}
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	parts := []string{"a;1.0\nb;-2.5\na;1.1\n", "a;3.3\nc;7.0\nb;0.0\n", "c;-7.7\n", "d;1.0\nd;1.1\n", "d;1.0\nd;1.0\n"}
+	whole := NewStatistic()
+	var inputs []Results
+	for _, part := range parts {
+		whole.ParseAndAddLines([]byte(part))
+		s := NewStatistic()
+		s.ParseAndAddLines([]byte(part))
+		buf := &bytes.Buffer{}
+		if err := s.Results().WriteJSON(buf, WriteOptions{Aggregates: []Aggregate{AggregateMin, AggregateMax, AggregateSum, AggregateCount}}); err != nil {
+			t.Fatal(err)
+		}
+		r, err := ReadResults(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, r)
+	}
+	merged, err := MergeResults(inputs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected bytes.Buffer
+	merged.WriteTo(&got)
+	whole.Results().WriteTo(&expected)
+	if got.String() != expected.String() {
+		t.Errorf("expected %q, got %q", expected.String(), got.String())
+	}
+
+	// 没有sum时还原的总和不精确，只保留一位小数的平均值不能合并
+	a1, _ := ReadResults([]byte(`{"a":{"min":1.0,"mean":1.1,"max":1.1,"count":2}}`))
+	a2, _ := ReadResults([]byte(`{"a":{"min":1.0,"mean":1.0,"max":1.0,"count":2}}`))
+	if _, err := MergeResults(a1, a2); err == nil {
+		t.Error("expected error for a station in several json results without sum")
+	}
+	if merged, err := MergeResults(a1); err != nil || merged[0].Count != 2 || merged[0].Sum != 22 {
+		t.Errorf("expected a single json result to be kept, got %+v, %v", merged, err)
+	}
+
+	// 文本格式没有count，只能合并不重叠的站点
+	a, _ := ReadResults([]byte("{a=1.0/2.0/3.0}"))
+	b, _ := ReadResults([]byte("{b=1.0/1.5/2.0}"))
+	if merged, err := MergeResults(a, b); err != nil || len(merged) != 2 || merged[0].Count != 1 {
+		t.Errorf("expected disjoint text results to merge, got %+v, %v", merged, err)
+	}
+	if _, err := MergeResults(a, a); err == nil {
+		t.Error("expected error for a station in several text results")
+	}
+}
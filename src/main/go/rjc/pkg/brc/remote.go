@@ -0,0 +1,338 @@
+package brc
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 请求失败或服务端返回5xx时的重试次数
+const remoteRetries = 3
+
+// input 是Process读取的本地文件或远程对象
+type input interface {
+	io.Reader
+	io.Closer
+}
+
+// randomAccess 是可以按偏移读取的输入，EngineChunk和WithRange需要
+type randomAccess interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// IsRemote 报告path是否是对象存储或HTTP(S)的URL，如s3://bucket/key、gs://bucket/object或https://host/file
+func IsRemote(path string) bool {
+	for _, scheme := range []string{"s3://", "gs://", "http://", "https://"} {
+		if strings.HasPrefix(path, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// openInput 打开本地文件或IsRemote的远程对象
+func openInput(path string) (input, error) {
+	if IsRemote(path) {
+		return openRemote(path)
+	}
+	return os.Open(path)
+}
+
+// remoteObject 是对象存储或支持Range请求的HTTP服务器上的输入。ReadAt对每次读取发起一个Range请求，
+// EngineChunk的各个worker因此并行下载各自的区间，下载与解析重叠；Read从当前位置开始流式下载
+type remoteObject struct {
+	url    string
+	size   int64
+	client *http.Client
+	// sign 在发送前为请求添加认证信息
+	sign func(*http.Request) error
+
+	pos  int64
+	body io.ReadCloser
+}
+
+// openRemote 打开s3://、gs://或http(s)://的对象。S3使用AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN、
+// AWS_REGION和AWS_ENDPOINT_URL，没有凭证时匿名访问；GCS使用GOOGLE_OAUTH_ACCESS_TOKEN和STORAGE_EMULATOR_HOST。
+// HTTP服务器不支持Range请求时返回响应体，按流读取整个对象
+func openRemote(path string) (input, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid object URL %q, want %s://bucket/key", path, u.Scheme)
+	}
+	var o *remoteObject
+	switch u.Scheme {
+	case "s3":
+		o = newS3Object(u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "gs":
+		o = newGCSObject(u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "http", "https":
+		o = &remoteObject{url: path, client: http.DefaultClient, sign: func(*http.Request) error { return nil }}
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q, want s3, gs, http or https", u.Scheme)
+	}
+	resp, err := o.get(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK && resp.ContentLength != 0 && strings.HasPrefix(u.Scheme, "http") {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+	if o.size, err = o.stat(resp); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// get 请求对象的[start, end]区间，end小于0时请求到对象末尾，网络错误和5xx会重试
+func (o *remoteObject) get(start, end int64) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, o.url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if end >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+		}
+		if err := o.sign(req); err != nil {
+			return nil, err
+		}
+		resp, err := o.client.Do(req)
+		if err == nil && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("%s: %s", o.url, resp.Status)
+		}
+		if attempt == remoteRetries {
+			return nil, err
+		}
+		time.Sleep(time.Duration(100<<attempt) * time.Millisecond)
+	}
+}
+
+// statusError 将非预期的响应转换为错误，对象不存在时包含fs.ErrNotExist
+func (o *remoteObject) statusError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", o.url, fs.ErrNotExist)
+	}
+	return fmt.Errorf("%s: %s", o.url, resp.Status)
+}
+
+// stat 从只请求第一个字节的Range请求的响应中获取对象的大小
+func (o *remoteObject) stat(resp *http.Response) (int64, error) {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		size, err := strconv.ParseInt(total, 10, 64)
+		if !ok || err != nil {
+			return 0, fmt.Errorf("%s: invalid Content-Range %q", o.url, resp.Header.Get("Content-Range"))
+		}
+		return size, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 空对象没有可以请求的字节
+		return 0, nil
+	case http.StatusOK:
+		// 有的服务端对空对象忽略Range
+		if resp.ContentLength == 0 {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("%s: server does not support range requests", o.url)
+	}
+	return 0, o.statusError(resp)
+}
+
+func (o *remoteObject) ReadAt(p []byte, off int64) (int, error) {
+	if off >= o.size {
+		return 0, io.EOF
+	}
+	n := min(int64(len(p)), o.size-off)
+	resp, err := o.get(off, off+n-1)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, o.statusError(resp)
+	}
+	read, err := io.ReadFull(resp.Body, p[:n])
+	if err == nil && n < int64(len(p)) {
+		err = io.EOF
+	}
+	return read, err
+}
+
+func (o *remoteObject) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		resp, err := o.get(o.pos, -1)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return 0, o.statusError(resp)
+		}
+		o.body = resp.Body
+	}
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	if err == io.EOF && o.pos < o.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (o *remoteObject) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.pos
+	case io.SeekEnd:
+		offset += o.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	if offset != o.pos && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.pos = offset
+	return offset, nil
+}
+
+func (o *remoteObject) Close() error {
+	if o.body != nil {
+		return o.body.Close()
+	}
+	return nil
+}
+
+// escapeObjectKey 按RFC 3986转义对象名，保留'/'，与SigV4的规范URI一致
+func escapeObjectKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// awsCredentials 是SigV4签名使用的凭证
+type awsCredentials struct {
+	accessKey string
+	secretKey string
+	token     string
+}
+
+// newS3Object 返回S3上的对象，指定了AWS_ENDPOINT_URL_S3或AWS_ENDPOINT_URL时使用path-style的地址，
+// 以便访问MinIO等兼容S3的服务
+func newS3Object(bucket, key string) *remoteObject {
+	region := cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	target := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, escapeObjectKey(key))
+	if endpoint := cmp.Or(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); endpoint != "" {
+		target = strings.TrimRight(endpoint, "/") + "/" + bucket + "/" + escapeObjectKey(key)
+	}
+	creds := awsCredentials{os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}
+	return &remoteObject{url: target, client: http.DefaultClient, sign: func(req *http.Request) error {
+		if creds.accessKey != "" {
+			signV4(req, creds, region, time.Now().UTC())
+		}
+		return nil
+	}}
+}
+
+// newGCSObject 返回Google Cloud Storage上的对象，通过XML API的Range请求读取。
+// GOOGLE_OAUTH_ACCESS_TOKEN非空时作为Bearer token，否则匿名访问公开的对象
+func newGCSObject(bucket, object string) *remoteObject {
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = strings.TrimRight(host, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	}
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	return &remoteObject{url: endpoint + "/" + bucket + "/" + escapeObjectKey(object), client: http.DefaultClient, sign: func(req *http.Request) error {
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return nil
+	}}
+}
+
+// emptySHA256 是空请求体的SHA-256
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signV4 用AWS Signature Version 4为没有请求体的S3请求签名，签名覆盖host、range和所有x-amz-*头
+func signV4(req *http.Request, creds awsCredentials, region string, t time.Time) {
+	amzDate, date := t.Format("20060102T150405Z"), t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if creds.token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.token)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, v := range req.Header {
+		if name = strings.ToLower(name); name == "range" || strings.HasPrefix(name, "x-amz-") {
+			values[name] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers.String(), signed, emptySHA256}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	scope := date + "/" + region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		creds.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
@@ -0,0 +1,278 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Station 是单个站点的聚合结果
+type Station struct {
+	Name string
+	M
+	// Percentiles 仅在开启分位数统计时非空
+	Percentiles []Percentile
+	// Median 仅在开启精确中位数时非nil，以0.1度为单位
+	Median *float64
+	// roundedSum 表示Sum是ReadResults由保留一位小数的平均值还原的，不能与其它结果精确合并
+	roundedSum bool
+}
+
+// Percentile 是分位数的估计值
+type Percentile struct {
+	// P 是0到100之间的百分位，如50、90、99
+	P float64
+	// Value 以0.1度为单位
+	Value float64
+}
+
+func (p Percentile) Name() string {
+	return "p" + strconv.FormatFloat(p.P, 'f', -1, 64)
+}
+
+func (r Results) computePercentiles(percentiles []float64) {
+	for i := range r {
+		s := &r[i]
+		if s.Sketch == nil {
+			continue
+		}
+		s.Percentiles = make([]Percentile, len(percentiles))
+		for k, p := range percentiles {
+			// 估计值不会超出实际的最小最大值，p0和p100直接使用精确值
+			v := max(float64(s.Min), min(float64(s.Max), s.Sketch.Quantile(p/100)))
+			if p == 0 {
+				v = float64(s.Min)
+			} else if p == 100 {
+				v = float64(s.Max)
+			}
+			s.Percentiles[k] = Percentile{P: p, Value: v}
+		}
+	}
+}
+
+func (r Results) computeMedians() {
+	for i := range r {
+		if s := &r[i]; s.Counts != nil {
+			median := s.Counts.Median()
+			s.Median = &median
+		}
+	}
+}
+
+// Results 是按站点名排序的聚合结果
+type Results []Station
+
+func newResults(names []string, measures []M) Results {
+	r := make(Results, len(measures))
+	for i := range measures {
+		r[i] = Station{Name: names[i], M: measures[i]}
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Name < r[j].Name
+	})
+	return r
+}
+
+// Metric 是用于对站点排序的统计量
+type Metric string
+
+const (
+	MetricName  Metric = "name"
+	MetricMean  Metric = "mean"
+	MetricMax   Metric = "max"
+	MetricMin   Metric = "min"
+	MetricCount Metric = "count"
+)
+
+func (s *Station) metric(by Metric) float64 {
+	switch by {
+	case MetricMax:
+		return float64(s.Max)
+	case MetricMin:
+		return float64(s.Min)
+	case MetricCount:
+		return float64(s.Count)
+	default:
+		return s.mean()
+	}
+}
+
+// Top 返回按by排序的前n个站点：mean、max和count从大到小，min从小到大即最冷的站点，
+// 值相同时按站点名排序
+func (r Results) Top(n int, by Metric) (Results, error) {
+	switch by {
+	case MetricMean, MetricMax, MetricMin, MetricCount:
+	default:
+		return nil, fmt.Errorf("unknown metric %q, want mean, max, min or count", by)
+	}
+	top := append(Results(nil), r...)
+	sort.SliceStable(top, func(i, j int) bool {
+		if by == MetricMin {
+			return top[i].metric(by) < top[j].metric(by)
+		}
+		return top[i].metric(by) > top[j].metric(by)
+	})
+	return top[:min(n, len(top))], nil
+}
+
+// Sort 返回按by从小到大排序的结果，desc为true时从大到小，统计量相同时按站点名从小到大排序
+func (r Results) Sort(by Metric, desc bool) (Results, error) {
+	switch by {
+	case MetricName, MetricMean, MetricMax, MetricMin, MetricCount:
+	default:
+		return nil, fmt.Errorf("unknown metric %q, want name, mean, max, min or count", by)
+	}
+	sorted := append(Results(nil), r...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := &sorted[i], &sorted[j]
+		if by == MetricName {
+			return (a.Name < b.Name) != desc
+		}
+		if x, y := a.metric(by), b.metric(by); x != y {
+			return (x < y) != desc
+		}
+		return a.Name < b.Name
+	})
+	return sorted, nil
+}
+
+// MinCount 将结果分为观测次数不少于n的kept和少于n的omitted，两者都保持原来的顺序
+func (r Results) MinCount(n int) (kept, omitted Results) {
+	for _, s := range r {
+		if s.Count >= n {
+			kept = append(kept, s)
+		} else {
+			omitted = append(omitted, s)
+		}
+	}
+	return kept, omitted
+}
+
+// Column 是输出中除min/mean/max/count之外的附加统计量
+type Column struct {
+	Name string
+	// Value 返回该统计量的值，单位为输入数据的度
+	Value func(s *Station) float64
+	// Degree 是Value中温差的次数，换算单位时按比例的Degree次方缩放，0表示与单位无关
+	Degree int
+	// value 非空时代替Value和Degree，直接返回换算为opts.Unit后的值，用于派生统计量
+	value func(s *Station, opts WriteOptions) float64
+}
+
+var (
+	StddevColumn   = Column{Name: "stddev", Value: func(s *Station) float64 { return s.Stddev() }, Degree: 1}
+	VarianceColumn = Column{Name: "variance", Value: func(s *Station) float64 { return s.Variance() }, Degree: 2}
+)
+
+// WriteOptions 控制结果的输出方式
+type WriteOptions struct {
+	// Pretty 缩进输出，仅对json有效
+	Pretty bool
+	// Columns 追加在分位数之后输出
+	Columns []Column
+	// Buckets 大于0时在json和csv中输出每个站点的直方图，需要开启WithHistogram
+	Buckets int
+	// InputUnit 是输入数据的温度单位，Unit是输出的单位，两者不同时在输出时换算，
+	// 聚合过程仍然以输入单位的0.1度累加
+	InputUnit Unit
+	Unit      Unit
+	// Aggregates 非空时text、csv和json只按顺序输出这些统计量，不输出其它附加的列
+	Aggregates []Aggregate
+	// Metadata 非nil时csv和json在附加统计量之后输出每个站点的各列元数据
+	Metadata *Metadata
+	// Thresholds 非空时text和table用ANSI颜色标出满足条件的min、mean和max，只适合输出到终端
+	Thresholds []Threshold
+}
+
+type field struct {
+	name  string
+	value float64
+}
+
+// formatMinMeanMax 返回以opts.Unit的度为单位、保留一位小数的最小值、平均值和最大值。
+// 不需要换算单位时全部使用整数运算，与参考实现的输出完全一致
+func (s *Station) formatMinMeanMax(opts WriteOptions) (lo, mean, hi string) {
+	if opts.converts() {
+		return formatValue(opts.temperature(float64(s.Min) / 10)),
+			formatValue(opts.temperature(s.mean())),
+			formatValue(opts.temperature(float64(s.Max) / 10))
+	}
+	return formatTenths(s.Min), formatTenths(s.roundedMean()), formatTenths(s.Max)
+}
+
+// extraFields 返回中位数、分位数和附加统计量，单位为opts.Unit的度
+func (s *Station) extraFields(opts WriteOptions) []field {
+	fields := make([]field, 0, 1+len(s.Percentiles)+len(opts.Columns))
+	if s.Median != nil {
+		fields = append(fields, field{"median", opts.temperature(*s.Median / 10)})
+	}
+	for _, p := range s.Percentiles {
+		fields = append(fields, field{p.Name(), opts.temperature(p.Value / 10)})
+	}
+	for _, c := range opts.Columns {
+		if c.value != nil {
+			fields = append(fields, field{c.Name, c.value(s, opts)})
+		} else {
+			fields = append(fields, field{c.Name, opts.difference(c.Value(s), c.Degree)})
+		}
+	}
+	return fields
+}
+
+// WriteTo 以挑战要求的`{a=1.0/2.0/3.0, ...}`格式输出结果
+func (r Results) WriteTo(w io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf, WriteOptions{}); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// histogram 返回opts.Buckets个桶的直方图，边界以opts.Unit的度为单位，未记录计数时返回false
+func (s *Station) histogram(opts WriteOptions) (bounds []string, counts []string, ok bool) {
+	if s.Counts == nil || opts.Buckets <= 0 {
+		return nil, nil, false
+	}
+	b, c := s.Counts.Histogram(s.Min, s.Max, opts.Buckets)
+	for _, v := range b {
+		bounds = append(bounds, formatValue(opts.temperature(float64(v)/10)))
+	}
+	for _, n := range c {
+		counts = append(counts, strconv.FormatUint(n, 10))
+	}
+	return bounds, counts, true
+}
+
+// Write 以挑战要求的格式输出结果，中位数、分位数和opts.Columns依次追加在max之后，
+// 如`a=1.0/2.0/3.0/2.0/2.9`
+func (r Results) Write(w io.Writer, opts WriteOptions) error {
+	if len(r) == 0 {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i := range r {
+		s := &r[i]
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if len(opts.Aggregates) > 0 {
+			fmt.Fprintf(buf, "%s=%s", s.Name, strings.Join(s.aggregateValues(opts), "/"))
+			continue
+		}
+		lo, mean, hi := s.formatMinMeanMax(opts)
+		if len(opts.Thresholds) > 0 {
+			lo, mean, hi = s.colorize(MetricMin, lo, opts), s.colorize(MetricMean, mean, opts), s.colorize(MetricMax, hi, opts)
+		}
+		fmt.Fprintf(buf, "%s=%s/%s/%s", s.Name, lo, mean, hi)
+		for _, f := range s.extraFields(opts) {
+			buf.WriteString("/" + formatValue(f.value))
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,214 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testResults() Results {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("b;1.5\na;-2.0\nb;2.5\n"))
+	return s.Results()
+}
+
+func TestWriteJSON(t *testing.T) {
+	for _, tc := range []struct {
+		pretty   bool
+		expected string
+	}{
+		{
+			pretty:   false,
+			expected: `{"a":{"min":-2.0,"mean":-2.0,"max":-2.0,"count":1},"b":{"min":1.5,"mean":2.0,"max":2.5,"count":2}}` + "\n",
+		},
+		{
+			pretty: true,
+			expected: `{
+  "a": {
+    "min": -2.0,
+    "mean": -2.0,
+    "max": -2.0,
+    "count": 1
+  },
+  "b": {
+    "min": 1.5,
+    "mean": 2.0,
+    "max": 2.5,
+    "count": 2
+  }
+}
+`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		if err := testResults().WriteJSON(buf, WriteOptions{Pretty: tc.pretty}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("pretty=%v: expected %s, got %s", tc.pretty, tc.expected, buf.String())
+		}
+	}
+}
+
+func TestWriteColumns(t *testing.T) {
+	buf := &bytes.Buffer{}
+	opts := WriteOptions{Columns: []Column{StddevColumn, VarianceColumn}}
+	if err := testResults().Write(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{a=-2.0/-2.0/-2.0/0.0/0.0, b=1.5/2.0/2.5/0.5/0.3}\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := testResults().WriteJSON(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"a":{"min":-2.0,"mean":-2.0,"max":-2.0,"count":1,"stddev":0.0,"variance":0.0},` +
+		`"b":{"min":1.5,"mean":2.0,"max":2.5,"count":2,"stddev":0.5,"variance":0.3}}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+}
+
+func TestWriteUnit(t *testing.T) {
+	columns := []Column{StddevColumn, VarianceColumn}
+	for _, tc := range []struct {
+		opts     WriteOptions
+		expected string
+	}{
+		{WriteOptions{Unit: UnitFahrenheit}, "{a=28.4/28.4/28.4/0.0/0.0, b=34.7/35.6/36.5/0.9/0.8}\n"},
+		{WriteOptions{InputUnit: UnitFahrenheit, Unit: UnitFahrenheit}, "{a=-2.0/-2.0/-2.0/0.0/0.0, b=1.5/2.0/2.5/0.5/0.3}\n"},
+		{WriteOptions{InputUnit: UnitFahrenheit}, "{a=-18.9/-18.9/-18.9/0.0/0.0, b=-16.9/-16.7/-16.4/0.3/0.1}\n"},
+	} {
+		buf := &bytes.Buffer{}
+		tc.opts.Columns = columns
+		if err := testResults().Write(buf, tc.opts); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("%s to %s: expected %q, got %q", tc.opts.InputUnit, tc.opts.Unit, tc.expected, buf.String())
+		}
+	}
+
+	if _, err := ParseUnit("k"); err == nil {
+		t.Error("expected error for unknown unit")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("Washington, D.C.;1.5\na;-2.0\nWashington, D.C.;2.5\n"))
+	buf := &bytes.Buffer{}
+	if err := s.Results().WriteCSV(buf, WriteOptions{Columns: []Column{StddevColumn}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "station,min,mean,max,count,stddev\n" +
+		"\"Washington, D.C.\",1.5,2.0,2.5,2,0.5\n" +
+		"a,-2.0,-2.0,-2.0,1,0.0\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestTop(t *testing.T) {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("a;1.0\nb;5.0\nc;-3.0\nc;9.0\nd;5.0\n"))
+	results := s.Results()
+	for _, tc := range []struct {
+		by       Metric
+		n        int
+		expected string
+	}{
+		{MetricMean, 2, "b d"},
+		{MetricMax, 1, "c"},
+		{MetricMin, 2, "c a"},
+		{MetricCount, 2, "c a"},
+		{MetricMean, 10, "b d c a"},
+	} {
+		top, err := results.Top(tc.n, tc.by)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, st := range top {
+			names = append(names, st.Name)
+		}
+		if got := strings.Join(names, " "); got != tc.expected {
+			t.Errorf("%s/%d: expected %q, got %q", tc.by, tc.n, tc.expected, got)
+		}
+	}
+	if _, err := results.Top(1, "median"); err == nil {
+		t.Error("expected error for unknown metric")
+	}
+}
+
+func TestSort(t *testing.T) {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("a;1.0\nb;5.0\nc;-3.0\nc;9.0\nd;5.0\n"))
+	results := s.Results()
+	for _, tc := range []struct {
+		by       Metric
+		desc     bool
+		expected string
+	}{
+		{MetricName, false, "a b c d"},
+		{MetricName, true, "d c b a"},
+		{MetricMean, false, "a c b d"},
+		{MetricMean, true, "b d c a"},
+		{MetricMin, false, "c a b d"},
+		{MetricMax, true, "c b d a"},
+		{MetricCount, true, "c a b d"},
+	} {
+		sorted, err := results.Sort(tc.by, tc.desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, st := range sorted {
+			names = append(names, st.Name)
+		}
+		if got := strings.Join(names, " "); got != tc.expected {
+			t.Errorf("%s/%v: expected %q, got %q", tc.by, tc.desc, tc.expected, got)
+		}
+	}
+	if _, err := results.Sort("median", false); err == nil {
+		t.Error("expected error for unknown metric")
+	}
+}
+
+func TestMinCount(t *testing.T) {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("a;1.0\nb;5.0\nb;6.0\nc;-3.0\nc;9.0\nc;1.0\n"))
+	kept, omitted := s.Results().MinCount(2)
+	if len(kept) != 2 || kept[0].Name != "b" || kept[1].Name != "c" {
+		t.Errorf("expected b and c to be kept, got %v", kept)
+	}
+	if len(omitted) != 1 || omitted[0].Name != "a" {
+		t.Errorf("expected a to be omitted, got %v", omitted)
+	}
+	if kept, omitted := s.Results().MinCount(0); len(kept) != 3 || omitted != nil {
+		t.Errorf("expected all stations to be kept, got %v and %v", kept, omitted)
+	}
+}
+
+func TestRoundedMean(t *testing.T) {
+	for _, tc := range []struct {
+		sum, count int64
+		want       string
+	}{
+		{255, 10, "2.6"},
+		{-255, 10, "-2.5"},
+		{-4, 10, "0.0"},
+		{-6, 10, "-0.1"},
+		{1, 3, "0.0"},
+		{2, 3, "0.1"},
+		{-999 * 3_000_000_000, 3_000_000_000, "-99.9"},
+		{999*3_000_000_000 - 1, 3_000_000_000, "99.9"},
+	} {
+		m := M{Sum: tc.sum, Count: int(tc.count)}
+		if got := formatTenths(m.roundedMean()); got != tc.want {
+			t.Errorf("sum %d count %d: expected %s, got %s", tc.sum, tc.count, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package brc
+
+import "time"
+
+// RunStats 记录一次Process的耗时和吞吐量，在Process返回后读取
+type RunStats struct {
+	// Wall 是从开始处理到合并完成的总耗时，Parse和Merge分别是并行解析和合并各worker结果的耗时
+	Wall  time.Duration
+	Parse time.Duration
+	Merge time.Duration
+	// Bytes 是解析的字节数，压缩输入为解压后的大小，Parquet输入为文件大小
+	Bytes int64
+	// Rows 和Stations 是聚合的行数和站点数
+	Rows     int64
+	Stations int
+	// Workers 是每个worker的解析情况
+	Workers []WorkerStats
+}
+
+// WorkerStats 是一个worker解析的批次数、行数和忙碌时间
+type WorkerStats struct {
+	Batches int
+	Rows    int64
+	Busy    time.Duration
+}
+
+// Utilization 返回w的忙碌时间占并行解析耗时的比例
+func (s *RunStats) Utilization(w WorkerStats) float64 {
+	return float64(w.Busy) / float64(max(s.Parse, 1))
+}
+
+// WithRunStats 在Process返回后将运行的耗时、吞吐量和各worker的利用率写入stats，
+// 每个批次需要额外计时一次
+func WithRunStats(stats *RunStats) Option {
+	return func(o *options) {
+		o.runStats = stats
+	}
+}
+
+// collectRunStats 汇总statistics中各worker的解析情况，engine是实际使用的引擎
+func (j *job) collectRunStats(statistics []*Statistic, engine Engine, parse time.Duration) {
+	*j.runStats = RunStats{Parse: parse, Workers: make([]WorkerStats, len(statistics))}
+	for i, s := range statistics {
+		j.runStats.Workers[i] = WorkerStats{Batches: s.batches, Rows: s.rows, Busy: s.busy}
+		j.runStats.Bytes += s.bytes
+	}
+	if engine == EngineParquet {
+		j.runStats.Bytes = j.size
+	}
+}
@@ -0,0 +1,30 @@
+package brc
+
+import "testing"
+
+func TestRunStats(t *testing.T) {
+	data := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	path := writeMeasurements(t, data)
+	for _, engine := range []Engine{EngineScanner, EngineChunk, EngineMmap} {
+		var stats RunStats
+		if _, err := Process(path, WithRunStats(&stats), WithWorkers(2), WithEngine(engine)); err != nil {
+			t.Fatal(err)
+		}
+		if stats.Bytes != int64(len(data)) || stats.Rows != 3 || stats.Stations != 2 {
+			t.Errorf("%s: expected %d bytes, 3 rows and 2 stations, got %+v", engine, len(data), stats)
+		}
+		var rows int64
+		for _, w := range stats.Workers {
+			rows += w.Rows
+			if u := stats.Utilization(w); u < 0 || u > 1 {
+				t.Errorf("%s: utilization %v out of range", engine, u)
+			}
+		}
+		if len(stats.Workers) != 2 || rows != 3 {
+			t.Errorf("%s: expected 2 workers parsing 3 rows, got %+v", engine, stats.Workers)
+		}
+		if stats.Wall < stats.Parse+stats.Merge {
+			t.Errorf("%s: wall %v shorter than parse %v plus merge %v", engine, stats.Wall, stats.Parse, stats.Merge)
+		}
+	}
+}
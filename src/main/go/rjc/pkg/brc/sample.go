@@ -0,0 +1,85 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// 采样的单位，每块以相同的概率被选中，测试时替换
+var sampleChunkSize int64 = 1024 * 1024
+
+// Sample 指定WithSample的采样比例和随机数种子，并在Process返回后记录实际处理的数据量
+type Sample struct {
+	// Fraction 是每块被选中的概率，在(0, 1]之间
+	Fraction float64
+	// Seed 相同时，同一输入选中的块也相同，与worker数量无关
+	Seed int64
+	// Chunks 和Bytes 是选中的块数和字节数
+	Chunks int64
+	Bytes  int64
+	// Total 是可供采样的字节数
+	Total int64
+
+	mu sync.Mutex
+}
+
+// WithSample 只处理输入中随机选中的块，结果是近似值，处理的数据量记录在s中。
+// 块按行对齐，大小固定，因此结果不受worker数量影响。只对EngineChunk和EngineMmap有效
+func WithSample(s *Sample) Option {
+	return func(o *options) {
+		o.sample = s
+	}
+}
+
+// checkSample 检查采样能否与其它选项一起使用，engine是实际使用的引擎
+func (o *options) checkSample(engine Engine) error {
+	switch {
+	case o.sample.Fraction <= 0 || o.sample.Fraction > 1:
+		return fmt.Errorf("invalid sample fraction %v, want (0, 1]", o.sample.Fraction)
+	case engine != EngineChunk && engine != EngineMmap:
+		return fmt.Errorf("%s engine does not support sampling", engine)
+	case o.autoTune:
+		return errors.New("sampling cannot be used with auto-tune")
+	}
+	return nil
+}
+
+// sampler 决定scheduler的每一块是否被选中
+type sampler struct {
+	*Sample
+	rng *rand.Rand
+}
+
+// newSampler 返回第k个scheduler使用的sampler，未开启采样时返回nil
+func (j *job) newSampler(k int) *sampler {
+	if j.sample == nil {
+		return nil
+	}
+	return &sampler{Sample: j.sample, rng: rand.New(rand.NewSource(j.sample.Seed + int64(k)))}
+}
+
+// nextSampled 跳过未被选中的块，返回下一个选中的块[start, end)，没有剩余的块时start == end。调用时持有s.mu
+func (s *scheduler) nextSampled() (start, end int64, err error) {
+	for s.pos < s.size {
+		start = s.pos
+		end, err = nextLineStart(s.r, min(s.size, start+sampleChunkSize), s.size, s.buf[:])
+		if err != nil {
+			return 0, 0, err
+		}
+		s.pos = end
+		selected := s.sample.rng.Float64() < s.sample.Fraction
+		s.sample.mu.Lock()
+		s.sample.Total += end - start
+		if selected {
+			s.sample.Chunks++
+			s.sample.Bytes += end - start
+		}
+		s.sample.mu.Unlock()
+		if selected {
+			return start, end, nil
+		}
+	}
+	return s.pos, s.pos, nil
+}
@@ -0,0 +1,58 @@
+package brc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessSample(t *testing.T) {
+	defer func(size int64) { sampleChunkSize = size }(sampleChunkSize)
+	sampleChunkSize = 1024
+
+	data := strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n", 1000)
+	path := writeMeasurements(t, data)
+	full, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Sample{Fraction: 1}
+	results, err := Process(path, WithSample(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, full) || s.Bytes != int64(len(data)) || s.Total != int64(len(data)) {
+		t.Errorf("fraction 1: expected all %d bytes, got %d of %d", len(data), s.Bytes, s.Total)
+	}
+
+	// 选中的块只取决于种子
+	var expected Results
+	for _, engine := range []Engine{EngineMmap, EngineChunk} {
+		for _, workers := range []int{1, 3} {
+			s := &Sample{Fraction: 0.3, Seed: 42}
+			results, err := Process(path, WithEngine(engine), WithWorkers(workers), WithSample(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Chunks == 0 || s.Bytes >= s.Total || s.Total != int64(len(data)) {
+				t.Errorf("%s/%d: unexpected sample %d chunks, %d of %d bytes", engine, workers, s.Chunks, s.Bytes, s.Total)
+			}
+			if expected == nil {
+				expected = results
+			} else if !reflect.DeepEqual(results, expected) {
+				t.Errorf("%s/%d: expected %v, got %v", engine, workers, expected, results)
+			}
+		}
+	}
+
+	for _, opts := range [][]Option{
+		{WithSample(&Sample{Fraction: 0})},
+		{WithSample(&Sample{Fraction: 1.5})},
+		{WithSample(&Sample{Fraction: 0.5}), WithEngine(EngineScanner)},
+		{WithSample(&Sample{Fraction: 0.5}), WithAutoTune(true)},
+	} {
+		if _, err := Process(path, opts...); err == nil {
+			t.Errorf("expected error for %d options", len(opts))
+		}
+	}
+}
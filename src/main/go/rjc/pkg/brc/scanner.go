@@ -0,0 +1,209 @@
+package brc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime/trace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	scanBufferSize = 64 * 1024 * 1024
+	// 读取下一块数据的同时，worker解析上一块数据
+	scanBuffers = 2
+	// 批次的最小字节数，避免最后的批次太小
+	minBatchSize = 4 * 1024
+)
+
+// WithRaceSafe 让EngineScanner和EngineIOUring在分发前复制每个批次，worker不再引用读取缓冲区，
+// 缓冲区在分发后立即可以被再次写入。用于配合-race排查缓冲区的所有权问题，会增加复制和分配的开销
+func WithRaceSafe(raceSafe bool) Option {
+	return func(o *options) {
+		o.raceSafe = raceSafe
+	}
+}
+
+// batch 是分发给worker的若干行，offset是其在输入中的起始偏移，
+// lease非nil时lines引用读取缓冲区，解析完成后需要调用lease.release()
+type batch struct {
+	lines  []byte
+	offset int64
+	lease  *lease
+}
+
+// lease 是读取缓冲区的所有权：读取方和引用它的每个批次各持有一份，
+// 全部释放之后缓冲区才归还到free，之前不能被再次写入
+type lease struct {
+	buf  []byte
+	refs atomic.Int32
+	free chan<- []byte
+}
+
+// newLease 返回由读取方持有的lease
+func newLease(buf []byte, free chan<- []byte) *lease {
+	l := &lease{buf: buf, free: free}
+	l.refs.Store(1)
+	return l
+}
+
+func (l *lease) acquire() {
+	l.refs.Add(1)
+}
+
+func (l *lease) release() {
+	switch n := l.refs.Add(-1); {
+	case n == 0:
+		l.free <- l.buf
+	case n < 0:
+		panic("brc: scan buffer released twice")
+	}
+}
+
+// blockReader 将r按块顺序读入调用者提供的缓冲区，每块只包含完整的行，
+// 末尾不完整的行先复制到carry，再复制到下一块的开头，除此之外不复制数据
+type blockReader struct {
+	r     io.Reader
+	carry []byte
+	eof   bool
+	// partial 为true时不等待填满缓冲区，读到完整的行即返回
+	partial bool
+}
+
+// next 将下一块读入buf，返回以换行符结尾的部分，最后一块可能不以换行符结尾，之后eof为true。
+// next返回之后blockReader不再引用buf
+func (b *blockReader) next(buf []byte) ([]byte, error) {
+	n := copy(buf, b.carry)
+	var m int
+	var err error
+	if b.partial {
+		m, err = readSome(b.r, buf[n:])
+	} else {
+		m, err = io.ReadFull(b.r, buf[n:])
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		b.eof, b.carry = true, nil
+		return buf[:n+m], nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := buf[:n+m]
+	last := bytes.LastIndexByte(data, '\n')
+	if last < 0 {
+		return nil, errLineTooLong
+	}
+	b.carry = append(b.carry[:0], data[last+1:]...)
+	return data[:last+1], nil
+}
+
+// 单个goroutine顺序读取，按行切分成批次分发给各个worker。
+// 多个缓冲区在读取方和worker之间轮转，读取和解析可以同时进行
+func (j *job) scanStatistics(r io.Reader) ([]*Statistic, error) {
+	num := j.workers
+	statistics := make([]*Statistic, num)
+
+	// 在go tool trace中区分读取、等待缓冲区和解析的耗时，未开启trace时开销可以忽略
+	ctx := context.Background()
+	ch := make(chan batch)
+	// 已分发但尚未解析完成的批次
+	pending := &sync.WaitGroup{}
+	for i := 0; i < num; i++ {
+		statistics[i] = j.newStatistic()
+		go func(idx int) {
+			for b := range ch {
+				trace.WithRegion(ctx, "parse", func() {
+					j.parse(statistics[idx], b.lines, b.offset)
+				})
+				if b.lease != nil {
+					b.lease.release()
+				}
+				pending.Done()
+			}
+		}(i)
+	}
+	defer close(ch)
+
+	buffers := j.memory.scanBuffers
+	free := make(chan []byte, buffers)
+	for i := 0; i < buffers; i++ {
+		free <- make([]byte, j.memory.scanBufferSize)
+	}
+	// 返回前等待所有批次解析完成
+	defer pending.Wait()
+
+	var (
+		br         = &blockReader{r: r, partial: j.streamInFlight > 0}
+		offset     = j.start
+		checkpoint = time.Now()
+	)
+	for !br.eof && !j.failed() && !j.interrupted() {
+		if j.checkpointDue(checkpoint) {
+			// 等待已分发的批次解析完成，此时statistics恰好包含offset之前的数据
+			pending.Wait()
+			var err error
+			if !j.failed() {
+				err = j.saveCheckpoint(statistics, offset)
+			}
+			if err != nil {
+				return nil, err
+			}
+			checkpoint = time.Now()
+		}
+		var buf []byte
+		trace.WithRegion(ctx, "wait buffer", func() {
+			buf = <-free
+		})
+		l := newLease(buf, free)
+		var data []byte
+		var err error
+		trace.WithRegion(ctx, "read", func() {
+			data, err = br.next(buf)
+		})
+		if err != nil {
+			l.release()
+			if j.interrupted() {
+				break
+			}
+			return nil, err
+		}
+
+		trace.WithRegion(ctx, "dispatch", func() {
+			j.dispatch(ch, data, offset, l, pending)
+		})
+		// 之后只有尚未解析完成的批次引用buf
+		l.release()
+		offset += int64(len(data))
+	}
+	return statistics, nil
+}
+
+// dispatch 将data按字节切分成批次发送给worker，每批包含剩余字节数的1/(2*workers)，终点对齐到之后的第一个换行符。
+// 只查找每批终点附近的换行符，不需要事先扫描整块数据。批次越来越小，空闲的worker从channel领取下一批，
+// 避免某个worker成为拖尾。每个批次计入pending并持有l的一份所有权，开启WithRaceSafe时发送的是批次的副本
+func (j *job) dispatch(ch chan<- batch, data []byte, offset int64, l *lease, pending *sync.WaitGroup) {
+	send := func(lines []byte, off int64) {
+		pending.Add(1)
+		if j.raceSafe {
+			ch <- batch{lines: append([]byte(nil), lines...), offset: off}
+			return
+		}
+		l.acquire()
+		ch <- batch{lines, off, l}
+	}
+
+	for start := 0; start < len(data); {
+		end := start + max(minBatchSize, (len(data)-start)/(2*j.workers))
+		if end >= len(data) {
+			end = len(data)
+		} else if nl := bytes.IndexByte(data[end:], '\n'); nl < 0 {
+			end = len(data)
+		} else {
+			end += nl + 1
+		}
+		send(data[start:end], offset+int64(start))
+		start = end
+	}
+}
@@ -0,0 +1,59 @@
+package brc
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// 任务的最小字节数，避免尾部产生过多的小任务
+const minTaskSize = 1024 * 1024
+
+// nextLineStart 返回不小于off的第一个行首位置
+func nextLineStart(r io.ReaderAt, off, size int64, buf []byte) (int64, error) {
+	for off > 0 && off < size {
+		n, err := r.ReadAt(buf, off-1)
+		if n == 0 && err != nil {
+			return 0, err
+		}
+		if pos := bytes.IndexByte(buf[:n], '\n'); pos >= 0 {
+			return off + int64(pos), nil
+		}
+		off += int64(n)
+	}
+	return min(off, size), nil
+}
+
+// scheduler 以guided self-scheduling的方式分配任务：每次分配剩余数据的1/(2*workers)，
+// 任务越来越小，先完成的worker继续领取后面的任务，避免某个worker遇到较慢的数据时成为拖尾
+type scheduler struct {
+	mu      sync.Mutex
+	r       io.ReaderAt
+	pos     int64
+	size    int64
+	workers int
+	buf     [256]byte
+	// sample 非nil时只返回被选中的块
+	sample *sampler
+}
+
+func newScheduler(r io.ReaderAt, size int64, workers int) *scheduler {
+	return &scheduler{r: r, size: size, workers: workers}
+}
+
+// next 返回下一个任务的区间[start, end)，区间总是从行首开始；没有剩余任务时start == end
+func (s *scheduler) next() (start, end int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sample != nil {
+		return s.nextSampled()
+	}
+	start = s.pos
+	task := max(minTaskSize, (s.size-s.pos)/int64(2*s.workers))
+	end, err = nextLineStart(s.r, min(s.size, start+task), s.size, s.buf[:])
+	if err != nil {
+		return 0, 0, err
+	}
+	s.pos = end
+	return start, end, nil
+}
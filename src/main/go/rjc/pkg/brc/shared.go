@@ -0,0 +1,139 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// 共享哈希表的槽位数，负载因子不超过0.5，足够容纳挑战规定的最多10000个站点
+const sharedTableSize = 1 << 16
+
+var errSharedTableFull = errors.New("too many stations for the shared table")
+
+// WithSharedTable 让所有worker直接写入同一个无锁哈希表，而不是各自聚合后再合并。
+// 省去了合并各worker哈希表的过程，但每一行都需要原子操作，站点较少时worker之间竞争同一个缓存行，
+// 用于与默认的合并方式比较。不支持分位数、中位数、直方图、溢出检查、checkpoint和WithAutoTune
+func WithSharedTable(shared bool) Option {
+	return func(o *options) {
+		o.sharedTable = shared
+	}
+}
+
+// checkSharedTable 检查与WithSharedTable不兼容的选项
+func (o *options) checkSharedTable() error {
+	switch {
+	case len(o.percentiles) > 0 || o.exactMedian || o.histogram:
+		return errors.New("shared table does not support percentiles, median or histogram")
+	case o.checkOverflow:
+		return errors.New("shared table does not support overflow checks")
+	case o.checkpoint != "":
+		return errors.New("shared table does not support checkpoints")
+	case o.autoTune:
+		return errors.New("shared table does not support auto-tune")
+	case o.dumpPartials != "":
+		return errors.New("shared table does not support dumping partials")
+	}
+	return nil
+}
+
+// 槽位的状态，只会从empty变为writing，再变为ready
+const (
+	slotEmpty uint32 = iota
+	slotWriting
+	slotReady
+)
+
+// sharedEntry 是共享哈希表的一个槽位，站点名保存在插入它的worker的keyArena中。
+// hash、key和len在state变为slotReady之前写入，之后只读；累计值通过原子操作更新
+type sharedEntry struct {
+	state atomic.Uint32
+	len   uint32
+	hash  uint64
+	key   *byte
+
+	count, sum, sumSq, min, max atomic.Int64
+}
+
+func (e *sharedEntry) add(val int64) {
+	e.count.Add(1)
+	e.sum.Add(val)
+	e.sumSq.Add(val * val)
+	for cur := e.min.Load(); val < cur && !e.min.CompareAndSwap(cur, val); cur = e.min.Load() {
+	}
+	for cur := e.max.Load(); val > cur && !e.max.CompareAndSwap(cur, val); cur = e.max.Load() {
+	}
+}
+
+// sharedTable 是容量固定的开放寻址（线性探测）哈希表，可以被多个worker并发写入。
+// 插入新站点时通过CAS占用空槽位，不需要加锁；站点数超过容量的一半时不再插入，并记录full
+type sharedTable struct {
+	entries []sharedEntry
+	size    atomic.Int64
+	full    atomic.Bool
+}
+
+func newSharedTable(size int) *sharedTable {
+	return &sharedTable{entries: make([]sharedEntry, size)}
+}
+
+// add 将val累加到name对应的站点，新站点的名字复制到keys中，keys只能由调用者所在的worker使用
+func (t *sharedTable) add(name []byte, hash uint64, val int64, keys *keyArena) {
+	mask := uint64(len(t.entries) - 1)
+	for i := hash & mask; ; i = (i + 1) & mask {
+		e := &t.entries[i]
+		state := e.state.Load()
+		if state == slotEmpty {
+			if t.size.Load() >= int64(len(t.entries)/2) {
+				t.full.Store(true)
+				return
+			}
+			if e.state.CompareAndSwap(slotEmpty, slotWriting) {
+				t.size.Add(1)
+				e.hash, e.key, e.len = hash, keys.add(name), uint32(len(name))
+				e.min.Store(math.MaxInt64)
+				e.max.Store(math.MinInt64)
+				e.state.Store(slotReady)
+				e.add(val)
+				return
+			}
+			state = e.state.Load()
+		}
+		// 其它worker正在写入这个槽位的站点名
+		for state == slotWriting {
+			runtime.Gosched()
+			state = e.state.Load()
+		}
+		if e.hash == hash && int(e.len) == len(name) && bytes.Equal(unsafe.Slice(e.key, e.len), name) {
+			e.add(val)
+			return
+		}
+	}
+}
+
+// merged 在所有worker结束后将共享哈希表转换为MergedStatistics，站点名直接引用各worker的keyArena
+func (t *sharedTable) merged() *MergedStatistics {
+	r := &MergedStatistics{
+		index: make(map[string]int32, t.size.Load()),
+	}
+	for i := range t.entries {
+		e := &t.entries[i]
+		if e.state.Load() != slotReady {
+			continue
+		}
+		name := unsafeBytesToString(unsafe.Slice(e.key, e.len))
+		r.index[name] = int32(len(r.measures))
+		r.names = append(r.names, name)
+		r.measures = append(r.measures, M{
+			Count: int(e.count.Load()),
+			Min:   e.min.Load(),
+			Max:   e.max.Load(),
+			Sum:   e.sum.Load(),
+			SumSq: e.sumSq.Load(),
+		})
+	}
+	return r
+}
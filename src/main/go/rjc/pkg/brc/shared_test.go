@@ -0,0 +1,97 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSharedTableConcurrent(t *testing.T) {
+	tbl := newSharedTable(1 << 10)
+	const workers, rows = 4, 10000
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			var keys keyArena
+			for i := 0; i < rows; i++ {
+				name := []byte("station-" + strconv.Itoa(i%100))
+				tbl.add(name, hashName(name), int64(w*rows+i), &keys)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	merged := tbl.merged()
+	if len(merged.names) != 100 {
+		t.Fatalf("expected 100 stations, got %d", len(merged.names))
+	}
+	for i, name := range merged.names {
+		k, _ := strconv.Atoi(name[len("station-"):])
+		m := merged.measures[i]
+		if m.Count != workers*rows/100 || m.Min != int64(k) || m.Max != int64((workers-1)*rows+rows-100+k) {
+			t.Errorf("%s: unexpected %+v", name, m)
+		}
+	}
+}
+
+func TestSharedTableFull(t *testing.T) {
+	tbl := newSharedTable(16)
+	var keys keyArena
+	for i := 0; i < 9; i++ {
+		name := []byte(strconv.Itoa(i))
+		tbl.add(name, hashName(name), 1, &keys)
+	}
+	if !tbl.full.Load() {
+		t.Error("expected table to be full")
+	}
+	if n := len(tbl.merged().names); n != 8 {
+		t.Errorf("expected 8 stations, got %d", n)
+	}
+}
+
+func TestProcessSharedTable(t *testing.T) {
+	var data bytes.Buffer
+	for i := 0; data.Len() < 1<<20; i++ {
+		fmt.Fprintf(&data, "station%d;%d.%d\n", i%413, i%50-25, i%10)
+	}
+	path := writeMeasurements(t, data.String())
+	expected, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		results, err := Process(path, WithEngine(engine), WithWorkers(4), WithSharedTable(true))
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("%s: results differ from the merged tables", engine)
+		}
+	}
+
+	if _, err := Process(path, WithSharedTable(true), WithPercentiles(50)); err == nil {
+		t.Error("expected error for percentiles with a shared table")
+	}
+}
+
+func BenchmarkSharedTable(b *testing.B) {
+	var data bytes.Buffer
+	for i := 0; data.Len() < 16<<20; i++ {
+		fmt.Fprintf(&data, "%s;%d.%d\n", weatherStationNames[i%len(weatherStationNames)], i%50-25, i%10)
+	}
+	for name, shared := range map[string]bool{"merge": false, "shared": true} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(data.Len()))
+			for i := 0; i < b.N; i++ {
+				if _, err := ProcessBytes(data.Bytes(), WithSharedTable(shared)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package brc
+
+import "math/bits"
+
+// masksGeneric 是computeMasks的纯Go实现：data中第i个字节为';'时semi的第i位为1，为'\n'时nl的第i位为1
+func masksGeneric(data []byte, semi, nl []uint64) {
+	for i := range semi[:(len(data)+63)/64] {
+		semi[i], nl[i] = 0, 0
+	}
+	for i, c := range data {
+		switch c {
+		case ';':
+			semi[i>>6] |= 1 << (i & 63)
+		case '\n':
+			nl[i>>6] |= 1 << (i & 63)
+		}
+	}
+}
+
+// nextBit 返回mask中不小于from的第一个为1的位，不存在时返回-1
+func nextBit(mask []uint64, from int) int {
+	w := from >> 6
+	if w >= len(mask) {
+		return -1
+	}
+	m := mask[w] & (^uint64(0) << (from & 63))
+	for m == 0 {
+		w++
+		if w >= len(mask) {
+			return -1
+		}
+		m = mask[w]
+	}
+	return w<<6 + bits.TrailingZeros64(m)
+}
+
+// parseAndAddLinesMasked 与parseAndAddLinesGeneric语义相同，但先用SIMD一次性找出所有';'和'\n'的位置，
+// 再按位图逐行解析，避免每个字段调用一次bytes.IndexByte
+func (s *Statistic) parseAndAddLinesMasked(lines []byte) {
+	words := (len(lines) + 63) / 64
+	if cap(s.semiMask) < words {
+		s.semiMask = make([]uint64, words)
+		s.nlMask = make([]uint64, words)
+	}
+	semi, nl := s.semiMask[:words], s.nlMask[:words]
+	computeMasks(lines, semi, nl)
+
+	pos := 0
+	for {
+		for pos < len(lines) && (lines[pos] == '\r' || lines[pos] == '\n') {
+			pos++
+		}
+		idx := nextBit(semi, pos)
+		if idx < 0 {
+			return
+		}
+		end := nextBit(nl, idx+1)
+		next := end + 1
+		if end < 0 {
+			end, next = len(lines), len(lines)
+		}
+		val := int64(0)
+		neg := idx+1 < end && lines[idx+1] == '-'
+		for _, c := range lines[idx+1 : end] {
+			if c >= '0' && c <= '9' {
+				val = val*10 + int64(c-'0')
+			}
+		}
+		if neg {
+			val = -val
+		}
+		s.Add(lines[pos:idx], val)
+		pos = next
+	}
+}
@@ -0,0 +1,33 @@
+//go:build amd64 && !purego
+
+package brc
+
+import "golang.org/x/sys/cpu"
+
+// amd64总是支持SSE2，AVX2需要运行时检测
+var (
+	useMasks = true
+	hasAVX2  = cpu.X86.HasAVX2
+)
+
+// maskAVX2和maskSSE2处理data的前n个字节，n必须是64的倍数
+//
+//go:noescape
+func maskAVX2(data *byte, n int, semi, nl *uint64)
+
+//go:noescape
+func maskSSE2(data *byte, n int, semi, nl *uint64)
+
+func computeMasks(data []byte, semi, nl []uint64) {
+	n := len(data) &^ 63
+	if n > 0 {
+		if hasAVX2 {
+			maskAVX2(&data[0], n, &semi[0], &nl[0])
+		} else {
+			maskSSE2(&data[0], n, &semi[0], &nl[0])
+		}
+	}
+	if n < len(data) {
+		masksGeneric(data[n:], semi[n/64:], nl[n/64:])
+	}
+}
@@ -0,0 +1,99 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func maskAVX2(data *byte, n int, semi, nl *uint64)
+TEXT ·maskAVX2(SB), NOSPLIT, $0-32
+	MOVQ data+0(FP), SI
+	MOVQ n+8(FP), CX
+	MOVQ semi+16(FP), DI
+	MOVQ nl+24(FP), DX
+
+	MOVQ $0x3b3b3b3b3b3b3b3b, AX
+	MOVQ AX, X0
+	VPBROADCASTB X0, Y0
+	MOVQ $0x0a0a0a0a0a0a0a0a, AX
+	MOVQ AX, X1
+	VPBROADCASTB X1, Y1
+
+	SHRQ $6, CX
+	JZ   avx2done
+
+avx2loop:
+	VMOVDQU (SI), Y2
+	VMOVDQU 32(SI), Y3
+
+	VPCMPEQB  Y0, Y2, Y4
+	VPCMPEQB  Y0, Y3, Y5
+	VPMOVMSKB Y4, AX
+	VPMOVMSKB Y5, BX
+	SHLQ      $32, BX
+	ORQ       BX, AX
+	MOVQ      AX, (DI)
+
+	VPCMPEQB  Y1, Y2, Y4
+	VPCMPEQB  Y1, Y3, Y5
+	VPMOVMSKB Y4, AX
+	VPMOVMSKB Y5, BX
+	SHLQ      $32, BX
+	ORQ       BX, AX
+	MOVQ      AX, (DX)
+
+	ADDQ $64, SI
+	ADDQ $8, DI
+	ADDQ $8, DX
+	DECQ CX
+	JNZ  avx2loop
+
+avx2done:
+	VZEROUPPER
+	RET
+
+// 每16字节得到一个16位的掩码，四个拼成一个64位的字
+#define MASK16(off, needle, shift) \
+	MOVOU    off(SI), X2 \
+	PCMPEQB  needle, X2 \
+	PMOVMSKB X2, BX \
+	SHLQ     $shift, BX \
+	ORQ      BX, AX
+
+// func maskSSE2(data *byte, n int, semi, nl *uint64)
+TEXT ·maskSSE2(SB), NOSPLIT, $0-32
+	MOVQ data+0(FP), SI
+	MOVQ n+8(FP), CX
+	MOVQ semi+16(FP), DI
+	MOVQ nl+24(FP), DX
+
+	MOVQ       $0x3b3b3b3b3b3b3b3b, AX
+	MOVQ       AX, X0
+	PUNPCKLQDQ X0, X0
+	MOVQ       $0x0a0a0a0a0a0a0a0a, AX
+	MOVQ       AX, X1
+	PUNPCKLQDQ X1, X1
+
+	SHRQ $6, CX
+	JZ   sse2done
+
+sse2loop:
+	XORQ AX, AX
+	MASK16(0, X0, 0)
+	MASK16(16, X0, 16)
+	MASK16(32, X0, 32)
+	MASK16(48, X0, 48)
+	MOVQ AX, (DI)
+
+	XORQ AX, AX
+	MASK16(0, X1, 0)
+	MASK16(16, X1, 16)
+	MASK16(32, X1, 32)
+	MASK16(48, X1, 48)
+	MOVQ AX, (DX)
+
+	ADDQ $64, SI
+	ADDQ $8, DI
+	ADDQ $8, DX
+	DECQ CX
+	JNZ  sse2loop
+
+sse2done:
+	RET
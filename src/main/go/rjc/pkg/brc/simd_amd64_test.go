@@ -0,0 +1,28 @@
+//go:build amd64 && !purego
+
+package brc
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMaskSSE2AndAVX2(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	data := randomLines(r, 64*16)
+	expectedSemi, expectedNL := make([]uint64, 16), make([]uint64, 16)
+	masksGeneric(data, expectedSemi, expectedNL)
+
+	impls := map[string]func(data *byte, n int, semi, nl *uint64){"sse2": maskSSE2}
+	if hasAVX2 {
+		impls["avx2"] = maskAVX2
+	}
+	for name, mask := range impls {
+		semi, nl := make([]uint64, 16), make([]uint64, 16)
+		mask(&data[0], len(data), &semi[0], &nl[0])
+		if !reflect.DeepEqual(semi, expectedSemi) || !reflect.DeepEqual(nl, expectedNL) {
+			t.Errorf("%s: masks differ from the generic implementation", name)
+		}
+	}
+}
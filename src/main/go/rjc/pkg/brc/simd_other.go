@@ -0,0 +1,10 @@
+//go:build !amd64 || purego
+
+package brc
+
+// 没有SIMD实现的平台使用基于bytes.IndexByte的解析
+const useMasks = false
+
+func computeMasks(data []byte, semi, nl []uint64) {
+	masksGeneric(data, semi, nl)
+}
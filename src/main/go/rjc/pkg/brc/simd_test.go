@@ -0,0 +1,84 @@
+package brc
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func randomLines(r *rand.Rand, n int) []byte {
+	alphabet := []byte("ab;\n\r-.0123456789")
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = alphabet[r.Intn(len(alphabet))]
+	}
+	return data
+}
+
+func TestComputeMasks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 300; n++ {
+		data := randomLines(r, n)
+		words := (n + 63) / 64
+		expectedSemi, expectedNL := make([]uint64, words), make([]uint64, words)
+		masksGeneric(data, expectedSemi, expectedNL)
+
+		semi, nl := make([]uint64, words), make([]uint64, words)
+		for i := range semi {
+			semi[i], nl[i] = ^uint64(0), ^uint64(0)
+		}
+		computeMasks(data, semi, nl)
+		if !reflect.DeepEqual(semi, expectedSemi) || !reflect.DeepEqual(nl, expectedNL) {
+			t.Fatalf("n=%d: masks differ for %q", n, data)
+		}
+	}
+}
+
+func TestParseAndAddLinesMasked(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	inputs := [][]byte{
+		[]byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8"),
+		[]byte("Foo;1.0\r\nBar;-2.0\r\n\r\nFoo;3.0\n"),
+		[]byte("a;1.0\nb;"),
+		[]byte("no separator\n"),
+	}
+	for i := 0; i < 200; i++ {
+		inputs = append(inputs, randomLines(r, r.Intn(500)))
+	}
+	for _, input := range inputs {
+		expected, actual := NewStatistic(), NewStatistic()
+		expected.parseAndAddLinesGeneric(input)
+		actual.parseAndAddLinesMasked(input)
+		if !reflect.DeepEqual(expected.Results(), actual.Results()) {
+			t.Fatalf("results differ for %q:\n%+v\n%+v", input, expected.Results(), actual.Results())
+		}
+	}
+}
+
+func BenchmarkParseAndAddLines(b *testing.B) {
+	r := rand.New(rand.NewSource(3))
+	buf := make([]byte, 0, 1024*1024)
+	for len(buf) < cap(buf)-128 {
+		station := weatherStationNames[r.Intn(len(weatherStationNames))]
+		buf = append(buf, station...)
+		buf = append(buf, ";-12.3\n"...)
+	}
+
+	for name, parse := range map[string]func(s *Statistic, lines []byte){
+		"generic": (*Statistic).parseAndAddLinesGeneric,
+		"masked":  (*Statistic).parseAndAddLinesMasked,
+	} {
+		b.Run(name, func(b *testing.B) {
+			s := NewStatistic()
+			b.SetBytes(int64(len(buf)))
+			for i := 0; i < b.N; i++ {
+				parse(s, buf)
+			}
+		})
+	}
+}
+
+var weatherStationNames = []string{
+	"Hamburg", "Bulawayo", "Palembang", "St. John's", "Cracow", "Bridgetown", "Istanbul",
+	"Roseau", "Conakry", "Petropavlovsk-Kamchatsky", "Ouahigouya", "Las Palmas de Gran Canaria",
+}
@@ -0,0 +1,113 @@
+package brc
+
+import "math"
+
+// 分位数估计的相对误差
+const sketchAlpha = 0.01
+
+var (
+	sketchGamma    = (1 + sketchAlpha) / (1 - sketchAlpha)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// bucketStore 是连续key区间上的计数，counts[i]对应key为offset+i的桶
+type bucketStore struct {
+	offset int
+	counts []uint64
+}
+
+func (b *bucketStore) add(key int, n uint64) {
+	if len(b.counts) == 0 {
+		b.offset = key
+	}
+	if key < b.offset {
+		counts := make([]uint64, len(b.counts)+b.offset-key)
+		copy(counts[b.offset-key:], b.counts)
+		b.counts, b.offset = counts, key
+	} else if key >= b.offset+len(b.counts) {
+		b.counts = append(b.counts, make([]uint64, key-b.offset-len(b.counts)+1)...)
+	}
+	b.counts[key-b.offset] += n
+}
+
+func (b *bucketStore) merge(o *bucketStore) {
+	for i, n := range o.counts {
+		if n > 0 {
+			b.add(o.offset+i, n)
+		}
+	}
+}
+
+// Sketch 是DDSketch的简化实现，以1%的相对误差估计分位数，可以合并。
+// 正数和负数分别按绝对值的对数分桶，0单独计数
+type Sketch struct {
+	pos   bucketStore
+	neg   bucketStore
+	zeros uint64
+	count uint64
+}
+
+func NewSketch() *Sketch {
+	return &Sketch{}
+}
+
+func sketchKey(v float64) int {
+	return int(math.Ceil(math.Log(v) / sketchLogGamma))
+}
+
+func sketchValue(key int) float64 {
+	return 2 * math.Pow(sketchGamma, float64(key)) / (sketchGamma + 1)
+}
+
+func (s *Sketch) Add(val int64) {
+	s.count++
+	switch {
+	case val > 0:
+		s.pos.add(sketchKey(float64(val)), 1)
+	case val < 0:
+		s.neg.add(sketchKey(float64(-val)), 1)
+	default:
+		s.zeros++
+	}
+}
+
+func (s *Sketch) Merge(o *Sketch) {
+	s.pos.merge(&o.pos)
+	s.neg.merge(&o.neg)
+	s.zeros += o.zeros
+	s.count += o.count
+}
+
+func (s *Sketch) clone() *Sketch {
+	c := *s
+	c.pos.counts = append([]uint64(nil), s.pos.counts...)
+	c.neg.counts = append([]uint64(nil), s.neg.counts...)
+	return &c
+}
+
+// Quantile 返回q(0<=q<=1)分位数的估计值，单位与Add的参数相同
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+	rank := uint64(q * float64(s.count-1))
+	// 按值从小到大：绝对值最大的负数、...、0、...、最大的正数
+	seen := uint64(0)
+	for i := len(s.neg.counts) - 1; i >= 0; i-- {
+		seen += s.neg.counts[i]
+		if seen > rank {
+			return -sketchValue(s.neg.offset + i)
+		}
+	}
+	seen += s.zeros
+	if seen > rank {
+		return 0
+	}
+	for i, n := range s.pos.counts {
+		seen += n
+		if seen > rank {
+			return sketchValue(s.pos.offset + i)
+		}
+	}
+	return sketchValue(s.pos.offset + len(s.pos.counts) - 1)
+}
@@ -0,0 +1,57 @@
+package brc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSketchQuantile(t *testing.T) {
+	a, b := NewSketch(), NewSketch()
+	// -999..999均匀分布，分两半加入再合并
+	for v := int64(-999); v <= 999; v++ {
+		if v%2 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+	}
+	a.Merge(b)
+
+	for _, tc := range []struct {
+		q        float64
+		expected float64
+	}{
+		{0, -999},
+		{0.1, -799.2},
+		{0.5, 0},
+		{0.9, 799.2},
+		{0.99, 979},
+		{1, 999},
+	} {
+		actual := a.Quantile(tc.q)
+		if math.Abs(actual-tc.expected) > math.Abs(tc.expected)*sketchAlpha+1 {
+			t.Errorf("q=%v: expected ~%v, got %v", tc.q, tc.expected, actual)
+		}
+	}
+
+	if !math.IsNaN(NewSketch().Quantile(0.5)) {
+		t.Error("expected NaN for empty sketch")
+	}
+}
+
+func TestProcessPercentiles(t *testing.T) {
+	path := writeMeasurements(t, "a;1.0\na;2.0\na;3.0\na;4.0\na;5.0\nb;-7.5\n")
+	for _, engine := range []Engine{EngineScanner, EngineChunk} {
+		results, err := Process(path, WithEngine(engine), WithWorkers(3), WithPercentiles(50, 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, b := results[0].Percentiles, results[1].Percentiles
+		if len(a) != 2 || math.Abs(a[0].Value-30) > 0.5 || a[1].Value != 50 || a[0].Name() != "p50" {
+			t.Errorf("%s: unexpected percentiles for a: %+v", engine, a)
+		}
+		if len(b) != 2 || b[0].Value != -75 || b[1].Value != -75 {
+			t.Errorf("%s: unexpected percentiles for b: %+v", engine, b)
+		}
+	}
+}
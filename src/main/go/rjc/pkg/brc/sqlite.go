@@ -0,0 +1,108 @@
+package brc
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// RunInfo 是WriteSQLite随结果一起保存的运行信息
+type RunInfo struct {
+	Started  time.Time
+	Finished time.Time
+	// Command 是本次运行的命令行参数
+	Command string
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at  TEXT NOT NULL,
+	finished_at TEXT NOT NULL,
+	command     TEXT NOT NULL,
+	unit        TEXT NOT NULL,
+	stations    INTEGER NOT NULL,
+	rows        INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS results (
+	run_id  INTEGER NOT NULL REFERENCES runs(id),
+	station TEXT NOT NULL,
+	min     REAL NOT NULL,
+	mean    REAL NOT NULL,
+	max     REAL NOT NULL,
+	count   INTEGER NOT NULL,
+	PRIMARY KEY (run_id, station)
+);
+CREATE TABLE IF NOT EXISTS result_fields (
+	run_id  INTEGER NOT NULL REFERENCES runs(id),
+	station TEXT NOT NULL,
+	name    TEXT NOT NULL,
+	value   REAL NOT NULL,
+	PRIMARY KEY (run_id, station, name)
+);`
+
+// WriteSQLite 将结果追加到path指定的SQLite数据库中：每次运行在runs表中新增一行，
+// 每个站点在results表中新增一行，中位数、分位数和opts.Columns写入result_fields表，
+// 多次运行的结果可以用SQL按run_id比较。温度以opts.Unit的度为单位，不做舍入，返回本次运行的id
+func (r Results) WriteSQLite(path string, run RunInfo, opts WriteOptions) (id int64, err error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	unit := opts.Unit
+	if unit == "" {
+		unit = UnitCelsius
+	}
+	var rows int64
+	for i := range r {
+		rows += int64(r[i].Count)
+	}
+	res, err := tx.Exec(`INSERT INTO runs (started_at, finished_at, command, unit, stations, rows) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.Started.Format(time.RFC3339Nano), run.Finished.Format(time.RFC3339Nano), run.Command, string(unit), len(r), rows)
+	if err != nil {
+		return 0, err
+	}
+	if id, err = res.LastInsertId(); err != nil {
+		return 0, err
+	}
+
+	insertResult, err := tx.Prepare(`INSERT INTO results (run_id, station, min, mean, max, count) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insertResult.Close()
+	insertField, err := tx.Prepare(`INSERT INTO result_fields (run_id, station, name, value) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insertField.Close()
+	for i := range r {
+		s := &r[i]
+		_, err = insertResult.Exec(id, s.Name, opts.temperature(float64(s.Min)/10), opts.temperature(s.mean()),
+			opts.temperature(float64(s.Max)/10), s.Count)
+		if err != nil {
+			return 0, err
+		}
+		for _, f := range s.extraFields(opts) {
+			if _, err = insertField.Exec(id, s.Name, f.name, f.value); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return id, tx.Commit()
+}
@@ -0,0 +1,58 @@
+//go:build cgo
+
+package brc
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	run := RunInfo{Started: time.Now(), Finished: time.Now(), Command: "-median measurements.txt"}
+	results := testResults()
+	for i := range results {
+		median := results[i].mean() * 10
+		results[i].Median = &median
+	}
+	for i := 1; i <= 2; i++ {
+		id, err := results.WriteSQLite(path, run, WriteOptions{Columns: []Column{StddevColumn}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != int64(i) {
+			t.Errorf("expected run id %d, got %d", i, id)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var stations, rows int
+	var command, unit string
+	if err := db.QueryRow(`SELECT stations, rows, command, unit FROM runs WHERE id = 2`).Scan(&stations, &rows, &command, &unit); err != nil {
+		t.Fatal(err)
+	}
+	if stations != 2 || rows != 3 || command != run.Command || unit != "c" {
+		t.Errorf("unexpected run %d %d %q %q", stations, rows, command, unit)
+	}
+	var min, mean, max float64
+	var count int
+	if err := db.QueryRow(`SELECT min, mean, max, count FROM results WHERE run_id = 2 AND station = 'b'`).Scan(&min, &mean, &max, &count); err != nil {
+		t.Fatal(err)
+	}
+	if min != 1.5 || mean != 2 || max != 2.5 || count != 2 {
+		t.Errorf("unexpected result %v %v %v %d", min, mean, max, count)
+	}
+	var fields int
+	if err := db.QueryRow(`SELECT count(*) FROM result_fields WHERE run_id = 1 AND name IN ('median', 'stddev')`).Scan(&fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields != 4 {
+		t.Errorf("expected 4 extra fields, got %d", fields)
+	}
+}
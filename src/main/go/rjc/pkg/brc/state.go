@@ -0,0 +1,237 @@
+package brc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 聚合状态的二进制格式：magic、版本号、已处理的输入偏移、输入大小，
+// 然后是每个站点的名字和M，整数都使用varint编码。M之后的一个字节标记是否带有Sketch和Counts
+const (
+	stateMagic   = "BRCS"
+	stateVersion = 1
+
+	stateHasSketch = 1 << 0
+	stateHasCounts = 1 << 1
+)
+
+var errBadState = errors.New("invalid aggregation state")
+
+type stateWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (sw *stateWriter) uvarint(v uint64) {
+	sw.w.Write(sw.buf[:binary.PutUvarint(sw.buf[:], v)])
+}
+
+func (sw *stateWriter) varint(v int64) {
+	sw.w.Write(sw.buf[:binary.PutVarint(sw.buf[:], v)])
+}
+
+func (sw *stateWriter) buckets(b *bucketStore) {
+	sw.varint(int64(b.offset))
+	sw.uvarint(uint64(len(b.counts)))
+	for _, n := range b.counts {
+		sw.uvarint(n)
+	}
+}
+
+// counts 只写入非零的计数，每项是与上一项的下标差和计数
+func (sw *stateWriter) counts(c *Counts) {
+	var n uint64
+	for _, v := range c {
+		if v != 0 {
+			n++
+		}
+	}
+	sw.uvarint(n)
+	prev := -1
+	for i, v := range c {
+		if v != 0 {
+			sw.uvarint(uint64(i - prev))
+			sw.uvarint(v)
+			prev = i
+		}
+	}
+}
+
+// writeState 将s编码写入w，offset是s已经包含的输入字节数，size是输入的总大小，未知时为0
+func writeState(w io.Writer, s *MergedStatistics, offset, size int64) error {
+	sw := &stateWriter{w: bufio.NewWriter(w)}
+	sw.w.WriteString(stateMagic)
+	sw.uvarint(stateVersion)
+	sw.varint(offset)
+	sw.varint(size)
+	sw.uvarint(uint64(len(s.names)))
+	for i, name := range s.names {
+		m := &s.measures[i]
+		sw.uvarint(uint64(len(name)))
+		sw.w.WriteString(name)
+		sw.uvarint(uint64(m.Count))
+		sw.varint(m.Min)
+		sw.varint(m.Max)
+		sw.varint(m.Sum)
+		sw.varint(m.SumSq)
+		var flags byte
+		if m.Sketch != nil {
+			flags |= stateHasSketch
+		}
+		if m.Counts != nil {
+			flags |= stateHasCounts
+		}
+		sw.w.WriteByte(flags)
+		if m.Sketch != nil {
+			sw.uvarint(m.Sketch.zeros)
+			sw.uvarint(m.Sketch.count)
+			sw.buckets(&m.Sketch.pos)
+			sw.buckets(&m.Sketch.neg)
+		}
+		if m.Counts != nil {
+			sw.counts(m.Counts)
+		}
+	}
+	return sw.w.Flush()
+}
+
+type stateReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (sr *stateReader) uvarint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(sr.r)
+	sr.err = err
+	return v
+}
+
+func (sr *stateReader) varint() int64 {
+	if sr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(sr.r)
+	sr.err = err
+	return v
+}
+
+func (sr *stateReader) bytes(n uint64) []byte {
+	if sr.err != nil {
+		return nil
+	}
+	if n > 1<<20 {
+		sr.err = errBadState
+		return nil
+	}
+	b := make([]byte, n)
+	_, sr.err = io.ReadFull(sr.r, b)
+	return b
+}
+
+func (sr *stateReader) buckets(b *bucketStore) {
+	b.offset = int(sr.varint())
+	n := sr.uvarint()
+	if n > 1<<16 {
+		sr.err = errBadState
+		return
+	}
+	b.counts = make([]uint64, n)
+	for i := range b.counts {
+		b.counts[i] = sr.uvarint()
+	}
+}
+
+func (sr *stateReader) counts(c *Counts) {
+	n := sr.uvarint()
+	i := -1
+	for k := uint64(0); k < n && sr.err == nil; k++ {
+		delta := sr.uvarint()
+		if delta == 0 || delta > uint64(len(c)-1-i) {
+			sr.err = errBadState
+			return
+		}
+		i += int(delta)
+		c[i] = sr.uvarint()
+	}
+}
+
+// readState 解码writeState写入的聚合状态
+func readState(r io.Reader) (s *MergedStatistics, offset, size int64, err error) {
+	sr := &stateReader{r: bufio.NewReader(r)}
+	if magic := sr.bytes(uint64(len(stateMagic))); sr.err == nil && string(magic) != stateMagic {
+		return nil, 0, 0, errBadState
+	}
+	if version := sr.uvarint(); sr.err == nil && version != stateVersion {
+		return nil, 0, 0, fmt.Errorf("unsupported aggregation state version %d", version)
+	}
+	offset = sr.varint()
+	size = sr.varint()
+	n := sr.uvarint()
+	s = &MergedStatistics{index: make(map[string]int32)}
+	for i := uint64(0); i < n && sr.err == nil; i++ {
+		name := string(sr.bytes(sr.uvarint()))
+		m := M{
+			Count: int(sr.uvarint()),
+			Min:   sr.varint(),
+			Max:   sr.varint(),
+			Sum:   sr.varint(),
+			SumSq: sr.varint(),
+		}
+		var flags byte
+		if sr.err == nil {
+			flags, sr.err = sr.r.ReadByte()
+		}
+		if flags&stateHasSketch != 0 {
+			m.Sketch = NewSketch()
+			m.Sketch.zeros = sr.uvarint()
+			m.Sketch.count = sr.uvarint()
+			sr.buckets(&m.Sketch.pos)
+			sr.buckets(&m.Sketch.neg)
+		}
+		if flags&stateHasCounts != 0 {
+			m.Counts = &Counts{}
+			sr.counts(m.Counts)
+		}
+		s.add(name, &m)
+	}
+	if sr.err == io.EOF || sr.err == io.ErrUnexpectedEOF {
+		return nil, 0, 0, errBadState
+	}
+	if sr.err != nil {
+		return nil, 0, 0, sr.err
+	}
+	return s, offset, size, nil
+}
+
+// ProcessState 与Process相同，但返回合并后的聚合状态，可以保存到文件或与其它状态合并，
+// 用于在多台机器上或分多次聚合
+func ProcessState(path string, opts ...Option) (*MergedStatistics, error) {
+	file, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return aggregate(file, newOptions(opts))
+}
+
+// ProcessReaderState 与ProcessReader相同，但返回合并后的聚合状态
+func ProcessReaderState(r io.Reader, opts ...Option) (*MergedStatistics, error) {
+	return aggregate(r, newOptions(opts))
+}
+
+// WriteState 以紧凑的带版本号的二进制格式写入s，Sketch和Counts也会被保存
+func (s *MergedStatistics) WriteState(w io.Writer) error {
+	return writeState(w, s, 0, 0)
+}
+
+// ReadState 读取WriteState写入的聚合状态，也可以读取checkpoint文件
+func ReadState(r io.Reader) (*MergedStatistics, error) {
+	s, _, _, err := readState(r)
+	return s, err
+}
@@ -0,0 +1,269 @@
+package brc
+
+import (
+	"bytes"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+func unsafeBytesToString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// Statistic 是单个worker的聚合状态，不能被并发使用
+type Statistic struct {
+	table    *table
+	rows     int64
+	sketches bool
+	counts   bool
+	checked  bool
+	// skipMinMax 为true时不更新每个站点的Min和Max
+	skipMinMax bool
+	// overflowed 是开启溢出检查时第一个累计值溢出的站点
+	overflowed string
+	// filter 非nil时只聚合返回true的站点
+	filter func(name []byte) bool
+	// group 非nil时按返回的分组名聚合
+	group func(name []byte) []byte
+	// window 大于0时按站点名和windowStart所在的时间窗口聚合，windowStart由ParseAndAddLinesWindowed设置
+	window      time.Duration
+	windowStart time.Time
+	windowBuf   []byte
+	// hash 非nil时代替hashName计算站点名的哈希值
+	hash func(name []byte) uint64
+	// shared 非nil时直接写入共享哈希表，table只用于保存新站点的名字
+	shared *sharedTable
+
+	// spec 为true时严格模式还校验挑战规范的限制
+	spec bool
+	// 解析WithQuoted格式时复用的站点名缓冲区
+	unquoted []byte
+	// normalize 为true时站点名先规范化为NFC，validBuf、normBuf和normIter在各行之间复用
+	normalize         bool
+	validBuf, normBuf []byte
+	normIter          *norm.Iter
+	// foldCase 不为FoldNone时折叠站点名的大小写，foldBuf和folder在各行之间复用
+	foldCase CaseFolding
+	foldBuf  []byte
+	folder   *cases.Caser
+
+	// 开启WithLogger、WithRunStats或WithDumpPartials时累计的解析批次数、字节数和耗时
+	batches int
+	bytes   int64
+	busy    time.Duration
+	// 开启WithDumpPartials时记录的各批次的位置
+	spans []batchSpan
+	// live 在开启WithProgress时于解析每个批次期间持有，Progress.Snapshot持有它读取这个Statistic
+	live sync.Mutex
+
+	// SIMD解析时复用的位图
+	semiMask []uint64
+	nlMask   []uint64
+}
+
+func NewStatistic() *Statistic {
+	return &Statistic{
+		table: newTable(initialTableSize),
+	}
+}
+
+// EnableSketches 为每个站点维护一个Sketch，用于估计分位数，需要在Add之前调用
+func (s *Statistic) EnableSketches() {
+	s.sketches = true
+}
+
+// EnableCounts 为每个站点记录每个取值出现的次数，用于计算精确的中位数，需要在Add之前调用
+func (s *Statistic) EnableCounts() {
+	s.counts = true
+}
+
+// EnableOverflowCheck 检查每个站点的Sum和SumSq是否溢出，溢出的值不会被累加，需要在Add之前调用
+func (s *Statistic) EnableOverflowCheck() {
+	s.checked = true
+}
+
+func (s *Statistic) Add(nameBytes []byte, val int64) {
+	if s.normalize {
+		nameBytes = s.normalizeName(nameBytes)
+	}
+	if s.foldCase != FoldNone {
+		nameBytes = s.foldName(nameBytes)
+	}
+	if s.filter != nil && !s.filter(nameBytes) {
+		return
+	}
+	if s.group != nil {
+		nameBytes = s.group(nameBytes)
+	}
+	if s.window > 0 {
+		nameBytes = s.windowName(nameBytes)
+	}
+	var h uint64
+	if s.hash != nil {
+		h = s.hash(nameBytes)
+	} else {
+		h = hashName(nameBytes)
+	}
+	if s.shared != nil {
+		s.shared.add(nameBytes, h, val, &s.table.keys)
+		s.rows++
+		return
+	}
+	m := s.table.get(nameBytes, h)
+	if s.sketches && m.Sketch == nil {
+		m.Sketch = NewSketch()
+	}
+	if s.counts && m.Counts == nil {
+		m.Counts = &Counts{}
+	}
+	if s.checked {
+		if !m.addChecked(val) && s.overflowed == "" {
+			s.overflowed = string(nameBytes)
+		}
+	} else if s.skipMinMax {
+		m.addSum(val)
+	} else {
+		m.Add(val)
+	}
+	s.rows++
+}
+
+// reset 清空所有站点，保留已分配的内存以便复用
+func (s *Statistic) reset() {
+	s.table.reset()
+	s.rows = 0
+	s.overflowed = ""
+}
+
+// ParseAndAddLines 解析`name;value`格式的多行数据，同时支持LF和CRLF换行：
+// value之后的'\r'作为非数字字符被跳过，空行留下的'\r'、'\n'不会成为下一个站点名的前缀。
+// CPU支持时使用SIMD实现
+func (s *Statistic) ParseAndAddLines(lines []byte) {
+	if useMasks {
+		s.parseAndAddLinesMasked(lines)
+	} else {
+		s.parseAndAddLinesGeneric(lines)
+	}
+}
+
+func (s *Statistic) parseAndAddLinesGeneric(lines []byte) {
+	for {
+		for len(lines) > 0 && (lines[0] == '\r' || lines[0] == '\n') {
+			lines = lines[1:]
+		}
+		idx := bytes.IndexByte(lines, ';')
+		if idx < 0 {
+			return
+		}
+		val := int64(0)
+		neg := idx+1 < len(lines) && lines[idx+1] == '-'
+		i := idx + 1
+		for i < len(lines) {
+			if lines[i] == '\n' {
+				i++
+				break
+			}
+			// '\r'等非数字字符直接跳过
+			if lines[i] >= '0' && lines[i] <= '9' {
+				val = val*10 + int64(lines[i]-'0')
+			}
+			i++
+		}
+		if neg {
+			val = -val
+		}
+		s.Add(lines[:idx], val)
+		lines = lines[i:]
+	}
+}
+
+// Results 返回按站点名排序的聚合结果
+func (s *Statistic) Results() Results {
+	return mergeStatistics(s).Results()
+}
+
+// MergedStatistics 是多个Statistic合并后的结果，M连续存放在measures中，
+// index只保存站点名到下标的映射，避免为每个站点单独分配一个M
+type MergedStatistics struct {
+	names    []string
+	measures []M
+	index    map[string]int32
+	// rows 是合并的总行数
+	rows int64
+	// overflowed 是第一个累计值溢出的站点，包括各Statistic中开启溢出检查时发现的溢出
+	overflowed string
+}
+
+func mergeStatistics(slice ...*Statistic) *MergedStatistics {
+	r := &MergedStatistics{
+		index: make(map[string]int32),
+	}
+
+	for _, s := range slice {
+		// 站点名直接引用Statistic的keyArena，之后Statistic继续插入或被reset都不会修改这些字节
+		s.table.keys.shared = true
+		if r.overflowed == "" {
+			r.overflowed = s.overflowed
+		}
+		r.rows += s.rows
+		s.table.each(func(nameBytes []byte, m *M) {
+			r.add(unsafeBytesToString(nameBytes), m)
+		})
+	}
+
+	return r
+}
+
+// add 将name的累计值m合并进来，m本身不会被修改或引用
+func (s *MergedStatistics) add(name string, m *M) {
+	i, ok := s.index[name]
+	if ok {
+		if !s.measures[i].mergeChecked(m) && s.overflowed == "" {
+			s.overflowed = name
+		}
+		return
+	}
+	m2 := *m
+	if m.Sketch != nil {
+		m2.Sketch = m.Sketch.clone()
+	}
+	if m.Counts != nil {
+		counts := *m.Counts
+		m2.Counts = &counts
+	}
+	s.index[name] = int32(len(s.measures))
+	s.names = append(s.names, name)
+	s.measures = append(s.measures, m2)
+}
+
+// Merge 将o的全部站点合并进来，o本身不会被修改
+func (s *MergedStatistics) Merge(o *MergedStatistics) {
+	if s.overflowed == "" {
+		s.overflowed = o.overflowed
+	}
+	s.rows += o.rows
+	for i, name := range o.names {
+		s.add(name, &o.measures[i])
+	}
+}
+
+// Results 返回按站点名排序的聚合结果，opts中的WithPercentiles和WithExactMedian
+// 用于计算分位数和中位数，需要聚合时开启了相应的选项，其它选项被忽略
+func (s *MergedStatistics) Results(opts ...Option) Results {
+	return s.results(newOptions(opts))
+}
+
+func (s *MergedStatistics) results(o options) Results {
+	results := newResults(s.names, s.measures)
+	if len(o.percentiles) > 0 {
+		results.computePercentiles(o.percentiles)
+	}
+	if o.exactMedian {
+		results.computeMedians()
+	}
+	return results
+}
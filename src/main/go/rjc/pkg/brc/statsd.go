@@ -0,0 +1,67 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// WriteStatsd 以statsd gauge的形式输出每个站点的min、mean、max和count，以及站点数和总行数，
+// 指标名为prefix.<站点>.<统计量>，站点名中字母、数字、'_'和'-'之外的字符替换为'_'。
+// 多个指标用换行拼接成不超过packetSize字节的包，每个包调用一次w.Write，适合直接写入UDP连接
+func (r Results) WriteStatsd(w io.Writer, prefix string, packetSize int, opts WriteOptions) error {
+	buf := &bytes.Buffer{}
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		_, err := w.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+	gauge := func(name, value string) error {
+		line := prefix + "." + name + ":" + value + "|g"
+		if buf.Len() > 0 && buf.Len()+1+len(line) > packetSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+		return nil
+	}
+
+	var rows int
+	for i := range r {
+		s := &r[i]
+		name := statsdName(s.Name)
+		lo, mean, hi := s.formatMinMeanMax(opts)
+		for _, g := range [...][2]string{{"min", lo}, {"mean", mean}, {"max", hi}, {"count", fmt.Sprint(s.Count)}} {
+			if err := gauge(name+"."+g[0], g[1]); err != nil {
+				return err
+			}
+		}
+		rows += s.Count
+	}
+	if err := gauge("stations", fmt.Sprint(len(r))); err != nil {
+		return err
+	}
+	if err := gauge("rows", fmt.Sprint(rows)); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// statsdName 将站点名中不能出现在statsd指标名中的字符替换为'_'
+func statsdName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,47 @@
+package brc
+
+import (
+	"strings"
+	"testing"
+)
+
+// packets 记录每次Write的内容
+type packets []string
+
+func (p *packets) Write(b []byte) (int, error) {
+	*p = append(*p, string(b))
+	return len(b), nil
+}
+
+func TestWriteStatsd(t *testing.T) {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("b;1.5\nSão Paulo;-12.0\nb;2.5\n"))
+	var p packets
+	if err := s.Results().WriteStatsd(&p, "brc", 1432, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"brc.São_Paulo.min:-12.0|g\nbrc.São_Paulo.mean:-12.0|g\nbrc.São_Paulo.max:-12.0|g\nbrc.São_Paulo.count:1|g\n" +
+		"brc.b.min:1.5|g\nbrc.b.mean:2.0|g\nbrc.b.max:2.5|g\nbrc.b.count:2|g\n" +
+		"brc.stations:2|g\nbrc.rows:3|g"
+	if len(p) != 1 || p[0] != expected {
+		t.Errorf("expected one packet\n%s\ngot %q", expected, p)
+	}
+
+	// 包过小时每个包至少包含一个指标
+	p = nil
+	if err := s.Results().WriteStatsd(&p, "x", 40, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(p, "\n") != strings.ReplaceAll(expected, "brc.", "x.") {
+		t.Errorf("packets do not add up to the metrics: %q", p)
+	}
+	for _, packet := range p {
+		if len(packet) > 40 && strings.Contains(packet, "\n") {
+			t.Errorf("packet of %d bytes exceeds the limit: %q", len(packet), packet)
+		}
+	}
+	if len(p) < 2 {
+		t.Errorf("expected several packets, got %q", p)
+	}
+}
@@ -0,0 +1,44 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WithStreaming 让EngineScanner以至多inFlight个size字节的块读取管道、socket等流。
+// 每块读到一个换行符即分发，不等待填满；所有块都在等待解析时读取方阻塞，不再从输入读取，
+// 生产者随之被阻塞，内存占用固定为inFlight*size，与生产者的速度无关。inFlight为0时不开启
+func WithStreaming(inFlight, size int) Option {
+	return func(o *options) {
+		o.streamInFlight, o.streamChunkSize = inFlight, size
+	}
+}
+
+// applyStreaming 用WithStreaming的块数和块大小替换EngineScanner的读取缓冲区
+func (j *job) applyStreaming() error {
+	if j.streamInFlight == 0 {
+		return nil
+	}
+	if j.streamInFlight < 0 || j.streamChunkSize <= 0 {
+		return fmt.Errorf("invalid streaming with %d chunks of %d bytes", j.streamInFlight, j.streamChunkSize)
+	}
+	j.memory.scanBuffers, j.memory.scanBufferSize = j.streamInFlight, j.streamChunkSize
+	return nil
+}
+
+// readSome 从r读入buf，读到的数据包含换行符、buf已满或出错时返回
+func readSome(r io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if bytes.IndexByte(buf[n-m:n], '\n') >= 0 {
+			break
+		}
+	}
+	return n, nil
+}
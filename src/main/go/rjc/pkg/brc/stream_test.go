@@ -0,0 +1,58 @@
+package brc
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessReaderStreaming(t *testing.T) {
+	data := strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n", 1000)
+	expected, err := ProcessBytes([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]Option{
+		{WithStreaming(1, 64)},
+		{WithStreaming(4, 1024), WithWorkers(3)},
+		{WithStreaming(2, 1<<20), WithRaceSafe(true)},
+	} {
+		results, err := ProcessReader(io.MultiReader(strings.NewReader(data[:1000]), strings.NewReader(data[1000:])), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("expected %v, got %v", expected, results)
+		}
+	}
+
+	// 不等待填满缓冲区，生产者暂停时已经写入的行也会被解析
+	r, w := io.Pipe()
+	p := &Progress{}
+	done := make(chan error)
+	go func() {
+		_, err := ProcessReader(r, WithStreaming(2, 1<<20), WithProgress(p))
+		done <- err
+	}()
+	io.WriteString(w, "Hamburg;12.0\nBulawayo;8.9\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Rows() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if p.Rows() != 2 {
+		t.Errorf("expected 2 rows before the producer finished, got %d", p.Rows())
+	}
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ProcessReader(strings.NewReader(data), WithStreaming(2, 0)); err == nil {
+		t.Error("expected error for a zero chunk size")
+	}
+	if _, err := ProcessReader(strings.NewReader(data), WithStreaming(1, 8)); err == nil {
+		t.Error("expected error for chunks shorter than a line")
+	}
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package brc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestProcessFIFO(t *testing.T) {
+	data := strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n", 1000)
+	expected, err := ProcessBytes([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "measurements.fifo")
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		t.Skip(err)
+	}
+	// 要求按偏移读取的引擎也改为流式读取
+	for _, engine := range []Engine{"", EngineChunk, EngineMmap} {
+		go func() {
+			os.WriteFile(path, []byte(data), 0o600)
+		}()
+		results, err := Process(path, WithEngine(engine), WithStreaming(2, 4096))
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("%s: expected %v, got %v", engine, expected, results)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ParseError 表示严格模式下遇到的不合法的行
+type ParseError struct {
+	// Offset 是该行行首在输入中的字节偏移
+	Offset int64
+	Line   string
+	// Reason 是格式正确但违反挑战规范的原因，格式错误时为空
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("invalid line at offset %d: %q: %s", e.Offset, e.Line, e.Reason)
+	}
+	return fmt.Sprintf("invalid line at offset %d: %q", e.Offset, e.Line)
+}
+
+const (
+	// 挑战规范中站点名的最大字节数
+	maxNameBytes = 100
+	// 挑战规范中温度的取值范围，以0.1度为单位
+	minSpecValue = -999
+	maxSpecValue = 999
+)
+
+// WithStrictSpec 在严格模式的基础上校验挑战规范的限制：站点名是不超过100字节的合法UTF-8，
+// 温度在[-99.9, 99.9]之间。遇到第一个违反规范的行时Process返回*ParseError，用于提交前检查生成的数据
+func WithStrictSpec(spec bool) Option {
+	return func(o *options) {
+		o.strictSpec = spec
+	}
+}
+
+// specViolation 返回name和val违反挑战规范的原因，没有违反时返回空字符串
+func specViolation(name []byte, val int64) string {
+	switch {
+	case len(name) > maxNameBytes:
+		return fmt.Sprintf("station name is %d bytes, longer than %d", len(name), maxNameBytes)
+	case !utf8.Valid(name):
+		return "station name is not valid UTF-8"
+	case val < minSpecValue || val > maxSpecValue:
+		return fmt.Sprintf("value %s is outside [-99.9, 99.9]", formatTenths(val))
+	}
+	return ""
+}
+
+// parseLine 按`name;-?\d?\d\.\d`格式解析一行，line不包含换行符。
+// 整数没有负零，"-0.0"与"0.0"一样解析为0，输出时不会出现"-0.0"
+func parseLine(line []byte) (name []byte, val int64, ok bool) {
+	idx := bytes.IndexByte(line, ';')
+	if idx <= 0 {
+		return nil, 0, false
+	}
+	val, ok = parseTenths(line[idx+1:])
+	if !ok {
+		return nil, 0, false
+	}
+	return line[:idx], val, true
+}
+
+// parseTenths 按`-?\d?\d\.\d`格式解析温度，返回以0.1度为单位的整数
+func parseTenths(num []byte) (val int64, ok bool) {
+	neg := len(num) > 0 && num[0] == '-'
+	if neg {
+		num = num[1:]
+	}
+	if len(num) < 3 || len(num) > 4 || num[len(num)-2] != '.' {
+		return 0, false
+	}
+	for i, c := range num {
+		if i == len(num)-2 {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		val = val*10 + int64(c-'0')
+	}
+	if neg {
+		val = -val
+	}
+	return val, true
+}
+
+// ParseAndAddLinesStrict 与ParseAndAddLines相同，但会校验每一行的格式，
+// 遇到第一个不合法的行时停止并返回*ParseError，offset是lines在输入中的起始偏移。
+// 行尾的"\r\n"与"\n"等价，开启WithStrictSpec时还校验挑战规范的限制
+func (s *Statistic) ParseAndAddLinesStrict(lines []byte, offset int64) error {
+	for len(lines) > 0 {
+		line, next := lines, len(lines)
+		if end := bytes.IndexByte(lines, '\n'); end >= 0 {
+			line, next = lines[:end], end+1
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		name, val, ok := parseLine(line)
+		if !ok || !s.validName(name) {
+			return &ParseError{Offset: offset, Line: string(line)}
+		}
+		if s.spec {
+			if reason := specViolation(name, val); reason != "" {
+				return &ParseError{Offset: offset, Line: string(line), Reason: reason}
+			}
+		}
+		s.Add(name, val)
+		lines = lines[next:]
+		offset += int64(next)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package brc
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		name string
+		val  int64
+		ok   bool
+	}{
+		{line: "Hamburg;12.0", name: "Hamburg", val: 120, ok: true},
+		{line: "Bulawayo;-8.9", name: "Bulawayo", val: -89, ok: true},
+		{line: "a;-99.9", name: "a", val: -999, ok: true},
+		{line: "a;0.0", name: "a", val: 0, ok: true},
+		{line: "a;-0.0", name: "a", val: 0, ok: true},
+		{line: "a;99.9", name: "a", val: 999, ok: true},
+		{line: "a;5.0", name: "a", val: 50, ok: true},
+		{line: "a;-5.0", name: "a", val: -50, ok: true},
+		{line: "a;1.23"},
+		{line: "a;123.4"},
+		{line: "a;12"},
+		{line: "a;1x.2"},
+		{line: "a;--1.2"},
+		{line: "a;b;1.2"},
+		{line: ";1.2"},
+		{line: "a"},
+		{line: ""},
+	} {
+		name, val, ok := parseLine([]byte(tc.line))
+		if ok != tc.ok || string(name) != tc.name || val != tc.val {
+			t.Errorf("%q: expected (%q, %d, %v), got (%q, %d, %v)", tc.line, tc.name, tc.val, tc.ok, name, val, ok)
+		}
+	}
+}
+
+func TestProcessStrict(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;1x.4\nPalembang;38.8\nfoo\n")
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		for _, workers := range []int{1, 4} {
+			_, err := Process(path, WithEngine(engine), WithWorkers(workers), WithStrict(true))
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("%s/%d: expected ParseError, got %v", engine, workers, err)
+			}
+			if perr.Offset != 26 || perr.Line != "Hamburg;1x.4" {
+				t.Errorf("%s/%d: unexpected error %v", engine, workers, perr)
+			}
+		}
+	}
+
+	valid := writeMeasurements(t, "Hamburg;12.0\nBulawayo;8.9")
+	if _, err := Process(valid, WithStrict(true)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestProcessCRLF(t *testing.T) {
+	lf := writeMeasurements(t, "Foo;1.0\nBar;-2.0\nFoo;3.0\n\nBar;4.0\n")
+	crlf := writeMeasurements(t, "Foo;1.0\r\nBar;-2.0\r\nFoo;3.0\n\r\nBar;4.0\r\n")
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		expected, err := Process(lf, WithEngine(engine))
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := Process(crlf, WithEngine(engine))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, actual) || len(actual) != 2 {
+			t.Errorf("%s: expected %+v, got %+v", engine, expected, actual)
+		}
+	}
+
+	path := writeMeasurements(t, "Foo;1.0\r\nBar;-2.0\r\nFoo;3.0\r")
+	results, err := Process(path, WithStrict(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Name != "Foo" || results[1].Sum != 40 {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestSpecViolation(t *testing.T) {
+	long := []byte(strings.Repeat("a", maxNameBytes+1))
+	for _, tc := range []struct {
+		name  []byte
+		val   int64
+		valid bool
+	}{
+		{[]byte("Hamburg"), 120, true},
+		{long[:maxNameBytes], -999, true},
+		{long, 0, false},
+		{[]byte("Bad\xff"), 0, false},
+		{[]byte("a"), 1000, false},
+		{[]byte("a"), -1000, false},
+	} {
+		if reason := specViolation(tc.name, tc.val); (reason == "") != tc.valid {
+			t.Errorf("%q %d: expected valid %v, got %q", tc.name, tc.val, tc.valid, reason)
+		}
+	}
+}
+
+func TestProcessStrictSpec(t *testing.T) {
+	data := "Hamburg;12.0\n" + strings.Repeat("x", maxNameBytes+1) + ";1.0\nBulawayo;8.9\n"
+	path := writeMeasurements(t, data)
+
+	if _, err := Process(path, WithStrict(true)); err != nil {
+		t.Fatalf("expected long name to be accepted without -strict-spec, got %v", err)
+	}
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		_, err := Process(path, WithEngine(engine), WithStrictSpec(true))
+		var perr *ParseError
+		if !errors.As(err, &perr) || perr.Offset != int64(len("Hamburg;12.0\n")) || perr.Reason == "" {
+			t.Errorf("%s: expected spec violation at the second line, got %v", engine, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package brc
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+const (
+	// 2的幂，可以用位运算取模，且足够容纳挑战规定的最多10000个站点
+	initialTableSize = 1 << 14
+
+	// keyArena每个块的大小
+	keyBlockSize = 64 * 1024
+
+	fnv1aOffset64 = 14695981039346656037
+	fnv1aPrime64  = 1099511628211
+)
+
+func hashName(name []byte) uint64 {
+	h := uint64(fnv1aOffset64)
+	for _, b := range name {
+		h ^= uint64(b)
+		h *= fnv1aPrime64
+	}
+	return h
+}
+
+// keyArena 按固定大小的块保存站点名，块写满后分配新的块而不是扩容，
+// 已经写入的字节不会移动，直接引用它们的字符串在之后插入新站点时仍然有效
+type keyArena struct {
+	blocks [][]byte
+	// shared 表示已有字符串直接引用arena中的内存，此时reset不能复用已分配的块
+	shared bool
+}
+
+// add 将name复制到arena中，返回副本的起始地址
+func (a *keyArena) add(name []byte) *byte {
+	if len(name) == 0 {
+		return nil
+	}
+	n := len(a.blocks)
+	if n == 0 || cap(a.blocks[n-1])-len(a.blocks[n-1]) < len(name) {
+		a.blocks = append(a.blocks, make([]byte, 0, max(keyBlockSize, len(name))))
+		n++
+	}
+	off := len(a.blocks[n-1])
+	a.blocks[n-1] = append(a.blocks[n-1], name...)
+	return &a.blocks[n-1][off]
+}
+
+// reset 清空arena，没有被共享时复用第一个块
+func (a *keyArena) reset() {
+	if a.shared || len(a.blocks) == 0 {
+		*a = keyArena{}
+		return
+	}
+	a.blocks = append(a.blocks[:0], a.blocks[0][:0])
+}
+
+// 站点名保存在table.keys中，entry只记录地址和长度，M直接内联
+type entry struct {
+	hash uint64
+	key  *byte
+	len  uint32
+	used bool
+	m    M
+}
+
+// table 是以站点名原始字节为key的开放寻址（线性探测）哈希表
+type table struct {
+	keys    keyArena
+	entries []entry
+	size    int
+
+	// probes 是查找时越过的被其它站点占用的槽位数，collisions 是其中哈希值相同的次数
+	probes     int64
+	collisions int64
+}
+
+// newTable 返回预分配size个entry的哈希表，size必须是2的幂
+func newTable(size int) *table {
+	return &table{
+		entries: make([]entry, size),
+	}
+}
+
+func (t *table) name(e *entry) []byte {
+	return unsafe.Slice(e.key, e.len)
+}
+
+// get 返回name对应的M，不存在时插入一个新的M
+func (t *table) get(name []byte, hash uint64) *M {
+	mask := uint64(len(t.entries) - 1)
+	i := hash & mask
+	for {
+		e := &t.entries[i]
+		if !e.used {
+			break
+		}
+		if e.hash == hash {
+			if int(e.len) == len(name) && bytes.Equal(t.name(e), name) {
+				return &e.m
+			}
+			t.collisions++
+		}
+		t.probes++
+		i = (i + 1) & mask
+	}
+
+	if (t.size+1)*2 > len(t.entries) {
+		t.grow()
+		return t.get(name, hash)
+	}
+	e := &t.entries[i]
+	e.hash = hash
+	e.key = t.keys.add(name)
+	e.len = uint32(len(name))
+	e.used = true
+	e.m = newM()
+	t.size++
+	return &e.m
+}
+
+func (t *table) reset() {
+	clear(t.entries)
+	t.keys.reset()
+	t.size = 0
+}
+
+// 负载因子超过0.5时扩容一倍
+func (t *table) grow() {
+	entries := t.entries
+	t.entries = make([]entry, len(entries)*2)
+	mask := uint64(len(t.entries) - 1)
+	for _, e := range entries {
+		if !e.used {
+			continue
+		}
+		i := e.hash & mask
+		for t.entries[i].used {
+			i = (i + 1) & mask
+		}
+		t.entries[i] = e
+	}
+}
+
+// each 按插入槽位顺序遍历所有站点
+func (t *table) each(fn func(name []byte, m *M)) {
+	for i := range t.entries {
+		e := &t.entries[i]
+		if e.used {
+			fn(t.name(e), &e.m)
+		}
+	}
+}
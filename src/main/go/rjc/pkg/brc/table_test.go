@@ -0,0 +1,104 @@
+package brc
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestTableGrow(t *testing.T) {
+	tbl := newTable(initialTableSize)
+	const n = initialTableSize * 2
+	for round := 0; round < 2; round++ {
+		for i := 0; i < n; i++ {
+			name := []byte("station-" + strconv.Itoa(i))
+			tbl.get(name, hashName(name)).Add(int64(i))
+		}
+	}
+	if tbl.size != n {
+		t.Fatalf("expected %d entries, got %d", n, tbl.size)
+	}
+
+	seen := 0
+	tbl.each(func(name []byte, m *M) {
+		seen++
+		i, err := strconv.Atoi(string(name[len("station-"):]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Count != 2 || m.Min != int64(i) || m.Max != int64(i) || m.Sum != int64(2*i) {
+			t.Errorf("%s: unexpected %+v", name, *m)
+		}
+	})
+	if seen != n {
+		t.Errorf("expected to visit %d entries, got %d", n, seen)
+	}
+}
+
+func TestTableHashCollision(t *testing.T) {
+	tbl := newTable(initialTableSize)
+	a, b := []byte("a"), []byte("b")
+	tbl.get(a, 42).Add(10)
+	tbl.get(b, 42).Add(20)
+	tbl.get(a, 42).Add(30)
+
+	if m := tbl.get(a, 42); m.Count != 2 || m.Sum != 40 {
+		t.Errorf("a: unexpected %+v", *m)
+	}
+	if m := tbl.get(b, 42); m.Count != 1 || m.Sum != 20 {
+		t.Errorf("b: unexpected %+v", *m)
+	}
+}
+
+func TestKeyArena(t *testing.T) {
+	tbl := newTable(16)
+	// 超过多个块，且包含比块更长的站点名
+	long := make([]byte, keyBlockSize+1)
+	for i := range long {
+		long[i] = 'x'
+	}
+	var names []string
+	for i := 0; i < 3*keyBlockSize/10; i++ {
+		name := []byte("station-" + strconv.Itoa(i))
+		if i == 100 {
+			name = long
+		}
+		tbl.get(name, hashName(name)).Add(1)
+		// 新插入的站点名位于最后一个块的末尾
+		block := tbl.keys.blocks[len(tbl.keys.blocks)-1]
+		names = append(names, unsafeBytesToString(block[len(block)-len(name):]))
+	}
+	if len(tbl.keys.blocks) < 3 {
+		t.Fatalf("expected at least 3 blocks, got %d", len(tbl.keys.blocks))
+	}
+	for i, name := range names {
+		expected := "station-" + strconv.Itoa(i)
+		if i == 100 {
+			expected = string(long)
+		}
+		if name != expected {
+			t.Fatalf("%d: expected %q, got %q", i, expected, name)
+		}
+	}
+}
+
+func TestStatisticResultsAfterReset(t *testing.T) {
+	s := NewStatistic()
+	s.ParseAndAddLines([]byte("Hamburg;12.0\nBulawayo;8.9\n"))
+	results := s.Results()
+	// 结果中的站点名引用s的keyArena，reset之后插入的站点不能覆盖它们
+	s.reset()
+	s.ParseAndAddLines([]byte("Palembang;38.8\nXXXXXXXXXXXXXXXXXXX;1.0\n"))
+	if results[0].Name != "Bulawayo" || results[1].Name != "Hamburg" {
+		t.Errorf("station names changed after reset: %q, %q", results[0].Name, results[1].Name)
+	}
+
+	// 没有共享时reset复用已分配的块
+	s = NewStatistic()
+	s.ParseAndAddLines([]byte("Hamburg;12.0\n"))
+	block := &s.table.keys.blocks[0][:1][0]
+	s.reset()
+	s.ParseAndAddLines([]byte("Bulawayo;8.9\n"))
+	if &s.table.keys.blocks[0][:1][0] != block {
+		t.Error("expected reset to reuse the first block")
+	}
+}
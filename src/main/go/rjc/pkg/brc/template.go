@@ -0,0 +1,32 @@
+package brc
+
+import (
+	"io"
+	"text/template"
+)
+
+// WriteTemplate 使用text/template渲染结果，模板的数据是按当前顺序排列的Results，
+// 每个Station可以访问Name和M的各个字段，其中温度以0.1度为单位。模板中还可以使用以下函数：
+//
+//	temp  将以0.1度为单位的温度，如.Min和.Max，格式化为opts.Unit的度，保留一位小数
+//	mean  返回站点的平均值，格式与其它输出相同
+//	round 将以度为单位的值保留一位小数，如.Stddev
+func (r Results) WriteTemplate(w io.Writer, text string, opts WriteOptions) error {
+	tmpl, err := template.New("results").Funcs(template.FuncMap{
+		"temp": func(v int64) string {
+			if opts.converts() {
+				return formatValue(opts.temperature(float64(v) / 10))
+			}
+			return formatTenths(v)
+		},
+		"mean": func(s Station) string {
+			_, mean, _ := s.formatMinMeanMax(opts)
+			return mean
+		},
+		"round": formatValue,
+	}).Parse(text)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, r)
+}
@@ -0,0 +1,31 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	text := "{{range .}}{{.Name}}: {{temp .Min}} {{mean .}} {{temp .Max}} n={{.Count}} sd={{round .Stddev}}\n{{end}}"
+	for _, tc := range []struct {
+		opts     WriteOptions
+		expected string
+	}{
+		{WriteOptions{}, "a: -2.0 -2.0 -2.0 n=1 sd=0.0\nb: 1.5 2.0 2.5 n=2 sd=0.5\n"},
+		{WriteOptions{Unit: UnitFahrenheit}, "a: 28.4 28.4 28.4 n=1 sd=0.0\nb: 34.7 35.6 36.5 n=2 sd=0.5\n"},
+	} {
+		buf := &bytes.Buffer{}
+		if err := testResults().WriteTemplate(buf, text, tc.opts); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("unit %s: expected %q, got %q", tc.opts.Unit, tc.expected, buf.String())
+		}
+	}
+
+	for _, text := range []string{"{{range .}}", "{{unknown .}}", "{{range .}}{{.Missing}}{{end}}"} {
+		if err := testResults().WriteTemplate(&bytes.Buffer{}, text, WriteOptions{}); err == nil {
+			t.Errorf("%q: expected error", text)
+		}
+	}
+}
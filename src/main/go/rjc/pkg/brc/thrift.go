@@ -0,0 +1,170 @@
+package brc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Thrift compact protocol的类型，Parquet的元数据和page header都以此编码
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+
+	// 嵌套结构体的最大深度，防止损坏的文件导致无限递归
+	thriftMaxDepth = 64
+)
+
+var errBadThrift = errors.New("invalid thrift data")
+
+// thriftReader 从data中解码compact protocol，只实现解析Parquet需要的部分。
+// 出错后所有读取都返回零值，错误保存在err中
+type thriftReader struct {
+	data  []byte
+	pos   int
+	err   error
+	depth int
+}
+
+func (r *thriftReader) fail() {
+	if r.err == nil {
+		r.err = errBadThrift
+	}
+	r.pos = len(r.data)
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.fail()
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+// int 读取zigzag编码的i16、i32或i64
+func (r *thriftReader) int() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) binary() []byte {
+	n := r.uvarint()
+	if n > uint64(len(r.data)-r.pos) {
+		r.fail()
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// list 读取list或set的头部，返回元素类型和数量
+func (r *thriftReader) list() (typ byte, n int) {
+	h := r.byte()
+	typ, size := h&0x0f, uint64(h>>4)
+	if size == 15 {
+		size = r.uvarint()
+	}
+	// 每个元素至少占一个字节
+	if size > uint64(len(r.data)-r.pos) {
+		r.fail()
+		return 0, 0
+	}
+	return typ, int(size)
+}
+
+// readStruct 依次读取结构体的字段，fn处理需要的字段并返回true，其它字段被跳过。
+// bool字段的值编码在类型中，fn可以直接比较typ == thriftTrue
+func (r *thriftReader) readStruct(fn func(id int16, typ byte) bool) {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > thriftMaxDepth {
+		r.fail()
+		return
+	}
+	var id int16
+	for r.err == nil {
+		h := r.byte()
+		typ := h & 0x0f
+		if typ == thriftStop {
+			return
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.int())
+		}
+		if !fn(id, typ) {
+			r.skip(typ)
+		}
+	}
+}
+
+// skip 跳过一个类型为typ的字段值
+func (r *thriftReader) skip(typ byte) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+	case thriftByte:
+		r.byte()
+	case thriftI16, thriftI32, thriftI64:
+		r.uvarint()
+	case thriftDouble:
+		if len(r.data)-r.pos < 8 {
+			r.fail()
+			return
+		}
+		r.pos += 8
+	case thriftBinary:
+		r.binary()
+	case thriftList, thriftSet:
+		elem, n := r.list()
+		for i := 0; i < n && r.err == nil; i++ {
+			r.skipElem(elem)
+		}
+	case thriftMap:
+		n := r.uvarint()
+		if n == 0 {
+			return
+		}
+		kv := r.byte()
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			r.skipElem(kv >> 4)
+			r.skipElem(kv & 0x0f)
+		}
+	case thriftStruct:
+		r.readStruct(func(int16, byte) bool { return false })
+	default:
+		r.fail()
+	}
+}
+
+// 容器中的bool占用一个字节，其它类型与字段相同
+func (r *thriftReader) skipElem(typ byte) {
+	if typ == thriftTrue || typ == thriftFalse {
+		r.byte()
+		return
+	}
+	r.skip(typ)
+}
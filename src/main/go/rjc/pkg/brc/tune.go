@@ -0,0 +1,119 @@
+package brc
+
+import (
+	"io"
+	"runtime"
+	"time"
+)
+
+// 自动调优时用于试验的数据量，以及每次试验的最小数据量，测试时替换
+var (
+	tuneSampleSize   int64 = 256 * 1024 * 1024
+	minTuneTrialSize int64 = 8 * 1024 * 1024
+)
+
+// EngineChunk每次读取的块大小的候选值
+var tuneBufferSizes = []int{1 << 20, 4 << 20, 8 << 20, 16 << 20}
+
+// WithAutoTune 开启自动调优：先用文件开头的一部分数据依次试验不同的worker数量，
+// EngineChunk再试验不同的读取块大小，之后的数据使用吞吐量最高的配置处理。
+// 试验处理的数据同样计入结果，不会重复读取。只对EngineChunk和EngineMmap有效；
+// 指定了WithWorkers时不调整worker数量，文件太小时不调优
+func WithAutoTune(tune bool) Option {
+	return func(o *options) {
+		o.autoTune = tune
+	}
+}
+
+// tuneWorkers 返回worker数量的候选值：不超过limit的2的幂以及limit本身
+func tuneWorkers(limit int) []int {
+	var workers []int
+	for w := 1; w < limit; w *= 2 {
+		workers = append(workers, w)
+	}
+	return append(workers, limit)
+}
+
+// autoTune 用run依次处理试验区间和剩余的数据，run按j.workers和j.memory处理[j.start, j.end)。
+// 每次试验处理一段按行对齐的区间，记录吞吐量，选出最好的配置后处理剩余数据，返回所有的Statistic
+func (j *job) autoTune(file io.ReaderAt, chunk bool, run func() ([]*Statistic, error)) ([]*Statistic, error) {
+	start, end := j.start, j.size
+	if j.end > 0 {
+		end = j.end
+	}
+
+	var workers []int
+	if j.options.workers > 0 {
+		workers = []int{j.workers}
+	} else if j.maxMemory > 0 {
+		// 缓冲区按planMemory的worker数量分配，不能再增加
+		workers = tuneWorkers(j.workers)
+	} else {
+		workers = tuneWorkers(runtime.NumCPU())
+	}
+	var buffers []int
+	if chunk {
+		for _, size := range tuneBufferSizes {
+			if j.maxMemory == 0 || size <= j.memory.chunkBufferSize {
+				buffers = append(buffers, size)
+			}
+		}
+	}
+
+	sample := min(tuneSampleSize, (end-start)/4)
+	trial := sample / int64(len(workers)+len(buffers))
+	if len(workers)+len(buffers) <= 1 || trial < minTuneTrialSize {
+		return run()
+	}
+
+	var statistics []*Statistic
+	var buf [256]byte
+	// measure 用当前的配置处理下一段试验区间，返回吞吐量
+	measure := func() (float64, error) {
+		next, err := nextLineStart(file, min(start+trial, end), end, buf[:])
+		if err != nil {
+			return 0, err
+		}
+		j.start, j.end = start, next
+		t := time.Now()
+		s, err := run()
+		if err != nil {
+			return 0, err
+		}
+		statistics = append(statistics, s...)
+		elapsed := time.Since(t)
+		start = next
+		return float64(j.end-j.start) / max(elapsed.Seconds(), 1e-9), nil
+	}
+
+	best, bestBuffer, bestThroughput := j.workers, j.memory.chunkBufferSize, 0.0
+	for _, w := range workers {
+		j.workers = w
+		throughput, err := measure()
+		if err != nil {
+			return nil, err
+		}
+		if throughput > bestThroughput {
+			best, bestThroughput = w, throughput
+		}
+	}
+	j.workers, bestThroughput = best, 0
+	for _, size := range buffers {
+		j.memory.chunkBufferSize = size
+		throughput, err := measure()
+		if err != nil {
+			return nil, err
+		}
+		if throughput > bestThroughput {
+			bestBuffer, bestThroughput = size, throughput
+		}
+	}
+	j.memory.chunkBufferSize = bestBuffer
+
+	j.start, j.end = start, end
+	s, err := run()
+	if err != nil {
+		return nil, err
+	}
+	return append(statistics, s...), nil
+}
@@ -0,0 +1,43 @@
+package brc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTuneWorkers(t *testing.T) {
+	for limit, expected := range map[int][]int{1: {1}, 4: {1, 2, 4}, 6: {1, 2, 4, 6}} {
+		if workers := tuneWorkers(limit); !reflect.DeepEqual(workers, expected) {
+			t.Errorf("%d: expected %v, got %v", limit, expected, workers)
+		}
+	}
+}
+
+func TestProcessAutoTune(t *testing.T) {
+	defer func(sample, trial int64) { tuneSampleSize, minTuneTrialSize = sample, trial }(tuneSampleSize, minTuneTrialSize)
+	tuneSampleSize, minTuneTrialSize = 64*1024, 1024
+
+	path := writeMeasurements(t, strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n", 10000))
+	for _, engine := range []Engine{EngineMmap, EngineChunk} {
+		for _, opts := range [][]Option{
+			{},
+			{WithWorkers(3)},
+			{WithMaxMemory(16 << 20)},
+			{WithRange(1000, 300000)},
+		} {
+			opts = append(opts, WithEngine(engine))
+			expected, err := Process(path, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := Process(path, append(opts, WithAutoTune(true))...)
+			if err != nil {
+				t.Fatalf("%s: %v", engine, err)
+			}
+			if !reflect.DeepEqual(results, expected) {
+				t.Errorf("%s: expected %v, got %v", engine, expected, results)
+			}
+		}
+	}
+}
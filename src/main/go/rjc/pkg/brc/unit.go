@@ -0,0 +1,55 @@
+package brc
+
+import "fmt"
+
+// Unit 是温度的单位，空字符串等同于UnitCelsius
+type Unit string
+
+const (
+	UnitCelsius    Unit = "c"
+	UnitFahrenheit Unit = "f"
+)
+
+// ParseUnit 解析c或f
+func ParseUnit(s string) (Unit, error) {
+	switch u := Unit(s); u {
+	case UnitCelsius, UnitFahrenheit:
+		return u, nil
+	default:
+		return "", fmt.Errorf("unknown unit %q, want c or f", s)
+	}
+}
+
+// converts 判断输入和输出的单位是否不同
+func (opts WriteOptions) converts() bool {
+	return (opts.InputUnit == UnitFahrenheit) != (opts.Unit == UnitFahrenheit)
+}
+
+// temperature 将单位为opts.InputUnit的温度v换算为opts.Unit，单位相同时原样返回
+func (opts WriteOptions) temperature(v float64) float64 {
+	from, to := opts.InputUnit == UnitFahrenheit, opts.Unit == UnitFahrenheit
+	switch {
+	case to && !from:
+		return v*9/5 + 32
+	case from && !to:
+		return (v - 32) * 5 / 9
+	default:
+		return v
+	}
+}
+
+// difference 将温差的degree次方v换算为opts.Unit，如标准差的degree为1，方差为2
+func (opts WriteOptions) difference(v float64, degree int) float64 {
+	from, to := opts.InputUnit == UnitFahrenheit, opts.Unit == UnitFahrenheit
+	scale := 1.0
+	switch {
+	case to && !from:
+		scale = 9.0 / 5
+	case from && !to:
+		scale = 5.0 / 9
+	}
+	for i := 0; i < degree; i++ {
+		v *= scale
+	}
+	return v
+}
@@ -0,0 +1,282 @@
+package brc
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// io_uring的ABI常量，见linux/io_uring.h
+const (
+	uringOpRead         = 22
+	uringEnterGetEvents = 1
+
+	uringOffSQRing = 0
+	uringOffCQRing = 0x8000000
+	uringOffSQEs   = 0x10000000
+)
+
+const (
+	// 提交队列的大小，即同时进行的读取请求数
+	uringEntries = 16
+	// 每个读取请求的最大字节数
+	uringReadSize = 4 * 1024 * 1024
+)
+
+type uringSQOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringCQOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFD uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  uringSQOffsets
+	cqOff                                                                  uringCQOffsets
+}
+
+type uringSQE struct {
+	opcode, flags         uint8
+	ioprio                uint16
+	fd                    int32
+	off, addr             uint64
+	len, rwFlags          uint32
+	userData              uint64
+	bufIndex, personality uint16
+	spliceFDIn            int32
+	addr3, pad            uint64
+}
+
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring 是一个io_uring实例，提交队列和完成队列的head、tail通过原子操作与内核同步
+type uring struct {
+	fd                     int
+	sqRing, cqRing, sqeMem []byte
+
+	sqHead, sqTail, sqMask *uint32
+	sqArray                []uint32
+	sqes                   []uringSQE
+	cqHead, cqTail, cqMask *uint32
+	cqes                   []uringCQE
+}
+
+func newURing(entries uint32) (u *uring, err error) {
+	var p uringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("io_uring_setup", errno)
+	}
+	u = &uring{fd: int(fd)}
+	defer func() {
+		if err != nil {
+			u.close()
+		}
+	}()
+
+	mmap := func(offset int64, size uint32) ([]byte, error) {
+		b, err := unix.Mmap(u.fd, offset, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+		return b, os.NewSyscallError("mmap", err)
+	}
+	if u.sqRing, err = mmap(uringOffSQRing, p.sqOff.array+p.sqEntries*4); err != nil {
+		return nil, err
+	}
+	if u.cqRing, err = mmap(uringOffCQRing, p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(uringCQE{}))); err != nil {
+		return nil, err
+	}
+	if u.sqeMem, err = mmap(uringOffSQEs, p.sqEntries*uint32(unsafe.Sizeof(uringSQE{}))); err != nil {
+		return nil, err
+	}
+
+	u32 := func(ring []byte, off uint32) *uint32 {
+		return (*uint32)(unsafe.Pointer(&ring[off]))
+	}
+	u.sqHead, u.sqTail, u.sqMask = u32(u.sqRing, p.sqOff.head), u32(u.sqRing, p.sqOff.tail), u32(u.sqRing, p.sqOff.ringMask)
+	u.sqArray = unsafe.Slice(u32(u.sqRing, p.sqOff.array), p.sqEntries)
+	u.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&u.sqeMem[0])), p.sqEntries)
+	u.cqHead, u.cqTail, u.cqMask = u32(u.cqRing, p.cqOff.head), u32(u.cqRing, p.cqOff.tail), u32(u.cqRing, p.cqOff.ringMask)
+	u.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&u.cqRing[p.cqOff.cqes])), p.cqEntries)
+	return u, nil
+}
+
+func (u *uring) close() error {
+	for _, b := range [][]byte{u.sqRing, u.cqRing, u.sqeMem} {
+		if b != nil {
+			unix.Munmap(b)
+		}
+	}
+	return unix.Close(u.fd)
+}
+
+// free 返回提交队列的空闲位置数
+func (u *uring) free() int {
+	return len(u.sqes) - int(*u.sqTail-atomic.LoadUint32(u.sqHead))
+}
+
+// prepRead 在提交队列中加入一个读取请求，调用者需要保证队列有空闲位置，且buf在请求完成前不被回收
+func (u *uring) prepRead(fd int, buf []byte, off int64, userData uint64) {
+	tail := *u.sqTail
+	i := tail & *u.sqMask
+	u.sqes[i] = uringSQE{
+		opcode:   uringOpRead,
+		fd:       int32(fd),
+		off:      uint64(off),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      uint32(len(buf)),
+		userData: userData,
+	}
+	u.sqArray[i] = i
+	atomic.StoreUint32(u.sqTail, tail+1)
+}
+
+// submit 提交n个请求，并等待至少wait个请求完成
+func (u *uring) submit(n, wait int) error {
+	for {
+		_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(u.fd), uintptr(n), uintptr(wait), uringEnterGetEvents, 0, 0)
+		if errno == unix.EINTR {
+			// 请求已经提交，重试时只等待完成
+			n = 0
+			continue
+		}
+		if errno != 0 {
+			return os.NewSyscallError("io_uring_enter", errno)
+		}
+		return nil
+	}
+}
+
+// reap 依次处理已完成的请求
+func (u *uring) reap(fn func(cqe uringCQE)) {
+	head := *u.cqHead
+	for tail := atomic.LoadUint32(u.cqTail); head != tail; head++ {
+		fn(u.cqes[head&*u.cqMask])
+	}
+	atomic.StoreUint32(u.cqHead, head)
+}
+
+// uringReader 通过io_uring顺序读取文件的[off, end)区间，每次Read将p切分成多个请求同时提交，
+// 不受单个goroutine同步读取的限制
+type uringReader struct {
+	ring     *uring
+	fd       int
+	off, end int64
+}
+
+func newURingReader(file *os.File, off, end int64) (*uringReader, error) {
+	ring, err := newURing(uringEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &uringReader{ring: ring, fd: int(file.Fd()), off: off, end: end}, nil
+}
+
+// uringSegment 是Read中一个读取请求负责的部分，done是已经读取的字节数
+type uringSegment struct {
+	buf  []byte
+	done int
+}
+
+func (r *uringReader) Read(p []byte) (int, error) {
+	p = p[:min(int64(len(p)), r.end-r.off)]
+	if len(p) == 0 {
+		return 0, io.EOF
+	}
+	var segments []uringSegment
+	for i := 0; i < len(p); i += uringReadSize {
+		segments = append(segments, uringSegment{buf: p[i:min(len(p), i+uringReadSize)]})
+	}
+
+	// 未提交的请求，部分读取的请求完成后重新加入
+	var pending []int
+	for i := range segments {
+		pending = append(pending, i)
+	}
+	inflight := 0
+	var err error
+	for (len(pending) > 0 || inflight > 0) && err == nil {
+		n := 0
+		for ; n < len(pending) && r.ring.free() > 0; n++ {
+			s := &segments[pending[n]]
+			off := r.off + int64(pending[n]*uringReadSize+s.done)
+			r.ring.prepRead(r.fd, s.buf[s.done:], off, uint64(pending[n]))
+		}
+		pending = pending[n:]
+		inflight += n
+		if err = r.ring.submit(n, 1); err != nil {
+			break
+		}
+		r.ring.reap(func(cqe uringCQE) {
+			inflight--
+			s := &segments[cqe.userData]
+			switch {
+			case cqe.res < 0 && unix.Errno(-cqe.res) != unix.EINTR && unix.Errno(-cqe.res) != unix.EAGAIN:
+				if err == nil {
+					err = &os.PathError{Op: "read", Path: "io_uring", Err: unix.Errno(-cqe.res)}
+				}
+			case cqe.res == 0:
+				// 到达文件末尾，不再继续读取
+			default:
+				s.done += int(max(cqe.res, 0))
+				if s.done < len(s.buf) {
+					pending = append(pending, int(cqe.userData))
+				}
+			}
+		})
+	}
+	// 出错时等待已提交的请求完成，之后才能归还p
+	for inflight > 0 {
+		if r.ring.submit(0, 1) != nil {
+			break
+		}
+		r.ring.reap(func(uringCQE) { inflight-- })
+	}
+	runtime.KeepAlive(p)
+	if err != nil {
+		return 0, err
+	}
+
+	// 文件在读取过程中被截断时，只返回第一个不完整的请求之前连续读取的数据
+	n := 0
+	for _, s := range segments {
+		n += s.done
+		if s.done < len(s.buf) {
+			break
+		}
+	}
+	r.off += int64(n)
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (r *uringReader) Close() error {
+	return r.ring.close()
+}
+
+// uringStatistics 通过io_uring顺序读取文件，与EngineScanner一样按行分批分发给worker
+func (j *job) uringStatistics(file *os.File) ([]*Statistic, error) {
+	end := j.size
+	if j.end > 0 {
+		end = j.end
+	}
+	r, err := newURingReader(file, j.start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return j.scanStatistics(r)
+}
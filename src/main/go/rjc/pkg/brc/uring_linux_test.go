@@ -0,0 +1,55 @@
+package brc
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestURingReader(t *testing.T) {
+	// 超过uringReadSize，Read需要拆分成多个请求
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*uringReadSize+12345)/16)
+	path := writeMeasurements(t, string(data))
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, err := newURingReader(file, 100, int64(len(data))-7)
+	if err != nil {
+		t.Skipf("io_uring unavailable: %v", err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data[100:len(data)-7]) {
+		t.Errorf("expected %d bytes, got %d", len(data)-107, len(got))
+	}
+}
+
+func TestProcessURing(t *testing.T) {
+	path := writeMeasurements(t, strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\nPalembang;38.8\n", 1000))
+	expected, err := Process(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Process(path, WithEngine(EngineIOUring))
+	if err != nil {
+		t.Skipf("io_uring unavailable: %v", err)
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+	results, err = Process(path, WithEngine(EngineIOUring), WithRange(100, 20000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, err = Process(path, WithRange(100, 20000)); err != nil || !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v, %v", expected, results, err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package brc
+
+import (
+	"errors"
+	"os"
+)
+
+func (j *job) uringStatistics(file *os.File) ([]*Statistic, error) {
+	return nil, errors.New("io_uring engine is only supported on Linux")
+}
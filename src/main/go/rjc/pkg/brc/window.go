@@ -0,0 +1,107 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WindowSeparator 分隔结果中的站点名和时间窗口的起始时间，如`Hamburg@2024-01-01T10:00:00Z`
+const WindowSeparator = '@'
+
+// WithWindow 读取`station;timestamp;value`格式的输入，按站点和长度为window的时间窗口分别聚合。
+// timestamp是Unix秒数或RFC 3339格式的时间，窗口按UTC对齐，结果中的站点名是
+// `站点名@窗口的起始时间`，起始时间为RFC 3339格式，因此同一站点的窗口按时间排序
+func WithWindow(window time.Duration) Option {
+	return func(o *options) {
+		o.window = window
+	}
+}
+
+func (o *options) checkWindow() error {
+	switch {
+	case o.window < 0:
+		return fmt.Errorf("window must be positive, got %v", o.window)
+	case o.window > 0 && o.quoted:
+		return errors.New("windows do not support quoted input")
+	}
+	return nil
+}
+
+// SplitWindow 将WithWindow结果中的站点名拆分为站点名和窗口的起始时间
+func SplitWindow(name string) (station string, start time.Time, ok bool) {
+	i := strings.LastIndexByte(name, WindowSeparator)
+	if i < 0 {
+		return name, time.Time{}, false
+	}
+	start, err := time.Parse(time.RFC3339, name[i+1:])
+	if err != nil {
+		return name, time.Time{}, false
+	}
+	return name[:i], start, true
+}
+
+// parseTimestamp 解析Unix秒数或RFC 3339格式的时间
+func parseTimestamp(ts []byte) (time.Time, bool) {
+	if sec, err := strconv.ParseInt(unsafeBytesToString(ts), 10, 64); err == nil {
+		return time.Unix(sec, 0), true
+	}
+	t, err := time.Parse(time.RFC3339, unsafeBytesToString(ts))
+	return t, err == nil
+}
+
+// windowName 返回name加上当前行所在窗口的起始时间，结果位于s的缓冲区中，在下一次调用前有效
+func (s *Statistic) windowName(name []byte) []byte {
+	s.windowBuf = append(s.windowBuf[:0], name...)
+	s.windowBuf = append(s.windowBuf, WindowSeparator)
+	s.windowBuf = s.windowStart.AppendFormat(s.windowBuf, time.RFC3339)
+	return s.windowBuf
+}
+
+// ParseAndAddLinesWindowed 解析WithWindow格式的多行数据，offset是lines在输入中的起始偏移。
+// strict为true时与ParseAndAddLinesStrict一样校验每一行，遇到第一个不合法的行时返回*ParseError；
+// 否则跳过时间戳不合法的行，温度的解析与ParseAndAddLines相同
+func (s *Statistic) ParseAndAddLinesWindowed(lines []byte, offset int64, strict bool) error {
+	for len(lines) > 0 {
+		line, next := lines, len(lines)
+		if end := bytes.IndexByte(lines, '\n'); end >= 0 {
+			line, next = lines[:end], end+1
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		name, rest, ok := bytes.Cut(line, []byte{';'})
+		ts, num, ok2 := bytes.Cut(rest, []byte{';'})
+		ok = ok && ok2
+		var t time.Time
+		if ok {
+			t, ok = parseTimestamp(ts)
+		}
+		var val int64
+		if strict {
+			if ok && len(name) > 0 && s.validName(name) {
+				val, ok = parseTenths(num)
+			} else {
+				ok = false
+			}
+			if !ok {
+				return &ParseError{Offset: offset, Line: string(line)}
+			}
+			if s.spec {
+				if reason := specViolation(name, val); reason != "" {
+					return &ParseError{Offset: offset, Line: string(line), Reason: reason}
+				}
+			}
+		} else {
+			val = parseTenthsLenient(num)
+		}
+		if ok {
+			s.windowStart = t.UTC().Truncate(s.window)
+			s.Add(name, val)
+		}
+		lines = lines[next:]
+		offset += int64(next)
+	}
+	return nil
+}
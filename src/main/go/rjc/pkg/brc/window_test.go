@@ -0,0 +1,52 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProcessWindow(t *testing.T) {
+	path := writeMeasurements(t, "Hamburg;2024-01-01T10:15:00Z;12.0\n"+
+		"Hamburg;2024-01-01T10:45:00+01:00;-3.4\n"+
+		"Hamburg;1704105000;8.0\n"+
+		"Bulawayo;2024-01-01T10:59:59Z;8.9\n"+
+		"Hamburg;yesterday;99.9\n")
+	for _, engine := range []Engine{EngineScanner, EngineMmap, EngineChunk} {
+		results, err := Process(path, WithWindow(time.Hour), WithEngine(engine))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		results.WriteTo(buf)
+		expected := "{Bulawayo@2024-01-01T10:00:00Z=8.9/8.9/8.9, " +
+			"Hamburg@2024-01-01T09:00:00Z=-3.4/-3.4/-3.4, " +
+			"Hamburg@2024-01-01T10:00:00Z=8.0/10.0/12.0}\n"
+		if buf.String() != expected {
+			t.Errorf("%s: expected %q, got %q", engine, expected, buf.String())
+		}
+	}
+
+	_, err := Process(path, WithWindow(time.Hour), WithStrict(true))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != "Hamburg;yesterday;99.9" {
+		t.Errorf("expected parse error for the bad timestamp, got %v", err)
+	}
+	if _, err := Process(path, WithWindow(-time.Hour)); err == nil {
+		t.Error("expected error for negative window")
+	}
+	if _, err := Process(path, WithWindow(time.Hour), WithQuoted(true)); err == nil {
+		t.Error("expected error for quoted input")
+	}
+}
+
+func TestSplitWindow(t *testing.T) {
+	station, start, ok := SplitWindow("a@b@2024-01-01T10:00:00Z")
+	if !ok || station != "a@b" || !start.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected %q, %v, %v", station, start, ok)
+	}
+	if _, _, ok := SplitWindow("Hamburg"); ok {
+		t.Error("expected no window")
+	}
+}
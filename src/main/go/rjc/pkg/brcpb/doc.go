@@ -0,0 +1,4 @@
+// Package brcpb 是由brc.proto生成的gRPC查询接口
+package brcpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative brc.proto
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+var blockProfile = flag.String("blockprofile", "", "write a goroutine blocking profile to `file`, showing where workers wait on channels, wg.Wait and other synchronization")
+var blockProfileRate = flag.Int("blockprofile-rate", 10000, "with -blockprofile, sample about one blocking event per `ns` nanoseconds blocked, 1 records every event")
+var mutexProfile = flag.String("mutexprofile", "", "write a mutex contention profile to `file`")
+var mutexProfileFraction = flag.Int("mutexprofile-fraction", 1, "with -mutexprofile, sample on average 1 in `n` contention events")
+var goroutineProfile = flag.String("goroutineprofile", "", "write the stacks of all goroutines to `file` when the run finishes, or after -goroutineprofile-delay")
+var goroutineProfileDelay = flag.Duration("goroutineprofile-delay", 0, "take the -goroutineprofile snapshot after `duration` instead of at the end, to catch workers mid-run")
+
+// startProfiles 按-memprofile、-blockprofile、-mutexprofile和-goroutineprofile开始采样，
+// 返回的函数写出各个profile并恢复采样率
+func startProfiles() (stop func()) {
+	if *blockProfile != "" {
+		runtime.SetBlockProfileRate(max(*blockProfileRate, 1))
+	}
+	if *mutexProfile != "" {
+		runtime.SetMutexProfileFraction(max(*mutexProfileFraction, 1))
+	}
+	var snapshot *time.Timer
+	if *goroutineProfile != "" && *goroutineProfileDelay > 0 {
+		snapshot = time.AfterFunc(*goroutineProfileDelay, func() {
+			writeProfile("goroutine", *goroutineProfile)
+		})
+	}
+	return func() {
+		if *memprofile != "" {
+			// 先GC，使profile反映最新的存活对象
+			runtime.GC()
+			writeProfile("heap", *memprofile)
+		}
+		if *blockProfile != "" {
+			writeProfile("block", *blockProfile)
+			runtime.SetBlockProfileRate(0)
+		}
+		if *mutexProfile != "" {
+			writeProfile("mutex", *mutexProfile)
+			runtime.SetMutexProfileFraction(0)
+		}
+		// 延迟的快照已经写出时不再覆盖
+		if *goroutineProfile != "" && (snapshot == nil || snapshot.Stop()) {
+			writeProfile("goroutine", *goroutineProfile)
+		}
+	}
+}
+
+// writeProfile 将名为name的runtime profile写入path
+func writeProfile(name, path string) {
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		return pprof.Lookup(name).WriteTo(w, 0)
+	})
+	if err != nil {
+		log.Fatalf("could not write %s profile: %v", name, err)
+	}
+}
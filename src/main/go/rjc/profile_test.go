@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartProfiles(t *testing.T) {
+	dir := t.TempDir()
+	defer func(block, mutex, goroutine string, delay time.Duration) {
+		*blockProfile, *mutexProfile, *goroutineProfile, *goroutineProfileDelay = block, mutex, goroutine, delay
+	}(*blockProfile, *mutexProfile, *goroutineProfile, *goroutineProfileDelay)
+	*blockProfile = filepath.Join(dir, "block.out")
+	*mutexProfile = filepath.Join(dir, "mutex.out")
+	*goroutineProfile = filepath.Join(dir, "goroutine.out")
+	*goroutineProfileDelay = 0
+
+	stop := startProfiles()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(10 * time.Millisecond)
+	}()
+	wg.Wait()
+	stop()
+
+	for _, path := range []string{*blockProfile, *mutexProfile, *goroutineProfile} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples, err := parseProfile(data)
+		if err != nil {
+			t.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		if path != *mutexProfile && len(samples) == 0 {
+			t.Errorf("%s: expected samples", filepath.Base(path))
+		}
+	}
+}
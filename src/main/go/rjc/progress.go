@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var progress = flag.Bool("progress", false, "periodically print bytes processed, rows/sec and ETA to stderr")
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func printProgress(w io.Writer, p *brc.Progress, elapsed time.Duration) {
+	bytes, rows, total := p.Bytes(), p.Rows(), p.Total()
+	rate := float64(rows) / elapsed.Seconds()
+	if total > 0 {
+		eta := "unknown"
+		if bytes > 0 {
+			remaining := time.Duration(float64(elapsed) * float64(total-bytes) / float64(bytes))
+			eta = remaining.Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s / %s (%.1f%%), %.0f rows/s, ETA %s\n",
+			formatBytes(bytes), formatBytes(total), float64(bytes)*100/float64(total), rate, eta)
+	} else {
+		fmt.Fprintf(w, "%s, %.0f rows/s\n", formatBytes(bytes), rate)
+	}
+}
+
+// 每隔interval向w打印一次进度，返回的函数用于停止汇报
+func reportProgress(w io.Writer, p *brc.Progress, interval time.Duration) (stop func()) {
+	start := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				printProgress(w, p, time.Since(start))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var maxRowsPerSec = flag.Int("max-rows-per-sec", 0, "aggregate at most `rows` per second on average, with bursts of up to one second's worth, so -follow or streaming input shares the host with other workloads; 0 means no limit")
+var maxBytesPerSec = flag.String("max-bytes-per-sec", "", "aggregate at most `size` of input per second on average, e.g. 64MB, like -max-rows-per-sec")
+
+// newRateLimiter 按-max-rows-per-sec和-max-bytes-per-sec的取值创建限速器，都不限制时返回nil
+func newRateLimiter(rows int, size string) (*brc.RateLimiter, error) {
+	if rows < 0 {
+		return nil, fmt.Errorf("invalid -max-rows-per-sec %d, want a positive number or 0", rows)
+	}
+	var bytes int64
+	if size != "" {
+		var err error
+		if bytes, err = parseSize(size); err != nil {
+			return nil, fmt.Errorf("invalid -max-bytes-per-sec %q", size)
+		}
+	}
+	return brc.NewRateLimiter(float64(rows), float64(bytes)), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var runStats = flag.String("run-stats", "", "after completion print wall time, bytes read, rows parsed, throughput, distinct stations, per-worker utilization and merge time to stderr, as `format` text or json")
+
+// checkRunStats 检查-run-stats的格式
+func checkRunStats() error {
+	switch *runStats {
+	case "", "text", "json":
+		return nil
+	default:
+		return fmt.Errorf("unknown -run-stats format %q, want text or json", *runStats)
+	}
+}
+
+// workerReport 是json格式中一个worker的解析情况
+type workerReport struct {
+	Batches     int     `json:"batches"`
+	Rows        int64   `json:"rows"`
+	BusySeconds float64 `json:"busy_seconds"`
+	Utilization float64 `json:"utilization"`
+}
+
+// runReport 是-run-stats json输出的内容，耗时以秒为单位
+type runReport struct {
+	WallSeconds  float64        `json:"wall_seconds"`
+	ParseSeconds float64        `json:"parse_seconds"`
+	MergeSeconds float64        `json:"merge_seconds"`
+	Bytes        int64          `json:"bytes"`
+	Rows         int64          `json:"rows"`
+	RowsPerSec   float64        `json:"rows_per_sec"`
+	GBPerSec     float64        `json:"gb_per_sec"`
+	Stations     int            `json:"stations"`
+	Workers      []workerReport `json:"workers"`
+}
+
+func newRunReport(s *brc.RunStats) runReport {
+	wall := max(s.Wall.Seconds(), 1e-9)
+	r := runReport{
+		WallSeconds:  s.Wall.Seconds(),
+		ParseSeconds: s.Parse.Seconds(),
+		MergeSeconds: s.Merge.Seconds(),
+		Bytes:        s.Bytes,
+		Rows:         s.Rows,
+		RowsPerSec:   float64(s.Rows) / wall,
+		GBPerSec:     float64(s.Bytes) / wall / 1e9,
+		Stations:     s.Stations,
+		Workers:      make([]workerReport, len(s.Workers)),
+	}
+	for i, w := range s.Workers {
+		r.Workers[i] = workerReport{Batches: w.Batches, Rows: w.Rows, BusySeconds: w.Busy.Seconds(), Utilization: s.Utilization(w)}
+	}
+	return r
+}
+
+// printRunStats 按format输出运行统计，json为一行，便于在不同提交之间记录和比较
+func printRunStats(w io.Writer, s *brc.RunStats, format string) error {
+	r := newRunReport(s)
+	if format == "json" {
+		return json.NewEncoder(w).Encode(r)
+	}
+	fmt.Fprintf(w, "wall: %s (parse %s, merge %s)\n", s.Wall.Round(time.Microsecond),
+		s.Parse.Round(time.Microsecond), s.Merge.Round(time.Microsecond))
+	fmt.Fprintf(w, "read: %s, %d rows, %d stations\n", formatBytes(s.Bytes), s.Rows, s.Stations)
+	fmt.Fprintf(w, "throughput: %.2f GB/s, %.0f rows/s\n", r.GBPerSec, r.RowsPerSec)
+	for i, wr := range r.Workers {
+		fmt.Fprintf(w, "worker %d: %d batches, %d rows, busy %s (%.1f%%)\n", i, wr.Batches, wr.Rows,
+			s.Workers[i].Busy.Round(time.Microsecond), wr.Utilization*100)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func TestPrintRunStats(t *testing.T) {
+	s := &brc.RunStats{
+		Wall: 2 * time.Second, Parse: 1900 * time.Millisecond, Merge: 100 * time.Millisecond,
+		Bytes: 4e9, Rows: 3e8, Stations: 413,
+		Workers: []brc.WorkerStats{{Batches: 10, Rows: 3e8, Busy: 950 * time.Millisecond}},
+	}
+	buf := &bytes.Buffer{}
+	if err := printRunStats(buf, s, "text"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"wall: 2s (parse 1.9s, merge 100ms)\n",
+		"throughput: 2.00 GB/s, 150000000 rows/s\n",
+		"worker 0: 10 batches, 300000000 rows, busy 950ms (50.0%)\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("expected %q in\n%s", line, buf)
+		}
+	}
+
+	buf.Reset()
+	if err := printRunStats(buf, s, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var r runReport
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.GBPerSec != 2 || r.Stations != 413 || len(r.Workers) != 1 || r.Workers[0].Utilization != 0.5 {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var sampleFraction = flag.Float64("sample", 0, "only aggregate a random `fraction` of the input's 1MB chunks, e.g. 0.01, for quick approximate results; chunk and mmap engines only")
+var sampleSeed = flag.Int64("sample-seed", 1, "random `seed` choosing the chunks for -sample, the same seed picks the same chunks")
+
+// newSample 返回-sample指定的采样，未指定时返回nil
+func newSample() *brc.Sample {
+	if *sampleFraction == 0 {
+		return nil
+	}
+	if *sampleFraction < 0 || *sampleFraction > 1 {
+		fatalUsage("invalid -sample %v, want a fraction in (0, 1]", *sampleFraction)
+	}
+	return &brc.Sample{Fraction: *sampleFraction, Seed: *sampleSeed}
+}
+
+// printSample 说明结果只来自输入的一部分
+func printSample(w io.Writer, s *brc.Sample) {
+	share := 0.0
+	if s.Total > 0 {
+		share = float64(s.Bytes) / float64(s.Total) * 100
+	}
+	fmt.Fprintf(w, "approximate results from %d chunks, %s of %s (%.2f%%) of the input\n",
+		s.Chunks, formatBytes(s.Bytes), formatBytes(s.Total), share)
+}
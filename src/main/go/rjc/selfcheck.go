@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var selfcheck = flag.Bool("selfcheck", false, "aggregate the input with both the optimized engine and the naive single-threaded implementation and report differing stations")
+var selfcheckSample = flag.String("selfcheck-sample", "", "only check lines starting in the first `size` of the input, e.g. 256MB")
+
+// sampleEnd 返回第一个不小于n的行首位置，即前n字节中开始的所有行的结尾
+func sampleEnd(file *os.File, n int64) (int64, error) {
+	if _, err := file.Seek(n-1, io.SeekStart); err != nil {
+		return 0, err
+	}
+	line, err := bufio.NewReader(file).ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return 0, fmt.Errorf("line at offset %d too long", n)
+	}
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return n - 1 + int64(len(line)), nil
+}
+
+// diffResults 比较两组结果中每个站点的累计值，返回按站点名排序的差异说明
+func diffResults(actual, expected brc.Results) []string {
+	format := func(m brc.M) string {
+		return fmt.Sprintf("count=%d sum=%d min=%d max=%d", m.Count, m.Sum, m.Min, m.Max)
+	}
+	stations := make(map[string]string)
+	for _, s := range actual {
+		stations[s.Name] = format(s.M)
+	}
+	var diffs []string
+	for _, s := range expected {
+		got, ok := stations[s.Name]
+		if want := format(s.M); !ok {
+			diffs = append(diffs, fmt.Sprintf("%q: missing, expected %s", s.Name, want))
+		} else if got != want {
+			diffs = append(diffs, fmt.Sprintf("%q: expected %s, got %s", s.Name, want, got))
+		}
+		delete(stations, s.Name)
+	}
+	for name, got := range stations {
+		diffs = append(diffs, fmt.Sprintf("%q: unexpected, got %s", name, got))
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// runSelfcheck 分别用Process和ProcessNaive处理输入（或其开头的一部分），结果不一致时打印差异并以状态1退出
+func runSelfcheck(path string, opts []brc.Option) {
+	if isStreamInput(path) {
+		fatalUsage("-selfcheck requires a regular input file")
+	}
+	file, err := os.Open(path)
+	check(err)
+	defer file.Close()
+	var naive io.Reader = file
+	if *selfcheckSample != "" {
+		size, err := parseSize(*selfcheckSample)
+		if err != nil {
+			fatalUsage("invalid -selfcheck-sample: %v", err)
+		}
+		info, err := file.Stat()
+		check(err)
+		if size < info.Size() {
+			end, err := sampleEnd(file, size)
+			check(err)
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				check(err)
+			}
+			opts = append(opts, brc.WithRange(0, end))
+			naive = io.LimitReader(file, end)
+		}
+	}
+
+	results, err := brc.Process(path, opts...)
+	checkProcessError(path, err)
+	expected, err := brc.ProcessNaive(naive)
+	if err != nil {
+		log.Fatalf("naive implementation: %v", err)
+	}
+
+	diffs := diffResults(results, expected)
+	if len(diffs) == 0 {
+		var rows int
+		for _, s := range expected {
+			rows += s.Count
+		}
+		fmt.Fprintf(os.Stderr, "selfcheck passed: %d stations, %d rows\n", len(expected), rows)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "selfcheck failed, results differ in %d stations:\n", len(diffs))
+	for _, d := range diffs {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	os.Exit(1)
+}
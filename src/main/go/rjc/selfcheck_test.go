@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func TestSampleEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(path, []byte("a;1.0\nbb;2.0\nc;3.0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for n, expected := range map[int64]int64{1: 6, 6: 6, 7: 13, 13: 13, 15: 18} {
+		if end, err := sampleEnd(file, n); err != nil || end != expected {
+			t.Errorf("%d: expected %d, got %d, %v", n, expected, end, err)
+		}
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+	m := func(count int, sum int64) brc.M {
+		return brc.M{Count: count, Sum: sum, Min: 1, Max: 2}
+	}
+	actual := brc.Results{{Name: "a", M: m(1, 1)}, {Name: "b", M: m(2, 3)}, {Name: "c", M: m(1, 1)}}
+	expected := brc.Results{{Name: "a", M: m(1, 1)}, {Name: "b", M: m(2, 4)}, {Name: "d", M: m(1, 2)}}
+	want := []string{
+		`"b": expected count=2 sum=4 min=1 max=2, got count=2 sum=3 min=1 max=2`,
+		`"c": unexpected, got count=1 sum=1 min=1 max=2`,
+		`"d": missing, expected count=1 sum=2 min=1 max=2`,
+	}
+	if diffs := diffResults(actual, expected); !reflect.DeepEqual(diffs, want) {
+		t.Errorf("expected %q, got %q", want, diffs)
+	}
+}
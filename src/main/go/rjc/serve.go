@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+const (
+	// 单个连接的读缓冲区，超过该长度的行会被丢弃
+	serveBufferSize = 64 * 1024
+	// 单个UDP数据报的最大长度，更长的数据报会被内核截断
+	udpPacketSize = 16 * 1024
+	// 等待解析的数据报数量，队列满时直接丢弃新的数据报
+	udpQueueSize = 4096
+)
+
+// udpStats 统计UDP数据报的接收情况
+type udpStats struct {
+	packets   atomic.Int64
+	dropped   atomic.Int64
+	truncated atomic.Int64
+}
+
+// addLines 将lines写入agg，超过limiter的速率时阻塞：TCP连接因此暂停读取，由发送方的TCP窗口反压；
+// UDP的解析队列满后丢弃新的数据报
+func addLines(agg *brc.Aggregator, limiter *brc.RateLimiter, lines []byte) {
+	agg.AddLines(lines)
+	if limiter != nil {
+		limiter.Wait(context.Background(), bytes.Count(lines, []byte{'\n'}), len(lines))
+	}
+}
+
+// addPacket 解析一个数据报，最后一行可以没有换行；被截断的数据报丢弃最后不完整的一行
+func addPacket(agg *brc.Aggregator, limiter *brc.RateLimiter, stats *udpStats, packet []byte, truncated bool) {
+	if truncated {
+		stats.truncated.Add(1)
+		last := bytes.LastIndexByte(packet, '\n')
+		packet = packet[:last+1]
+	}
+	if len(packet) > 0 {
+		addLines(agg, limiter, packet)
+	}
+}
+
+// serveUDP 由一个goroutine接收数据报，workers个goroutine解析。解析跟不上时丢弃数据报而不是阻塞接收，
+// 避免内核缓冲区溢出造成无法统计的丢包
+func serveUDP(conn net.PacketConn, agg *brc.Aggregator, limiter *brc.RateLimiter, stats *udpStats, workers int) error {
+	pool := sync.Pool{New: func() any { return make([]byte, udpPacketSize) }}
+	type packet struct {
+		buf       []byte
+		n         int
+		truncated bool
+	}
+	queue := make(chan packet, udpQueueSize)
+	defer close(queue)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for p := range queue {
+				addPacket(agg, limiter, stats, p.buf[:p.n], p.truncated)
+				pool.Put(p.buf)
+			}
+		}()
+	}
+
+	for {
+		buf := pool.Get().([]byte)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		stats.packets.Add(1)
+		select {
+		case queue <- packet{buf, n, n == len(buf)}:
+		default:
+			stats.dropped.Add(1)
+			pool.Put(buf)
+		}
+	}
+}
+
+// statsHandler 以json返回已聚合的行数、UDP数据报的统计以及因为限速而等待的毫秒数
+func statsHandler(agg *brc.Aggregator, limiter *brc.RateLimiter, stats *udpStats) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{
+			"rows":          agg.Rows(),
+			"udp_packets":   stats.packets.Load(),
+			"udp_dropped":   stats.dropped.Load(),
+			"udp_truncated": stats.truncated.Load(),
+			"throttled_ms":  limiter.Throttled().Milliseconds(),
+		})
+	})
+}
+
+// handleConn 按行读取conn并写入agg，连接断开时末尾不完整的行被丢弃
+func handleConn(conn io.Reader, agg *brc.Aggregator, limiter *brc.RateLimiter) error {
+	buf := make([]byte, serveBufferSize)
+	n := 0
+	// 正在丢弃超长的行，直到遇到下一个换行
+	discard := false
+	for {
+		m, err := conn.Read(buf[n:])
+		// 之前读取的部分没有换行，只需要在新读取的部分中查找
+		if last := bytes.LastIndexByte(buf[n:n+m], '\n'); last >= 0 {
+			last += n
+			start := 0
+			if discard {
+				start, discard = n+bytes.IndexByte(buf[n:n+m], '\n')+1, false
+			}
+			addLines(agg, limiter, buf[start:last+1])
+			n = copy(buf, buf[last+1:n+m])
+		} else if n += m; n == len(buf) {
+			n, discard = 0, true
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// resultsHandler 以?format=text|json|csv返回current的当前结果，默认为text
+func resultsHandler(current func() brc.Results) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := current()
+		var err error
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "text":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			err = results.Write(w, brc.WriteOptions{})
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			err = results.WriteJSON(w, brc.WriteOptions{})
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = results.WriteCSV(w, brc.WriteOptions{})
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q, want text, json or csv", format), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("write results: %v", err)
+		}
+	})
+}
+
+// brc serve [-listen addr] [-udp addr] [-http addr] [-grpc addr] [-shards N] [-max-rows-per-sec N] [-max-bytes-per-sec size]
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		commandUsage(fs, "serve")
+	}
+	listen := fs.String("listen", ":7070", "accept `station;value` lines over TCP on `addr`")
+	httpAddr := fs.String("http", ":7071", "serve current results over HTTP on `addr`")
+	udpAddr := fs.String("udp", "", "also accept datagrams of one or more lines over UDP on `addr`")
+	grpcAddr := fs.String("grpc", "", "serve current results over gRPC on `addr`")
+	shards := fs.Int("shards", 16, "number of `shards` in the shared aggregation state")
+	maxRows := fs.Int("max-rows-per-sec", 0, "aggregate at most `rows` per second over all connections and datagrams, with bursts of up to one second's worth; TCP senders are slowed down and excess datagrams dropped. 0 means no limit")
+	maxBytes := fs.String("max-bytes-per-sec", "", "aggregate at most `size` of lines per second, e.g. 16MB, like -max-rows-per-sec")
+	parseFlags(fs, args)
+	limiter, err := newRateLimiter(*maxRows, *maxBytes)
+	if err != nil {
+		fatalUsage("%v", err)
+	}
+
+	agg := brc.NewAggregator(*shards)
+	stats := &udpStats{}
+	if *udpAddr != "" {
+		conn, err := net.ListenPacket("udp", *udpAddr)
+		if err != nil {
+			log.Fatalf("could not listen on %s: %v", *udpAddr, err)
+		}
+		go func() {
+			log.Fatal(serveUDP(conn, agg, limiter, stats, *shards))
+		}()
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("could not listen on %s: %v", *listen, err)
+	}
+
+	if *grpcAddr != "" {
+		go func() {
+			log.Fatal(serveGRPC(*grpcAddr, &queryServer{results: agg.Results}))
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/results", resultsHandler(agg.Results))
+	mux.Handle("/stats", statsHandler(agg, limiter, stats))
+	go func() {
+		log.Fatal(http.ListenAndServe(*httpAddr, mux))
+	}()
+
+	log.Printf("accepting lines on %s, serving results on %s/results", ln.Addr(), *httpAddr)
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("accept: %v", err)
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			if err := handleConn(conn, agg, limiter); err != nil {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
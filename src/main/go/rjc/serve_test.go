@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+func TestHandleConn(t *testing.T) {
+	agg := brc.NewAggregator(4)
+	// 逐字节读取，行会跨越多次Read；超长的行和末尾不完整的行被丢弃
+	long := strings.Repeat("x", serveBufferSize+10) + ";1.0\n"
+	r := iotest.OneByteReader(strings.NewReader("Hamburg;12.0\n" + long + "Bulawayo;8.9\nHamburg;-3.4\nOslo;1"))
+	if err := handleConn(r, agg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	resultsHandler(agg.Results).ServeHTTP(rec, httptest.NewRequest("GET", "/results", nil))
+	const expected = "{Bulawayo=8.9/8.9/8.9, Hamburg=-3.4/4.3/12.0}\n"
+	if body, _ := io.ReadAll(rec.Body); string(body) != expected {
+		t.Errorf("expected %q, got %q", expected, body)
+	}
+
+	rec = httptest.NewRecorder()
+	resultsHandler(agg.Results).ServeHTTP(rec, httptest.NewRequest("GET", "/results?format=xml", nil))
+	if rec.Code != 400 {
+		t.Errorf("expected 400 for unknown format, got %d", rec.Code)
+	}
+}
+
+func TestServeUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := brc.NewAggregator(4)
+	stats := &udpStats{}
+	done := make(chan error)
+	go func() { done <- serveUDP(conn, agg, nil, stats, 2) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Write([]byte("Hamburg;12.0\nBulawayo;8.9"))
+	client.Write([]byte("Hamburg;-3.4\n"))
+	// 超过udpPacketSize的数据报被截断，最后不完整的一行被丢弃
+	client.Write([]byte("Oslo;1.0\n" + strings.Repeat("x", udpPacketSize)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for agg.Rows() < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.Close()
+	<-done
+
+	buf := &strings.Builder{}
+	agg.Results().WriteTo(buf)
+	const expected = "{Bulawayo=8.9/8.9/8.9, Hamburg=-3.4/4.3/12.0, Oslo=1.0/1.0/1.0}\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	if stats.packets.Load() != 3 || stats.truncated.Load() != 1 {
+		t.Errorf("expected 3 packets with 1 truncated, got %d and %d", stats.packets.Load(), stats.truncated.Load())
+	}
+}
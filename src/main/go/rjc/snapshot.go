@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// snapshotTop 是SIGUSR1快照中列出的站点数
+const snapshotTop = 10
+
+// printSnapshot 打印当前进度和目前为止行数最多的snapshotTop个站点
+func printSnapshot(w io.Writer, p *brc.Progress, elapsed time.Duration) {
+	printProgress(w, p, elapsed)
+	results := p.Snapshot()
+	top, err := results.Top(snapshotTop, brc.MetricCount)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintf(w, "%d rows, %d stations so far, top %d by count: ", p.Rows(), len(results), len(top))
+	if err := top.Write(w, brc.WriteOptions{}); err != nil {
+		fmt.Fprintln(w, err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"io"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// notifySnapshots 在没有SIGUSR1的平台上什么也不做
+func notifySnapshots(w io.Writer, p *brc.Progress) (stop func()) {
+	return func() {}
+}
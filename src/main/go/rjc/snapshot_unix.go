@@ -0,0 +1,38 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// notifySnapshots 每次收到SIGUSR1时向w打印p的进度和目前的聚合结果，不中断处理，返回的函数停止监听
+func notifySnapshots(w io.Writer, p *brc.Progress) (stop func()) {
+	start := time.Now()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ch:
+				printSnapshot(w, p, time.Since(start))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// brc sort [-out file] [-run-size size] [-workers N] [-temp-dir dir] [file]
+func sortCommand(args []string) {
+	fs := flag.NewFlagSet("sort", flag.ExitOnError)
+	fs.Usage = func() {
+		commandUsage(fs, "sort")
+	}
+	out := fs.String("out", "-", "write the sorted lines to `file`, - for stdout")
+	runSize := fs.String("run-size", "64MB", "`size` of the input sorted in memory at once by each worker before spilling it to a temporary file")
+	workers := fs.Int("workers", 0, "number of `workers` sorting runs in parallel, 0 means min(8, NumCPU)")
+	tempDir := fs.String("temp-dir", "", "`dir` for the temporary run files, defaults to $TMPDIR")
+	parseFlags(fs, args)
+
+	if fs.NArg() > 1 {
+		fatalUsage("sort takes at most one input file")
+	}
+	size, err := parseSize(*runSize)
+	if err != nil || size > 1<<30 {
+		fatalUsage("invalid -run-size %q, want up to 1GB", *runSize)
+	}
+	if *workers < 0 {
+		fatalUsage("invalid -workers %d", *workers)
+	}
+	path := fs.Arg(0)
+	if path == "" {
+		path = "measurements.txt"
+	}
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		check(err)
+		defer f.Close()
+		r = f
+	}
+
+	start := time.Now()
+	if *out == "-" {
+		check(brc.ExternalSort(r, os.Stdout, *tempDir, int(size), *workers))
+		return
+	}
+	check(writeFileAtomic(*out, func(w io.Writer) error {
+		return brc.ExternalSort(r, w, *tempDir, int(size), *workers)
+	}))
+	fmt.Fprintf(os.Stderr, "Sorted %s into %s in %s\n", path, *out, time.Since(start))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+// splitFile 将path按行切分成parts个文件prefix000、prefix001...，每个文件由一个goroutine独立地打开输入并复制，
+// Linux上复制通过copy_file_range在内核中完成。返回写入的文件名
+func splitFile(path, prefix string, parts int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	offsets, err := brc.SplitLines(f, info.Size(), parts)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, parts)
+	errs := make([]error, parts)
+	var wg sync.WaitGroup
+	for i := 0; i < parts; i++ {
+		names[i] = fmt.Sprintf("%s%03d", prefix, i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = copyRange(path, names[i], offsets[i], offsets[i+1])
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// copyRange 将path中[start, end)的数据原子地写入dst
+func copyRange(path, dst string, start, end int64) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, func(w io.Writer) error {
+		_, err := io.CopyN(w, src, end-start)
+		return err
+	})
+}
+
+// brc split [-parts N] [-prefix prefix] file
+func split(args []string) {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	fs.Usage = func() {
+		commandUsage(fs, "split")
+	}
+	parts := fs.Int("parts", runtime.NumCPU(), "number of `parts` to split the file into")
+	prefix := fs.String("prefix", "", "write the parts to `prefix`000, prefix001 and so on, defaults to the input file name followed by a dot")
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fatalUsage("split requires exactly one input file")
+	}
+	if *parts <= 0 || *parts > 1000 {
+		fatalUsage("invalid -parts %d, want 1 to 1000", *parts)
+	}
+	path := fs.Arg(0)
+	if *prefix == "" {
+		*prefix = path + "."
+	}
+	start := time.Now()
+	names, err := splitFile(path, *prefix, *parts)
+	check(err)
+	fmt.Fprintf(os.Stderr, "Split %s into %d parts %s..%s in %s\n", path, len(names), names[0], names[len(names)-1], time.Since(start))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "measurements.txt")
+	data := strings.Repeat("Hamburg;12.0\nBulawayo;8.9\nPalembang;-38.8\n", 100)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := splitFile(path, filepath.Join(dir, "part-"), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 7 || filepath.Base(names[6]) != "part-006" {
+		t.Fatalf("unexpected parts %v", names)
+	}
+	var joined strings.Builder
+	for _, name := range names {
+		part, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(part) == 0 || part[len(part)-1] != '\n' {
+			t.Errorf("%s does not end at a line boundary", name)
+		}
+		joined.Write(part)
+	}
+	if joined.String() != data {
+		t.Error("parts do not add up to the input")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var statePath = flag.String("state", "", "write the partial aggregation state to `file`, used by the save subcommand")
+
+// saveState 实现save子命令：接受与默认命令相同的参数，将聚合状态而不是结果写入-state，
+// 之后可以用merge子命令与其它机器或其它时间保存的状态合并
+func saveState(args []string) {
+	parseFlags(flag.CommandLine, args)
+	if *statePath == "" {
+		fatalUsage("save requires -state")
+	}
+	path := inputPath()
+	opts := processOptions()
+	var merged *brc.MergedStatistics
+	var err error
+	if path == "-" {
+		merged, err = brc.ProcessReaderState(os.Stdin, opts...)
+	} else {
+		merged, err = brc.ProcessState(path, opts...)
+	}
+	checkProcessError(path, err)
+	check(writeFileAtomic(*statePath, merged.WriteState))
+}
+
+// readStateFile 读取save子命令或checkpoint写入的状态文件
+func readStateFile(path string) (*brc.MergedStatistics, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return brc.ReadState(f)
+}
+
+// mergeStates 实现merge子命令：合并位置参数中的状态文件，按-format、-percentiles等参数输出最终结果
+func mergeStates(args []string) {
+	parseFlags(flag.CommandLine, args)
+	if flag.NArg() == 0 {
+		fatalUsage("merge requires at least one state file")
+	}
+	var merged *brc.MergedStatistics
+	for _, path := range flag.Args() {
+		s, err := readStateFile(path)
+		if err != nil {
+			log.Fatalf("could not read state %s: %v", path, err)
+		}
+		if merged == nil {
+			merged = s
+		} else {
+			merged.Merge(s)
+		}
+	}
+	check(writeOutput(merged.Results(processOptions()...)))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hyperchao/1brc/pkg/brc"
+)
+
+var verify = flag.String("verify", "", "compare the canonical output with the expected output in `file`, e.g. from the Java reference implementation, and exit with status 1 listing the differing stations on mismatch")
+
+// parseOutput 用brc.ReadResults解析`{a=1.0/2.0/3.0, ...}`格式的输出，返回站点名到`min/mean/max`的映射。
+// 没有站点时Write不输出任何内容，空字符串和"{}"都表示没有站点
+func parseOutput(s string) (map[string]string, error) {
+	results, err := brc.ReadResults([]byte(s))
+	if err != nil {
+		return nil, err
+	}
+	stations := make(map[string]string, len(results))
+	for _, st := range results {
+		// 文本格式没有观测次数，Sum是平均值
+		if st.Count != 0 {
+			return nil, fmt.Errorf("output is not in the default text format")
+		}
+		stations[st.Name] = fmt.Sprintf("%.1f/%.1f/%.1f", float64(st.Min)/10, float64(st.Sum)/10, float64(st.Max)/10)
+	}
+	return stations, nil
+}
+
+// diffOutput 返回实际输出与预期输出中每个不一致站点的说明，按站点名排序
+func diffOutput(actual, expected map[string]string) []string {
+	var diffs []string
+	for name, want := range expected {
+		got, ok := actual[name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: missing, expected %s", name, want))
+		} else if got != want {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %s, got %s", name, want, got))
+		}
+	}
+	for name, got := range actual {
+		if _, ok := expected[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected, got %s", name, got))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// verifyResults 将结果的标准输出与-verify指定的文件比较，不一致时打印每个站点的差异并以状态1退出
+func verifyResults(results brc.Results) {
+	data, err := os.ReadFile(*verify)
+	check(err)
+	expected, err := parseOutput(string(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid expected output %s: %v\n", *verify, err)
+		os.Exit(1)
+	}
+	buf := &bytes.Buffer{}
+	_, err = results.WriteTo(buf)
+	check(err)
+	actual, err := parseOutput(buf.String())
+	check(err)
+	diffs := diffOutput(actual, expected)
+	if len(diffs) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "output differs from %s in %d stations:\n", *verify, len(diffs))
+	for _, d := range diffs {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	os.Exit(1)
+}
+
+// verifyCommand 实现verify子命令：与run一样聚合位置参数中预期输出之后的输入，再与预期输出比较，
+// 等价于run -verify expected
+func verifyCommand(args []string) {
+	checkSubcommandFlags(parseFlags(flag.CommandLine, args))
+	if flag.NArg() == 0 {
+		fatalUsage("verify requires an expected output file")
+	}
+	*verify = flag.Arg(0)
+	runAggregate(flag.Args()[1:])
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	stations, err := parseOutput("{a=1.0/2.0/3.0, b=c=-0.1/0.0/0.1, d, e=9.9/9.9/9.9}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"a": "1.0/2.0/3.0", "b=c": "-0.1/0.0/0.1", "d, e": "9.9/9.9/9.9"}
+	if !reflect.DeepEqual(stations, expected) {
+		t.Errorf("expected %v, got %v", expected, stations)
+	}
+	for _, s := range []string{"a=1.0/2.0/3.0", "{a=1.0/2.0}", "{a=1.0/2.0/3.0, b=}", `{"a":{"min":1.0,"mean":1.5,"max":2.0,"count":2}}`} {
+		if _, err := parseOutput(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+
+	// 参考实现的输出都可以被解析
+	paths, _ := filepath.Glob("../../../test/resources/samples/*.out")
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stations, err := parseOutput(string(data))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		if n := strings.Count(string(data), "/") / 2; len(stations) != n {
+			t.Errorf("%s: expected %d stations, got %d", path, n, len(stations))
+		}
+	}
+}
+
+func TestDiffOutput(t *testing.T) {
+	actual := map[string]string{"a": "1.0/2.0/3.0", "b": "1.0/1.0/1.0", "c": "0.0/0.0/0.0"}
+	expected := map[string]string{"a": "1.0/2.0/3.0", "b": "1.0/1.1/1.0", "d": "5.0/5.0/5.0"}
+	diffs := diffOutput(actual, expected)
+	want := []string{
+		"b: expected 1.0/1.1/1.0, got 1.0/1.0/1.0",
+		"c: unexpected, got 0.0/0.0/0.0",
+		"d: missing, expected 5.0/5.0/5.0",
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("expected %q, got %q", want, diffs)
+	}
+}